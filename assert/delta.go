@@ -0,0 +1,73 @@
+package assert
+
+import (
+	"sort"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// EqualDelta asserts that the difference between the baseline and the variant is exactly the expected one.
+// The order of keys and field paths is ignored.
+// If there is a difference, fail the test and output diffs.
+//
+// Example of asserting that enabling autoscaling only adds an HPA and removes the replicas:
+//
+//  delta, err := r.RenderDelta(releaseName, chartPath, nil, []helmut.Option{
+//  	helmut.WithSet("autoscaling.enabled=true"),
+//  })
+//
+//  assert.EqualDelta(t, delta, &helmut.Delta{
+//  	Added:   []helmut.ObjectKey{hpaKey},
+//  	Changed: map[helmut.ObjectKey][]string{deploymentKey: {"spec.replicas"}},
+//  })
+//
+func EqualDelta(t TestingT, got, want *helmut.Delta) bool {
+	t.Helper()
+
+	opts := []cmp.Option{
+		cmpopts.EquateEmpty(),
+		cmpopts.SortSlices(func(x, y helmut.ObjectKey) bool {
+			return x.String() < y.String()
+		}),
+	}
+
+	if diff := cmp.Diff(normalizeDelta(want), normalizeDelta(got), opts...); diff != "" {
+		t.Errorf("delta mismatch (-want +got):\n%s", diff)
+
+		return false
+	}
+
+	return true
+}
+
+// normalizeDelta returns a copy of the delta with the changed field paths sorted
+// and the objects without changed field paths removed.
+func normalizeDelta(delta *helmut.Delta) *helmut.Delta {
+	if delta == nil {
+		return &helmut.Delta{}
+	}
+
+	normalized := &helmut.Delta{
+		Added:   delta.Added,
+		Removed: delta.Removed,
+	}
+
+	for key, paths := range delta.Changed {
+		if len(paths) == 0 {
+			continue
+		}
+
+		if normalized.Changed == nil {
+			normalized.Changed = make(map[helmut.ObjectKey][]string)
+		}
+
+		sorted := append([]string(nil), paths...)
+		sort.Strings(sorted)
+
+		normalized.Changed[key] = sorted
+	}
+
+	return normalized
+}
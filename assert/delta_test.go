@@ -0,0 +1,68 @@
+package assert_test
+
+import (
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestEqualDelta(t *testing.T) {
+	t.Parallel()
+
+	deploymentKey := helmut.NewObjectKey("", "nginx", schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	serviceKey := helmut.NewObjectKey("", "nginx", schema.GroupVersionKind{Version: "v1", Kind: "Service"})
+
+	got := &helmut.Delta{
+		Added:   []helmut.ObjectKey{serviceKey, deploymentKey},
+		Changed: map[helmut.ObjectKey][]string{deploymentKey: {"spec.template", "spec.replicas"}},
+	}
+
+	tests := []struct {
+		name  string
+		delta *helmut.Delta
+		want  bool
+	}{
+		{
+			name: "same delta in a different order",
+			delta: &helmut.Delta{
+				Added:   []helmut.ObjectKey{deploymentKey, serviceKey},
+				Removed: []helmut.ObjectKey{},
+				Changed: map[helmut.ObjectKey][]string{deploymentKey: {"spec.replicas", "spec.template"}},
+			},
+			want: true,
+		},
+		{
+			name: "missing changed field path",
+			delta: &helmut.Delta{
+				Added:   []helmut.ObjectKey{deploymentKey, serviceKey},
+				Changed: map[helmut.ObjectKey][]string{deploymentKey: {"spec.replicas"}},
+			},
+			want: false,
+		},
+		{
+			name: "unexpected removed object",
+			delta: &helmut.Delta{
+				Added:   []helmut.ObjectKey{deploymentKey},
+				Removed: []helmut.ObjectKey{serviceKey},
+				Changed: map[helmut.ObjectKey][]string{deploymentKey: {"spec.replicas", "spec.template"}},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeT := &fakeT{}
+
+			if ok := assert.EqualDelta(fakeT, got, tt.delta); ok != tt.want {
+				t.Errorf("got %t, want %t, message: %s", ok, tt.want, fakeT.message)
+			}
+		})
+	}
+}
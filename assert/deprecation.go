@@ -0,0 +1,50 @@
+package assert
+
+import (
+	"strings"
+
+	"github.com/d-kuro/helmut"
+)
+
+// NoDeprecatedAPIs asserts that the manifests have no objects whose APIs are deprecated or removed
+// at the Kubernetes version, such as "1.25".
+// If there are such objects, fail the test and output them with the APIs to migrate to.
+//
+// Example of checking the manifests before upgrading the cluster:
+//
+//  assert.NoDeprecatedAPIs(t, manifests, "1.25", assert.WithAllowDeprecatedAPIs())
+//
+func NoDeprecatedAPIs(t TestingT, manifests *helmut.Manifests, kubeVersion string, options ...Option) bool {
+	t.Helper()
+
+	opts := &option{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	deprecated, err := helmut.CheckDeprecatedAPIs(manifests, kubeVersion)
+	if err != nil {
+		t.Errorf("failed to check deprecated APIs: %s", err)
+
+		return false
+	}
+
+	var b strings.Builder
+
+	for _, d := range deprecated {
+		if !d.Removed && opts.allowDeprecatedAPIs {
+			continue
+		}
+
+		b.WriteString("\n  " + d.String())
+	}
+
+	if b.Len() == 0 {
+		return true
+	}
+
+	t.Errorf("deprecated APIs found:%s", b.String())
+
+	return false
+}
@@ -0,0 +1,79 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNoDeprecatedAPIs(t *testing.T) {
+	t.Parallel()
+
+	manifests := helmut.NewManifests()
+	manifests.Store(helmut.NewObjectKey("default", "foo",
+		schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}), &unstructured.Unstructured{})
+	manifests.Store(helmut.NewObjectKey("default", "foo",
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}), &unstructured.Unstructured{})
+
+	tests := []struct {
+		name        string
+		kubeVersion string
+		options     []assert.Option
+		want        bool
+		wantMessage string
+	}{
+		{
+			name:        "not deprecated",
+			kubeVersion: "1.13",
+			want:        true,
+		},
+		{
+			name:        "deprecated",
+			kubeVersion: "1.14",
+			want:        false,
+			wantMessage: "ingress.extensions/default/foo: extensions/v1beta1 Ingress is deprecated in v1.14+",
+		},
+		{
+			name:        "deprecated allowed",
+			kubeVersion: "1.14",
+			options:     []assert.Option{assert.WithAllowDeprecatedAPIs()},
+			want:        true,
+		},
+		{
+			name:        "removed",
+			kubeVersion: "1.22",
+			options:     []assert.Option{assert.WithAllowDeprecatedAPIs()},
+			want:        false,
+			wantMessage: "unavailable in v1.22+; use networking.k8s.io/v1 Ingress",
+		},
+		{
+			name:        "invalid version",
+			kubeVersion: "latest",
+			want:        false,
+			wantMessage: "failed to check deprecated APIs",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeT := &fakeT{}
+
+			got := assert.NoDeprecatedAPIs(fakeT, manifests, tt.kubeVersion, tt.options...)
+			if got != tt.want {
+				t.Errorf("got %t, want %t: %s", got, tt.want, fakeT.message)
+			}
+
+			if !strings.Contains(fakeT.message, tt.wantMessage) {
+				t.Errorf("got message %q, want to contain %q", fakeT.message, tt.wantMessage)
+			}
+		})
+	}
+}
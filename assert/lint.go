@@ -0,0 +1,56 @@
+package assert
+
+import (
+	"strings"
+
+	"github.com/d-kuro/helmut"
+)
+
+// LintClean asserts that the lint findings have no warnings and errors, like "helm lint --strict".
+// The findings of the suppressed rules and the informational findings are ignored.
+// If there are findings, fail the test and output them.
+//
+// Example of linting the chart with the values of the test:
+//
+//  findings, err := r.Lint(chartPath, helmut.WithValues("testdata/values.yaml"))
+//
+//  assert.LintClean(t, findings, assert.WithSuppressLintRules(helmut.LintRuleDeprecatedAPI))
+//
+func LintClean(t TestingT, findings []helmut.LintFinding, options ...Option) bool {
+	t.Helper()
+
+	opts := &option{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	var b strings.Builder
+
+	for _, f := range findings {
+		if f.Severity < helmut.LintWarning || suppressed(f.Rule, opts.suppressedLintRules) {
+			continue
+		}
+
+		b.WriteString("\n  " + f.String())
+	}
+
+	if b.Len() == 0 {
+		return true
+	}
+
+	t.Errorf("lint findings found:%s", b.String())
+
+	return false
+}
+
+// suppressed returns true if the rule matches one of the suppressed rules.
+func suppressed(rule string, suppressedRules []string) bool {
+	for _, s := range suppressedRules {
+		if helmut.MatchLintRule(rule, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+)
+
+func TestLintClean(t *testing.T) {
+	t.Parallel()
+
+	icon := helmut.LintFinding{
+		Rule:     helmut.LintRuleChartIcon,
+		Severity: helmut.LintInfo,
+		Path:     "Chart.yaml",
+		Message:  "icon is recommended",
+	}
+
+	deprecated := helmut.LintFinding{
+		Rule:     helmut.LintRuleDeprecatedAPI,
+		Severity: helmut.LintWarning,
+		Path:     "templates/ingress.yaml",
+		Message:  "extensions/v1beta1 Ingress is deprecated",
+	}
+
+	tests := []struct {
+		name        string
+		findings    []helmut.LintFinding
+		options     []assert.Option
+		want        bool
+		wantMessage string
+	}{
+		{
+			name:     "no findings",
+			findings: nil,
+			want:     true,
+		},
+		{
+			name:     "informational findings",
+			findings: []helmut.LintFinding{icon},
+			want:     true,
+		},
+		{
+			name:        "warning",
+			findings:    []helmut.LintFinding{icon, deprecated},
+			want:        false,
+			wantMessage: "[WARNING] templates/ingress.yaml: extensions/v1beta1 Ingress is deprecated (templates/deprecated-api)",
+		},
+		{
+			name:     "suppressed rule",
+			findings: []helmut.LintFinding{icon, deprecated},
+			options:  []assert.Option{assert.WithSuppressLintRules(helmut.LintRuleDeprecatedAPI)},
+			want:     true,
+		},
+		{
+			name:     "suppressed group",
+			findings: []helmut.LintFinding{icon, deprecated},
+			options:  []assert.Option{assert.WithSuppressLintRules(helmut.LintRuleTemplates)},
+			want:     true,
+		},
+		{
+			name:        "other rule suppressed",
+			findings:    []helmut.LintFinding{icon, deprecated},
+			options:     []assert.Option{assert.WithSuppressLintRules(helmut.LintRuleMetadataName)},
+			want:        false,
+			wantMessage: "(templates/deprecated-api)",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeT := &fakeT{}
+
+			got := assert.LintClean(fakeT, tt.findings, tt.options...)
+			if got != tt.want {
+				t.Errorf("got %t, want %t: %s", got, tt.want, fakeT.message)
+			}
+
+			if !strings.Contains(fakeT.message, tt.wantMessage) {
+				t.Errorf("got message %q, want to contain %q", fakeT.message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -21,6 +21,12 @@ type option struct {
 
 	// ignoreOption stores the option to ignore object diffs.
 	ignoreOption *ignoreOption
+
+	// suppressedLintRules is the lint rules whose findings are ignored by LintClean.
+	suppressedLintRules []string
+
+	// allowDeprecatedAPIs is whether NoDeprecatedAPIs ignores the deprecated APIs that are still served.
+	allowDeprecatedAPIs bool
 }
 
 // ignoreOption stores the option to ignore object diffs.
@@ -154,6 +160,28 @@ func WithAdditionalKeys(fn ...func(helmut.ObjectKey) helmut.ObjectKey) Option {
 	}
 }
 
+// WithSuppressLintRules is an option to ignore the findings of the lint rules in LintClean.
+// A group of the rules, such as helmut.LintRuleTemplates, suppresses all the rules in the group.
+//
+// Example of allowing the deprecated APIs:
+//
+//  assert.LintClean(t, findings, assert.WithSuppressLintRules(helmut.LintRuleDeprecatedAPI))
+//
+func WithSuppressLintRules(rules ...string) Option {
+	return func(o *option) {
+		o.suppressedLintRules = append(o.suppressedLintRules, rules...)
+	}
+}
+
+// WithAllowDeprecatedAPIs is an option to ignore the deprecated APIs in NoDeprecatedAPIs
+// as long as they are still served at the Kubernetes version.
+// Only the removed APIs fail the test.
+func WithAllowDeprecatedAPIs() Option {
+	return func(o *option) {
+		o.allowDeprecatedAPIs = true
+	}
+}
+
 // helmManagedLabel is the label used by Helm.
 // see: https://helm.sh/docs/chart_best_practices/labels/
 type helmManagedLabel string
@@ -0,0 +1,56 @@
+package assert
+
+import (
+	"strings"
+
+	"github.com/d-kuro/helmut"
+)
+
+// UpgradeSafe asserts that upgrading from the previous manifests to the current manifests
+// neither changes the immutable fields nor recreates objects by changing their kind or name.
+// If there is such a change, fail the test and output the changes.
+// The objects of the same name whose kind is changed are detected, and the renamed objects are declared by helmut.WithRenamed.
+//
+// Example of asserting that the new chart version can be upgraded from the released version:
+//
+//  previous, err := r.RenderTemplates(releaseName, "testdata/mychart-1.0.0.tgz")
+//
+//  current, err := r.RenderTemplates(releaseName, chartPath)
+//
+//  assert.UpgradeSafe(t, previous, current)
+//
+func UpgradeSafe(t TestingT, previous, current *helmut.Manifests, options ...helmut.UpgradeCheckOption) bool {
+	t.Helper()
+
+	report, err := helmut.CheckUpgrade(previous, current, options...)
+	if err != nil {
+		t.Errorf("failed to check upgrade: %s", err)
+
+		return false
+	}
+
+	if report.Empty() {
+		return true
+	}
+
+	var b strings.Builder
+
+	keys := make([]helmut.ObjectKey, 0, len(report.ImmutableFields))
+	for key := range report.ImmutableFields {
+		keys = append(keys, key)
+	}
+
+	helmut.SortObjectKeys(keys)
+
+	for _, key := range keys {
+		b.WriteString("\n  " + key.String() + ": immutable fields changed: " + strings.Join(report.ImmutableFields[key], ", "))
+	}
+
+	for _, recreation := range report.Recreated {
+		b.WriteString("\n  " + recreation.Previous.String() + ": recreated as " + recreation.Current.String())
+	}
+
+	t.Errorf("upgrade breaking changes found:%s", b.String())
+
+	return false
+}
@@ -0,0 +1,80 @@
+package assert_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestUpgradeSafe(t *testing.T) {
+	t.Parallel()
+
+	serviceGVK := schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+
+	service := func(name, clusterIP string) *helmut.Manifests {
+		manifests := helmut.NewManifests()
+		manifests.Store(helmut.NewObjectKey("", name, serviceGVK), &corev1.Service{
+			Spec: corev1.ServiceSpec{ClusterIP: clusterIP},
+		})
+
+		return manifests
+	}
+
+	tests := []struct {
+		name        string
+		current     *helmut.Manifests
+		options     []helmut.UpgradeCheckOption
+		want        bool
+		wantMessage string
+	}{
+		{
+			name:    "no breaking changes",
+			current: service("app", "10.0.0.1"),
+			want:    true,
+		},
+		{
+			name:        "immutable field changed",
+			current:     service("app", "None"),
+			want:        false,
+			wantMessage: "service/app: immutable fields changed: spec.clusterIP",
+		},
+		{
+			name:    "object added and removed",
+			current: service("web", "10.0.0.1"),
+			want:    true,
+		},
+		{
+			name:    "name changed",
+			current: service("web", "10.0.0.1"),
+			options: []helmut.UpgradeCheckOption{helmut.WithRenamed(
+				helmut.NewObjectKey("", "app", serviceGVK),
+				helmut.NewObjectKey("", "web", serviceGVK),
+			)},
+			want:        false,
+			wantMessage: "service/app: recreated as service/web",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeT := &fakeT{}
+
+			got := assert.UpgradeSafe(fakeT, service("app", "10.0.0.1"), tt.current, tt.options...)
+			if got != tt.want {
+				t.Errorf("got %t, want %t: %s", got, tt.want, fakeT.message)
+			}
+
+			if !strings.Contains(fakeT.message, tt.wantMessage) {
+				t.Errorf("got message %q, want to contain %q", fakeT.message, tt.wantMessage)
+			}
+		})
+	}
+}
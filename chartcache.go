@@ -0,0 +1,166 @@
+package helmut
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mitchellh/copystructure"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// chartCache caches the loaded charts by path and content hash.
+// The cached chart is reloaded when the content of the chart changes.
+// It is safe for concurrent use.
+type chartCache struct {
+	entries map[string]*chartCacheEntry
+
+	mu sync.Mutex
+}
+
+// chartCacheEntry is a chart stored in chartCache.
+type chartCacheEntry struct {
+	hash  string
+	chart *chart.Chart
+}
+
+// newChartCache creates and returns a new chartCache.
+func newChartCache() *chartCache {
+	return &chartCache{entries: make(map[string]*chartCacheEntry)}
+}
+
+// Load returns a copy of the chart for a path.
+// If the chart is not cached or its content has changed, the chart will be loaded.
+// Since rendering modifies the chart, the returned chart is a copy that can be modified freely.
+func (c *chartCache) Load(path string) (*chart.Chart, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	hash, err := hashChart(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the chart: %w", err)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[abs]
+	c.mu.Unlock()
+
+	if ok && entry.hash == hash {
+		return copyChart(entry.chart)
+	}
+
+	loaded, err := loader.Load(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the chart: %w", err)
+	}
+
+	c.mu.Lock()
+	c.entries[abs] = &chartCacheEntry{hash: hash, chart: loaded}
+	c.mu.Unlock()
+
+	return copyChart(loaded)
+}
+
+// hashChart returns the hash of the chart archive or the files in the chart directory.
+// The symbolic links are followed like the chart loader.
+func hashChart(path string) (string, error) {
+	h := sha256.New()
+	buf := make([]byte, 32*1024)
+
+	err := walkSymlinks(path, func(name string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(path, name)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Separate the file name and the content so that they are not mixed.
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+
+		// Hide io.WriterTo of *os.File so that the buffer is reused for all files.
+		if _, err := io.CopyBuffer(h, struct{ io.Reader }{f}, buf); err != nil {
+			return err
+		}
+
+		_, err = h.Write([]byte{0})
+
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyChart returns a copy of the chart that can be modified by rendering.
+// Metadata, values and dependencies are copied deeply, but the files are shared because they are never modified.
+func copyChart(c *chart.Chart) (*chart.Chart, error) {
+	copied := *c
+
+	if c.Metadata != nil {
+		metadata := *c.Metadata
+
+		// A nil dependencies means that the subcharts are not managed by Chart.yaml, so keep it nil.
+		if c.Metadata.Dependencies != nil {
+			metadata.Dependencies = make([]*chart.Dependency, 0, len(c.Metadata.Dependencies))
+
+			for _, dep := range c.Metadata.Dependencies {
+				d := *dep
+				d.Tags = append([]string(nil), dep.Tags...)
+				d.ImportValues = append([]interface{}(nil), dep.ImportValues...)
+				metadata.Dependencies = append(metadata.Dependencies, &d)
+			}
+		}
+
+		copied.Metadata = &metadata
+	}
+
+	if c.Values != nil {
+		values, err := copystructure.Copy(c.Values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy values: %w", err)
+		}
+
+		copied.Values, _ = values.(map[string]interface{})
+	}
+
+	copied.Templates = append([]*chart.File(nil), c.Templates...)
+	copied.Files = append([]*chart.File(nil), c.Files...)
+
+	deps := make([]*chart.Chart, 0, len(c.Dependencies()))
+
+	for _, dep := range c.Dependencies() {
+		d, err := copyChart(dep)
+		if err != nil {
+			return nil, err
+		}
+
+		deps = append(deps, d)
+	}
+
+	copied.SetDependencies(deps...)
+
+	return &copied, nil
+}
@@ -0,0 +1,221 @@
+package helmut_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+func TestChartCacheInvalidation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	chartPath := copyChart(t, filepath.Join("testdata", chartName))
+
+	r := newRenderer(t)
+
+	manifests, err := r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	assert.Contains(t, manifests, newDeployment(chartName, releaseName), assert.WithIgnoreHelmManagedLabels())
+
+	valuesPath := filepath.Join(chartPath, "values.yaml")
+
+	data, err := os.ReadFile(valuesPath)
+	if err != nil {
+		t.Fatalf("failed to read values: %s", err)
+	}
+
+	data = append(data, []byte("\nreplicaCount: 5\n")...)
+
+	if err := os.WriteFile(valuesPath, data, 0o600); err != nil {
+		t.Fatalf("failed to write values: %s", err)
+	}
+
+	manifests, err = r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	assert.Contains(t, manifests, newDeployment(chartName, releaseName, withDeploymentReplicas(5)),
+		assert.WithIgnoreHelmManagedLabels())
+}
+
+func TestChartCacheSymlinkedDirectory(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	chartPath := copyChart(t, filepath.Join("testdata", chartName))
+
+	// Replace the templates directory with a symbolic link to the directory outside the chart.
+	templates := filepath.Join(t.TempDir(), "templates")
+
+	if err := os.Rename(filepath.Join(chartPath, "templates"), templates); err != nil {
+		t.Fatalf("failed to move templates: %s", err)
+	}
+
+	if err := os.Symlink(templates, filepath.Join(chartPath, "templates")); err != nil {
+		t.Fatalf("failed to create symbolic link: %s", err)
+	}
+
+	r := newRenderer(t)
+
+	manifests, err := r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	length := manifests.Length()
+
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: symlinked\n"
+
+	if err := os.WriteFile(filepath.Join(templates, "configmap.yaml"), []byte(configMap), 0o600); err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+
+	manifests, err = r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	if got, want := manifests.Length(), length+1; got != want {
+		t.Errorf("got %d manifests, want %d after adding a template to the symlinked directory", got, want)
+	}
+}
+
+func TestChartCacheReturnsCopy(t *testing.T) {
+	t.Parallel()
+
+	r := newRenderer(t)
+	chartPath := filepath.Join("testdata", "test-chart")
+
+	c, err := r.LoadChart(chartPath)
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	c.Values["replicaCount"] = 10
+	c.Metadata.Name = "modified"
+	c.Templates = nil
+
+	c, err = r.LoadChart(chartPath)
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	if got := c.Values["replicaCount"]; got != float64(1) {
+		t.Errorf("got replicaCount %v, want 1", got)
+	}
+
+	if got := c.Metadata.Name; got != "test-chart" {
+		t.Errorf("got name %s, want test-chart", got)
+	}
+
+	if len(c.Templates) == 0 {
+		t.Error("templates of the cached chart have been modified")
+	}
+}
+
+func BenchmarkRenderTemplates(b *testing.B) {
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	chartPath := filepath.Join("testdata", chartName)
+
+	b.Run("cached", func(b *testing.B) {
+		r := newRenderer(b)
+
+		for i := 0; i < b.N; i++ {
+			if _, err := r.RenderTemplates(releaseName, chartPath); err != nil {
+				b.Fatalf("failed to render templates: %s", err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r := helmut.New()
+
+			if _, err := r.RenderTemplates(releaseName, chartPath); err != nil {
+				b.Fatalf("failed to render templates: %s", err)
+			}
+
+			if err := r.Close(); err != nil {
+				b.Fatalf("failed to close renderer: %s", err)
+			}
+		}
+	})
+}
+
+func BenchmarkLoadChart(b *testing.B) {
+	chartPath := filepath.Join("testdata", "test-chart")
+
+	b.Run("cached", func(b *testing.B) {
+		r := newRenderer(b)
+
+		for i := 0; i < b.N; i++ {
+			if _, err := r.LoadChart(chartPath); err != nil {
+				b.Fatalf("failed to load chart: %s", err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := loader.Load(chartPath); err != nil {
+				b.Fatalf("failed to load chart: %s", err)
+			}
+		}
+	})
+}
+
+// copyChart copies the chart directory to a temporary directory and returns the copied path.
+func copyChart(t *testing.T, src string) string {
+	t.Helper()
+
+	dst := filepath.Join(t.TempDir(), filepath.Base(src))
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o600)
+	})
+	if err != nil {
+		t.Fatalf("failed to copy chart: %s", err)
+	}
+
+	return dst
+}
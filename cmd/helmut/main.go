@@ -0,0 +1,140 @@
+// Command helmut provides the tools for the Helm charts tested with helmut.
+//
+// Usage:
+//
+//  helmut schema generate [-o file | -w] CHART
+//  helmut schema check CHART
+//
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/d-kuro/helmut"
+)
+
+const usage = `usage:
+  helmut schema generate [-o file | -w] CHART
+        Generate values.schema.json from the values.yaml of the chart.
+  helmut schema check CHART
+        Check the values.schema.json of the chart for drift against the values.yaml.
+`
+
+// errDrift is returned if the schema has drifted, so that the command exits with a non-zero status.
+var errDrift = errors.New("values.schema.json has drifted from values.yaml")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "helmut: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command with the arguments excluding the program name.
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 || args[0] != "schema" {
+		fmt.Fprint(stderr, usage)
+
+		return errors.New("unknown command")
+	}
+
+	switch args[1] {
+	case "generate":
+		return generateSchema(args[2:], stdout, stderr)
+	case "check":
+		return checkSchema(args[2:], stdout, stderr)
+	}
+
+	fmt.Fprint(stderr, usage)
+
+	return fmt.Errorf("unknown schema command %q", args[1])
+}
+
+// generateSchema runs the "schema generate" command.
+func generateSchema(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("schema generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	output := flags.String("o", "", "write the schema to the file instead of the standard output")
+	write := flags.Bool("w", false, "write the schema to values.schema.json in the chart directory")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("schema generate requires a chart")
+	}
+
+	chartPath := flags.Arg(0)
+
+	if *write {
+		if len(*output) != 0 {
+			fmt.Fprint(stderr, usage)
+
+			return errors.New("-o and -w cannot be specified together")
+		}
+
+		// The schema is written beside the values.yaml, which is not possible for a chart archive.
+		if info, err := os.Stat(chartPath); err == nil && !info.IsDir() {
+			fmt.Fprint(stderr, usage)
+
+			return fmt.Errorf("-w requires a chart directory, but %s is not a directory", chartPath)
+		}
+	}
+
+	schema, err := helmut.GenerateValuesSchema(chartPath)
+	if err != nil {
+		return err
+	}
+
+	if *write {
+		*output = filepath.Join(chartPath, "values.schema.json")
+	}
+
+	if len(*output) == 0 {
+		_, err := stdout.Write(schema)
+
+		return err
+	}
+
+	if err := os.WriteFile(*output, schema, 0o644); err != nil { //nolint:gosec // The schema is a part of the chart.
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+
+	return nil
+}
+
+// checkSchema runs the "schema check" command.
+func checkSchema(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("schema check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("schema check requires a chart")
+	}
+
+	drifts, err := helmut.CheckValuesSchema(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	for _, drift := range drifts {
+		fmt.Fprintln(stdout, drift)
+	}
+
+	if len(drifts) != 0 {
+		return errDrift
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSchemaGenerate(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+
+	if err := run([]string{"schema", "generate", "../../testdata/schema-chart"}, &stdout, &stderr); err != nil {
+		t.Fatalf("failed to run: %s\n%s", err, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), `"replicaCount": {`) {
+		t.Errorf("unexpected schema:\n%s", stdout.String())
+	}
+
+	output := filepath.Join(t.TempDir(), "values.schema.json")
+
+	if err := run([]string{"schema", "generate", "-o", output, "../../testdata/schema-chart"}, &stdout, &stderr); err != nil {
+		t.Fatalf("failed to run: %s\n%s", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read the output: %s", err)
+	}
+
+	if !strings.HasPrefix(stdout.String(), string(data)) {
+		t.Errorf("the output file differs from the standard output:\n%s", data)
+	}
+}
+
+func TestRunSchemaGenerateUsageError(t *testing.T) {
+	t.Parallel()
+
+	archive := filepath.Join(t.TempDir(), "schema-chart-0.1.0.tgz")
+
+	if err := os.WriteFile(archive, nil, 0o600); err != nil {
+		t.Fatalf("failed to write the archive: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "both -o and -w",
+			args: []string{"-o", filepath.Join(t.TempDir(), "values.schema.json"), "-w", "../../testdata/schema-chart"},
+		},
+		{
+			name: "-w with a chart archive",
+			args: []string{"-w", archive},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout, stderr bytes.Buffer
+
+			if err := run(append([]string{"schema", "generate"}, tt.args...), &stdout, &stderr); err == nil {
+				t.Error("got no error for a usage error")
+			}
+
+			if !strings.Contains(stderr.String(), "usage:") {
+				t.Errorf("usage not printed:\n%s", stderr.String())
+			}
+
+			if _, err := os.Stat(filepath.Join(filepath.Dir(archive), "values.schema.json")); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("got schema written beside the chart archive: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunSchemaCheck(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+
+	if err := run([]string{"schema", "check", "../../testdata/schema-chart"}, &stdout, &stderr); err != nil {
+		t.Fatalf("failed to run: %s\n%s", err, stdout.String())
+	}
+
+	dir := t.TempDir()
+
+	for name, data := range map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: drift\nversion: 0.1.0\n",
+		"values.yaml":        "replicaCount: 1\n",
+		"values.schema.json": `{"type": "object", "properties": {"replicaCount": {"type": "string"}}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+
+	stdout.Reset()
+
+	err := run([]string{"schema", "check", dir}, &stdout, &stderr)
+	if !errors.Is(err, errDrift) {
+		t.Fatalf("got error %v, want %v", err, errDrift)
+	}
+
+	if want := "/properties/replicaCount: type is string, want integer\n"; stdout.String() != want {
+		t.Errorf("got output %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+
+	if err := run([]string{"render"}, &stdout, &stderr); err == nil {
+		t.Error("got no error for an unknown command")
+	}
+
+	if !strings.Contains(stderr.String(), "usage:") {
+		t.Errorf("usage not printed:\n%s", stderr.String())
+	}
+}
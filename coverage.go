@@ -0,0 +1,673 @@
+package helmut
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template/parse"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// coverageAPIVersion and coverageKind are the resource looked up by the instrumented templates
+// to record that a block is executed.
+const (
+	coverageAPIVersion = "coverage.helmut.d-kuro.github.io/v1"
+	coverageKind       = "TemplateBlock"
+)
+
+// TemplateCoverage records the blocks of the templates executed by the renders,
+// like the coverage profile of "go test -cover".
+// A block is the body of a template file, "define", "if", "else", "range" and "with".
+// It is safe for concurrent use, so a single TemplateCoverage can be shared by all the tests of a package.
+//
+// e.g.
+//
+//  var coverage = helmut.NewTemplateCoverage()
+//
+//  func TestMain(m *testing.M) {
+//  	code := m.Run()
+//
+//  	_ = coverage.WriteSummary(os.Stdout)
+//  	_ = coverage.WriteHTML("coverage")
+//
+//  	os.Exit(code)
+//  }
+//
+type TemplateCoverage struct {
+	mu    sync.Mutex
+	files []*coverageFile
+
+	// index is the files keyed by the name and the source of the template,
+	// because different charts may have the templates of the same name.
+	index map[string]*coverageFile
+}
+
+// NewTemplateCoverage creates and returns a new TemplateCoverage.
+// Specify it to the Renderer by WithTemplateCoverage.
+func NewTemplateCoverage() *TemplateCoverage {
+	return &TemplateCoverage{index: make(map[string]*coverageFile)}
+}
+
+// TemplateBlock is a block of a template and the number of times it is executed.
+type TemplateBlock struct {
+	// Start is the location of the beginning of the block.
+	Start SourceLocation
+
+	// End is the location just after the end of the block.
+	End SourceLocation
+
+	// Count is the number of times the block is executed.
+	Count int
+}
+
+// TemplateFileCoverage is the coverage of a template file.
+type TemplateFileCoverage struct {
+	// File is the name of the template prefixed with the names of the chart and its parents.
+	// e.g. "test-chart/templates/deployment.yaml"
+	File string
+
+	// Blocks are the blocks of the template in the order of the locations.
+	Blocks []TemplateBlock
+}
+
+// Covered returns the number of the blocks executed at least once.
+func (c TemplateFileCoverage) Covered() int {
+	covered := 0
+
+	for _, b := range c.Blocks {
+		if b.Count > 0 {
+			covered++
+		}
+	}
+
+	return covered
+}
+
+// Percent returns the percentage of the blocks executed at least once.
+func (c TemplateFileCoverage) Percent() float64 {
+	return percent(c.Covered(), len(c.Blocks))
+}
+
+// Files returns the coverage of the templates rendered so far in the order of the names.
+func (c *TemplateCoverage) Files() []TemplateFileCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make([]TemplateFileCoverage, 0, len(c.files))
+
+	for _, f := range c.files {
+		files = append(files, f.coverage())
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].File < files[j].File
+	})
+
+	return files
+}
+
+// Percent returns the percentage of the blocks executed at least once in all the templates.
+func (c *TemplateCoverage) Percent() float64 {
+	covered, total := 0, 0
+
+	for _, f := range c.Files() {
+		covered += f.Covered()
+		total += len(f.Blocks)
+	}
+
+	return percent(covered, total)
+}
+
+// WriteSummary writes the coverage of each template and the total in text.
+//
+// e.g.
+//
+//  test-chart/templates/deployment.yaml:  80.0% (4/5)
+//  test-chart/templates/service.yaml:     100.0% (2/2)
+//  total:                                 85.7% (6/7)
+//
+func (c *TemplateCoverage) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	covered, total := 0, 0
+
+	for _, f := range c.Files() {
+		fmt.Fprintf(tw, "%s:\t%.1f%% (%d/%d)\n", f.File, f.Percent(), f.Covered(), len(f.Blocks))
+
+		covered += f.Covered()
+		total += len(f.Blocks)
+	}
+
+	fmt.Fprintf(tw, "total:\t%.1f%% (%d/%d)\n", percent(covered, total), covered, total)
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write coverage summary: %w", err)
+	}
+
+	return nil
+}
+
+// WriteHTML writes the source of each template with the executed blocks highlighted
+// to "<dir>/<template name>.html", and the list of the templates to "<dir>/index.html".
+func (c *TemplateCoverage) WriteHTML(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := append([]*coverageFile(nil), c.files...)
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	var index strings.Builder
+
+	index.WriteString(htmlHeader("Template coverage"))
+	index.WriteString("<h1>Template coverage</h1>\n<ul>\n")
+
+	seen := make(map[string]int)
+
+	for _, f := range files {
+		// The templates of the same name in different charts are written to different files.
+		seen[f.name]++
+
+		name := f.name
+		if seen[f.name] > 1 {
+			name += "~" + strconv.Itoa(seen[f.name])
+		}
+
+		page := filepath.Join(dir, filepath.FromSlash(name)+".html")
+
+		if err := os.MkdirAll(filepath.Dir(page), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if err := os.WriteFile(page, []byte(f.html()), 0o644); err != nil { //nolint:gosec // The report is not secret.
+			return fmt.Errorf("failed to write coverage of %s: %w", f.name, err)
+		}
+
+		cov := f.coverage()
+
+		fmt.Fprintf(&index, "<li><a href=\"%s\">%s</a> %.1f%% (%d/%d)</li>\n",
+			html.EscapeString(name+".html"), html.EscapeString(f.name), cov.Percent(), cov.Covered(), len(cov.Blocks))
+	}
+
+	index.WriteString("</ul>\n</body>\n</html>\n")
+
+	//nolint:gosec // The report is not secret.
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(index.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write coverage index: %w", err)
+	}
+
+	return nil
+}
+
+// record renders the instrumented templates of the release again to record the executed blocks.
+// The release is rendered with the same values and capabilities as "helm template".
+func (c *TemplateCoverage) record(rendered *release.Release, opts *option) error {
+	instrumented, err := c.instrumentChart(rendered.Chart)
+	if err != nil {
+		return err
+	}
+
+	values, err := renderValues(rendered, instrumented, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := engine.RenderWithClientProvider(instrumented, values, coverageClientProvider{coverage: c}); err != nil {
+		return fmt.Errorf("failed to render instrumented templates: %w", err)
+	}
+
+	return nil
+}
+
+// instrumentChart returns a copy of the chart and its subcharts whose templates are instrumented.
+func (c *TemplateCoverage) instrumentChart(ch *chart.Chart) (*chart.Chart, error) {
+	instrumented := *ch
+	instrumented.Templates = make([]*chart.File, 0, len(ch.Templates))
+
+	for _, t := range ch.Templates {
+		f, err := c.file(path.Join(ch.ChartFullPath(), t.Name), string(t.Data))
+		if err != nil {
+			return nil, err
+		}
+
+		instrumented.Templates = append(instrumented.Templates, &chart.File{Name: t.Name, Data: []byte(f.instrumented)})
+	}
+
+	dependencies := make([]*chart.Chart, 0, len(ch.Dependencies()))
+
+	for _, dep := range ch.Dependencies() {
+		d, err := c.instrumentChart(dep)
+		if err != nil {
+			return nil, err
+		}
+
+		dependencies = append(dependencies, d)
+	}
+
+	instrumented.SetDependencies(dependencies...)
+
+	return &instrumented, nil
+}
+
+// file returns the instrumented template, which is instrumented once for each source.
+func (c *TemplateCoverage) file(name, source string) (*coverageFile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := name + "\x00" + source
+
+	if f, ok := c.index[key]; ok {
+		return f, nil
+	}
+
+	f := &coverageFile{name: name, source: source}
+
+	var err error
+
+	f.instrumented, f.blocks, err = instrumentTemplate(name, source, len(c.files))
+	if err != nil {
+		return nil, err
+	}
+
+	c.files = append(c.files, f)
+	c.index[key] = f
+
+	return f, nil
+}
+
+// hit increments the count of the block identified by the name looked up by the instrumented template.
+func (c *TemplateCoverage) hit(id string) {
+	fileID, blockID, ok := strings.Cut(id, ".")
+	if !ok {
+		return
+	}
+
+	i, err := strconv.Atoi(fileID)
+	if err != nil {
+		return
+	}
+
+	j, err := strconv.Atoi(blockID)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i < len(c.files) && j < len(c.files[i].blocks) {
+		c.files[i].blocks[j].count++
+	}
+}
+
+// coverageFile is an instrumented template.
+type coverageFile struct {
+	name         string
+	source       string
+	instrumented string
+	blocks       []coverageBlock
+}
+
+// coverageBlock is a block of a template.
+type coverageBlock struct {
+	// start and end are the offsets of the block in the source.
+	start int
+	end   int
+
+	// trim is true if the action before the block trims the following spaces,
+	// so the marker of the block must trim them instead.
+	trim bool
+
+	count int
+}
+
+// coverage returns the coverage of the file. The caller must hold the lock.
+func (f *coverageFile) coverage() TemplateFileCoverage {
+	cov := TemplateFileCoverage{File: f.name, Blocks: make([]TemplateBlock, 0, len(f.blocks))}
+
+	for _, b := range f.blocks {
+		cov.Blocks = append(cov.Blocks, TemplateBlock{
+			Start: offsetLocation(f.name, f.source, b.start),
+			End:   offsetLocation(f.name, f.source, b.end),
+			Count: b.count,
+		})
+	}
+
+	return cov
+}
+
+// html returns the page of the source with the blocks highlighted. The caller must hold the lock.
+func (f *coverageFile) html() string {
+	// The inner blocks come after the outer blocks, so they take precedence.
+	states := make([]int, len(f.source))
+	for i := range states {
+		states[i] = -1
+	}
+
+	for _, b := range f.blocks {
+		state := 0
+		if b.count > 0 {
+			state = 1
+		}
+
+		for i := b.start; i < b.end; i++ {
+			states[i] = state
+		}
+	}
+
+	cov := f.coverage()
+
+	var page strings.Builder
+
+	page.WriteString(htmlHeader(f.name))
+	fmt.Fprintf(&page, "<h1>%s</h1>\n<p>%.1f%% (%d/%d) <span class=\"cov1\">covered</span> <span class=\"cov0\">not covered</span></p>\n<pre>",
+		html.EscapeString(f.name), cov.Percent(), cov.Covered(), len(cov.Blocks))
+
+	for start := 0; start < len(f.source); {
+		end := start + 1
+		for end < len(f.source) && states[end] == states[start] {
+			end++
+		}
+
+		text := html.EscapeString(f.source[start:end])
+
+		if states[start] < 0 {
+			page.WriteString(text)
+		} else {
+			fmt.Fprintf(&page, "<span class=\"cov%d\">%s</span>", states[start], text)
+		}
+
+		start = end
+	}
+
+	page.WriteString("</pre>\n</body>\n</html>\n")
+
+	return page.String()
+}
+
+// htmlHeader returns the beginning of the HTML page of the coverage report.
+func htmlHeader(title string) string {
+	return `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>` + html.EscapeString(title) + `</title>
+<style>
+body { font-family: monospace; }
+.cov0 { background-color: #fdd; }
+.cov1 { background-color: #dfd; }
+</style>
+</head>
+<body>
+`
+}
+
+// percent returns the percentage of n in total.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) / float64(total) * 100
+}
+
+// offsetLocation returns the location of the offset in the source.
+// The line and the column start from 1.
+func offsetLocation(file, source string, offset int) SourceLocation {
+	before := source[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+
+	return SourceLocation{File: file, Line: line, Column: column}
+}
+
+// instrumentTemplate inserts the markers at the beginning of the blocks of the template
+// and returns the instrumented source with the blocks.
+// The marker is a "lookup" of the block whose result is discarded, so the output of the template is not changed.
+// The id identifies the template in the names of the looked up resources.
+func instrumentTemplate(name, source string, id int) (string, []coverageBlock, error) {
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+
+	trees := make(map[string]*parse.Tree)
+
+	if _, err := tree.Parse(source, "{{", "}}", trees); err != nil {
+		return "", nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	i := &templateInstrumenter{source: source}
+
+	// The partials are not executed by themselves.
+	if !strings.HasPrefix(path.Base(name), "_") {
+		i.blocks = append(i.blocks, coverageBlock{start: 0, end: len(source)})
+	}
+
+	if tree.Root != nil {
+		i.nodes(tree.Root.Nodes)
+	}
+
+	for _, t := range trees {
+		if t != tree && t.Root != nil {
+			i.list(t.Root)
+		}
+	}
+
+	sort.Slice(i.blocks, func(x, y int) bool {
+		return i.blocks[x].start < i.blocks[y].start
+	})
+
+	var b strings.Builder
+
+	previous := 0
+
+	for j, block := range i.blocks {
+		b.WriteString(source[previous:block.start])
+
+		fmt.Fprintf(&b, `{{ if lookup %q %q "" "%d.%d" }}{{ end`, coverageAPIVersion, coverageKind, id, j)
+
+		if block.trim {
+			b.WriteString(" -}}")
+		} else {
+			b.WriteString(" }}")
+		}
+
+		previous = block.start
+	}
+
+	b.WriteString(source[previous:])
+
+	return b.String(), i.blocks, nil
+}
+
+// templateInstrumenter finds the blocks of a template.
+type templateInstrumenter struct {
+	source string
+	blocks []coverageBlock
+}
+
+// nodes finds the blocks in the nodes.
+func (i *templateInstrumenter) nodes(nodes []parse.Node) {
+	for _, node := range nodes {
+		if b := branchNode(node); b != nil {
+			i.list(b.List)
+			i.list(b.ElseList)
+		}
+	}
+}
+
+// list adds the list as a block unless it is the "else" of "else if" and "else with",
+// whose nested branch has its own blocks.
+func (i *templateInstrumenter) list(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	if start, trim, ok := i.blockStart(list); ok {
+		i.blocks = append(i.blocks, coverageBlock{start: start, end: i.listEnd(list, start), trim: trim})
+	}
+
+	i.nodes(list.Nodes)
+}
+
+// blockStart returns the offset just after the action that begins the list,
+// and whether the action trims the following spaces.
+func (i *templateInstrumenter) blockStart(list *parse.ListNode) (int, bool, bool) {
+	p := int(list.Pos)
+
+	// The spaces trimmed by the action are not a part of the list.
+	for p > 0 && isTemplateSpace(i.source[p-1]) {
+		p--
+	}
+
+	if !strings.HasSuffix(i.source[:p], "}}") {
+		return 0, false, false
+	}
+
+	trim := p >= 4 && i.source[p-3] == '-' && isTemplateSpace(i.source[p-4])
+
+	return p, trim, true
+}
+
+// listEnd returns the offset of the action that ends the list.
+func (i *templateInstrumenter) listEnd(list *parse.ListNode, start int) int {
+	from := start
+
+	if len(list.Nodes) != 0 {
+		from = i.nodeEnd(list.Nodes[len(list.Nodes)-1])
+	}
+
+	if end := strings.Index(i.source[from:], "{{"); end >= 0 {
+		return from + end
+	}
+
+	return len(i.source)
+}
+
+// nodeEnd returns the offset just after the node.
+func (i *templateInstrumenter) nodeEnd(node parse.Node) int {
+	if text, ok := node.(*parse.TextNode); ok {
+		return int(text.Pos) + len(text.Text)
+	}
+
+	b := branchNode(node)
+	if b == nil {
+		return i.actionEnd(int(node.Position()))
+	}
+
+	last := b.List
+
+	if b.ElseList != nil {
+		if _, _, ok := i.blockStart(b.ElseList); !ok && len(b.ElseList.Nodes) != 0 {
+			// The nested branch of "else if" shares the "end" action.
+			return i.nodeEnd(b.ElseList.Nodes[len(b.ElseList.Nodes)-1])
+		}
+
+		last = b.ElseList
+	}
+
+	return i.actionEnd(i.listEnd(last, int(last.Pos)))
+}
+
+// actionEnd returns the offset just after the action containing the offset.
+func (i *templateInstrumenter) actionEnd(offset int) int {
+	s := i.source
+
+	for p := offset; p+1 < len(s); p++ {
+		switch s[p] {
+		case '"', '\'':
+			quote := s[p]
+
+			for p++; p < len(s) && s[p] != quote; p++ {
+				if s[p] == '\\' {
+					p++
+				}
+			}
+		case '`':
+			if end := strings.IndexByte(s[p+1:], '`'); end >= 0 {
+				p += end + 1
+			}
+		case '}':
+			if s[p+1] == '}' {
+				return p + 2
+			}
+		}
+	}
+
+	return len(s)
+}
+
+// branchNode returns the branch of "if", "range" and "with", or nil for the other nodes.
+func branchNode(node parse.Node) *parse.BranchNode {
+	switch n := node.(type) {
+	case *parse.IfNode:
+		return &n.BranchNode
+	case *parse.RangeNode:
+		return &n.BranchNode
+	case *parse.WithNode:
+		return &n.BranchNode
+	}
+
+	return nil
+}
+
+// isTemplateSpace returns true if the character is a space trimmed by the actions.
+func isTemplateSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// coverageClientProvider provides the clients of the "lookup" function for the instrumented templates.
+// Every object is not found, which is the same as the "lookup" of "helm template".
+type coverageClientProvider struct {
+	coverage *TemplateCoverage
+}
+
+// GetClientFor implements engine.ClientProvider.
+func (p coverageClientProvider) GetClientFor(apiVersion, kind string) (dynamic.NamespaceableResourceInterface, bool, error) {
+	return &coverageClient{coverage: p.coverage, marker: apiVersion == coverageAPIVersion && kind == coverageKind}, false, nil
+}
+
+// coverageClient is a client that records the lookups of the markers.
+type coverageClient struct {
+	dynamic.NamespaceableResourceInterface
+
+	coverage *TemplateCoverage
+	marker   bool
+}
+
+// Get records the block if the object is a marker, and returns the not found error.
+func (c *coverageClient) Get(
+	_ context.Context,
+	name string,
+	_ metav1.GetOptions,
+	_ ...string,
+) (*unstructured.Unstructured, error) {
+	if c.marker {
+		c.coverage.hit(name)
+	}
+
+	return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+}
+
+// List returns the not found error.
+func (c *coverageClient) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return nil, apierrors.NewNotFound(schema.GroupResource{}, "")
+}
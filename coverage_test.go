@@ -0,0 +1,246 @@
+package helmut_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTemplateCoverage(t *testing.T) {
+	t.Parallel()
+
+	const (
+		helpers   = "coverage-chart/templates/_helpers.tpl"
+		configMap = "coverage-chart/templates/configmap.yaml"
+	)
+
+	tests := []struct {
+		name    string
+		renders [][]helmut.Option
+		// want is the counts of the blocks of each template.
+		want map[string][]int
+	}{
+		{
+			name:    "default values",
+			renders: [][]helmut.Option{nil},
+			want: map[string][]int{
+				// define, if, else
+				helpers: {1, 0, 1},
+				// file, with, if, else if, else, range, range else
+				configMap: {1, 0, 0, 0, 1, 0, 1},
+			},
+		},
+		{
+			name: "accumulated across renders",
+			renders: [][]helmut.Option{
+				nil,
+				{helmut.WithSet("mode=debug", "nameOverride=foo", "labels.app=foo")},
+				{helmut.WithSet("mode=quiet", "items={a,b}")},
+			},
+			want: map[string][]int{
+				helpers:   {3, 1, 2},
+				configMap: {3, 1, 1, 1, 1, 2, 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			coverage := helmut.NewTemplateCoverage()
+			renderer := helmut.New(helmut.WithTemplateCoverage(coverage))
+
+			for _, options := range tt.renders {
+				if _, err := renderer.RenderTemplates("foo", "testdata/coverage-chart", options...); err != nil {
+					t.Fatalf("render error: %s", err)
+				}
+			}
+
+			got := make(map[string][]int)
+
+			for _, f := range coverage.Files() {
+				for _, b := range f.Blocks {
+					got[f.File] = append(got[f.File], b.Count)
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("coverage mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemplateCoverageSubcharts(t *testing.T) {
+	t.Parallel()
+
+	coverage := helmut.NewTemplateCoverage()
+	renderer := helmut.New(helmut.WithTemplateCoverage(coverage))
+
+	_, err := renderer.RenderTemplates("foo", "testdata/umbrella-chart",
+		helmut.WithDependencyBuild(),
+		helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart"))
+	if err != nil {
+		t.Fatalf("render error: %s", err)
+	}
+
+	var files []string
+
+	for _, f := range coverage.Files() {
+		files = append(files, f.File)
+	}
+
+	want := "umbrella-chart/charts/test-chart/templates/deployment.yaml"
+
+	if !strings.Contains(strings.Join(files, "\n"), want) {
+		t.Errorf("coverage of %s not found in %v", want, files)
+	}
+}
+
+func TestTemplateCoverageReport(t *testing.T) {
+	t.Parallel()
+
+	coverage := helmut.NewTemplateCoverage()
+	renderer := helmut.New(helmut.WithTemplateCoverage(coverage))
+
+	if _, err := renderer.RenderTemplates("foo", "testdata/coverage-chart"); err != nil {
+		t.Fatalf("render error: %s", err)
+	}
+
+	var summary bytes.Buffer
+
+	if err := coverage.WriteSummary(&summary); err != nil {
+		t.Fatalf("write summary error: %s", err)
+	}
+
+	want := `coverage-chart/templates/_helpers.tpl:    66.7% (2/3)
+coverage-chart/templates/configmap.yaml:  42.9% (3/7)
+total:                                    50.0% (5/10)
+`
+
+	if diff := cmp.Diff(want, summary.String()); diff != "" {
+		t.Errorf("summary mismatch (-want +got):\n%s", diff)
+	}
+
+	dir := t.TempDir()
+
+	if err := coverage.WriteHTML(dir); err != nil {
+		t.Fatalf("write HTML error: %s", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "coverage-chart", "templates", "configmap.yaml.html"))
+	if err != nil {
+		t.Fatalf("failed to read the page: %s", err)
+	}
+
+	for _, s := range []string{
+		`<span class="cov0">` + "\n  level: debug\n  </span>",
+		`<span class="cov1">{{- else }}` + "\n  level: info",
+	} {
+		if !strings.Contains(string(page), s) {
+			t.Errorf("page does not contain %q:\n%s", s, page)
+		}
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("failed to read the index: %s", err)
+	}
+
+	if !strings.Contains(string(index), `<a href="coverage-chart/templates/configmap.yaml.html">`) {
+		t.Errorf("index does not link to the page:\n%s", index)
+	}
+}
+
+func TestInstrumentTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name:   "spaces are kept",
+			source: "a:\n{{ if .ok }}\n  b: 1\n{{ else }}\n  b: 2\n{{ end }}\n",
+		},
+		{
+			name:   "spaces are trimmed",
+			source: "a:\n{{- if .ok -}}\n  b: 1\n{{- else -}}\n  b: 2\n{{- end -}}\n",
+		},
+		{
+			name:   "spaces before a trimming action",
+			source: "a:\n{{ if .ok }}\n  {{- .ok }}\n{{ else }}  {{- \"}}\" }}{{ end }}",
+		},
+		{
+			name:   "else if and else with",
+			source: "{{ if not .ok }}a{{ else if .ok -}}\n b {{- end }}{{ with .none }}c{{ else with .ok }}d{{ end }}",
+		},
+		{
+			name:   "range",
+			source: "{{ range $i, $v := .items -}}\n  {{ $i }}: {{ $v }}\n{{ else }}none{{ end }}",
+		},
+		{
+			name:   "define",
+			source: "{{- define \"name\" -}}\n  {{ .ok }}\n{{- end }}\n{{ template \"name\" . }}",
+		},
+		{
+			name:   "empty blocks",
+			source: "{{ if .ok }}{{ else }}{{ end }}{{ with .ok }} {{ end }}",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			instrumented, err := helmut.InstrumentTemplate("test.yaml", tt.source)
+			if err != nil {
+				t.Fatalf("instrument error: %s", err)
+			}
+
+			for _, data := range []map[string]interface{}{
+				{"ok": true, "items": []string{"x", "y"}},
+				{"ok": false},
+			} {
+				want := executeTemplate(t, tt.source, data)
+				got := executeTemplate(t, instrumented, data)
+
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("output mismatch (-want +got):\n%s\ninstrumented:\n%s", diff, instrumented)
+				}
+			}
+		})
+	}
+}
+
+func executeTemplate(t *testing.T, source string, data interface{}) string {
+	t.Helper()
+
+	lookup := func(apiVersion, kind, namespace, name string) map[string]interface{} {
+		return map[string]interface{}{}
+	}
+
+	tmpl, err := template.New("test.yaml").Funcs(template.FuncMap{"lookup": lookup}).Parse(source)
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+
+	var b strings.Builder
+
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatalf("execute error: %s", err)
+	}
+
+	return b.String()
+}
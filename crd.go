@@ -0,0 +1,404 @@
+package helmut
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/defaulting"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CustomResourceReport is the report of the validation of the custom resources against the schemas of their CRDs.
+type CustomResourceReport struct {
+	// Violations is the violations of the custom resources sorted by the field paths, keyed by the objects.
+	Violations map[ObjectKey][]OpenAPIViolation
+
+	// PrunedFields is the paths of the fields that are not defined in the schemas and dropped by the API server,
+	// keyed by the objects.
+	// e.g. "spec.unknown", "spec.items[0].unknown"
+	PrunedFields map[ObjectKey][]string
+
+	// UnservedVersions is a list of custom resources whose versions are not served by the CRDs, sorted by the keys.
+	UnservedVersions []ObjectKey
+}
+
+// Empty returns true if there is neither a violation, a pruned field nor an unserved version.
+func (r *CustomResourceReport) Empty() bool {
+	return len(r.Violations) == 0 && len(r.PrunedFields) == 0 && len(r.UnservedVersions) == 0
+}
+
+// CustomResourceSchemas is the schemas of the registered CustomResourceDefinitions,
+// used to validate the custom resources like the API server.
+type CustomResourceSchemas struct {
+	// versions is the schemas of the versions of the CRDs keyed by the group and kind.
+	versions map[schema.GroupKind]map[string]*customResourceVersion
+}
+
+// customResourceVersion is the schema of a version of a CRD.
+type customResourceVersion struct {
+	served bool
+
+	// structural is used for pruning and defaulting, and schema is used for validation.
+	// They are nil if the version has no schema.
+	structural *structuralschema.Structural
+	schema     *jsonschema.Schema
+}
+
+// NewCustomResourceSchemas returns the schemas without CRDs.
+func NewCustomResourceSchemas() *CustomResourceSchemas {
+	return &CustomResourceSchemas{
+		versions: make(map[schema.GroupKind]map[string]*customResourceVersion),
+	}
+}
+
+// Register registers the CRDs.
+// The CRD registered later replaces the CRD of the same group and kind.
+func (s *CustomResourceSchemas) Register(crds ...*apiextensionsv1.CustomResourceDefinition) error {
+	for _, crd := range crds {
+		versions := make(map[string]*customResourceVersion, len(crd.Spec.Versions))
+
+		for _, v := range crd.Spec.Versions {
+			version := &customResourceVersion{served: v.Served}
+
+			if v.Schema != nil && v.Schema.OpenAPIV3Schema != nil {
+				var err error
+
+				version.structural, version.schema, err = compileCustomResourceSchema(crd.Name, v.Name, v.Schema.OpenAPIV3Schema)
+				if err != nil {
+					return fmt.Errorf("failed to compile the schema of %s %s: %w", crd.Name, v.Name, err)
+				}
+			}
+
+			versions[v.Name] = version
+		}
+
+		s.versions[schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}] = versions
+	}
+
+	return nil
+}
+
+// RegisterManifests registers the CRDs in the manifests,
+// such as the CRDs rendered by RenderTemplates with WithIncludeCRDs.
+// The CRDs of apiextensions.k8s.io/v1beta1 are converted to v1.
+//
+// e.g.
+//
+//  manifests, err := r.RenderTemplates(releaseName, chartPath, helmut.WithIncludeCRDs())
+//
+//  crds := helmut.NewCustomResourceSchemas()
+//  err = crds.RegisterManifests(manifests)
+//
+//  report, err := crds.Validate(manifests)
+//
+func (s *CustomResourceSchemas) RegisterManifests(manifests *Manifests) error {
+	keys := manifests.GetKeys()
+	SortObjectKeys(keys)
+
+	for _, key := range keys {
+		if key.Group != apiextensionsv1.GroupName || key.Kind != "CustomResourceDefinition" {
+			continue
+		}
+
+		object, _ := manifests.Load(key)
+
+		crd, err := toCustomResourceDefinition(object)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", key, err)
+		}
+
+		if err := s.Register(crd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegisterFiles registers the CRDs in the YAML or JSON files, or in the files in the directories recursively.
+// The files in the directories that have no CRD are ignored.
+func (s *CustomResourceSchemas) RegisterFiles(paths ...string) error {
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("failed to read CRD files: %w", err)
+		}
+
+		if !info.IsDir() {
+			if err := s.registerFile(p, true); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err = filepath.WalkDir(p, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			switch filepath.Ext(name) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					return s.registerFile(name, false)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read CRD directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// registerFile registers the CRDs in the file.
+// An error is returned if the file has no CRD and the CRD is required.
+func (s *CustomResourceSchemas) registerFile(name string, required bool) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read CRD file: %w", err)
+	}
+
+	manifests, err := New().SplitManifests(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse CRD file %s: %w", name, err)
+	}
+
+	found := false
+
+	for _, key := range manifests.GetKeys() {
+		if key.Group == apiextensionsv1.GroupName && key.Kind == "CustomResourceDefinition" {
+			found = true
+
+			break
+		}
+	}
+
+	if !found {
+		if required {
+			return fmt.Errorf("no CustomResourceDefinition found in %s", name)
+		}
+
+		return nil
+	}
+
+	return s.RegisterManifests(manifests)
+}
+
+// toCustomResourceDefinition converts the object to the CRD of apiextensions.k8s.io/v1.
+func toCustomResourceDefinition(object runtime.Object) (*apiextensionsv1.CustomResourceDefinition, error) {
+	switch crd := object.(type) {
+	case *apiextensionsv1.CustomResourceDefinition:
+		return crd, nil
+	case *apiextensionsv1beta1.CustomResourceDefinition:
+		internal := &apiextensions.CustomResourceDefinition{}
+		if err := apiextensionsv1beta1.Convert_v1beta1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(
+			crd, internal, nil); err != nil {
+			return nil, err
+		}
+
+		converted := &apiextensionsv1.CustomResourceDefinition{}
+		if err := apiextensionsv1.Convert_apiextensions_CustomResourceDefinition_To_v1_CustomResourceDefinition(
+			internal, converted, nil); err != nil {
+			return nil, err
+		}
+
+		return converted, nil
+	default:
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+		if err != nil {
+			return nil, err
+		}
+
+		if u["apiVersion"] != apiextensionsv1.SchemeGroupVersion.String() {
+			return nil, fmt.Errorf("unsupported CRD version %v", u["apiVersion"])
+		}
+
+		converted := &apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, converted); err != nil {
+			return nil, err
+		}
+
+		return converted, nil
+	}
+}
+
+// compileCustomResourceSchema returns the structural schema and the compiled schema of the version of the CRD.
+func compileCustomResourceSchema(
+	name, version string,
+	props *apiextensionsv1.JSONSchemaProps,
+) (*structuralschema.Structural, *jsonschema.Schema, error) {
+	internal := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(props, internal, nil); err != nil {
+		return nil, nil, err
+	}
+
+	structural, err := structuralschema.NewStructural(internal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the schema is not structural: %w", err)
+	}
+
+	data, err := json.Marshal(props)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	url := "file:///" + name + "/" + version + ".json"
+
+	compiler := newOpenAPICompiler()
+
+	if err := compiler.AddResource(url, convertOpenAPISchema(doc)); err != nil {
+		return nil, nil, err
+	}
+
+	sch, err := compiler.Compile(url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return structural, sch, nil
+}
+
+// Validate validates the custom resources of the registered CRDs in the manifests against the schemas
+// of their versions.
+// The unknown fields are pruned and the defaults are applied before the validation like the API server,
+// and the pruned fields are reported as PrunedFields.
+// The objects of the kinds without a registered CRD are not validated.
+// The validation rules of "x-kubernetes-validations" are not evaluated.
+//
+// e.g.
+//
+//  crds := helmut.NewCustomResourceSchemas()
+//  err := crds.RegisterFiles("testdata/crds")
+//
+//  report, err := crds.Validate(manifests)
+//
+func (s *CustomResourceSchemas) Validate(manifests *Manifests) (*CustomResourceReport, error) {
+	report := &CustomResourceReport{}
+
+	keys := manifests.GetKeys()
+	SortObjectKeys(keys)
+
+	for _, key := range keys {
+		versions, ok := s.versions[schema.GroupKind{Group: key.Group, Kind: key.Kind}]
+		if !ok {
+			continue
+		}
+
+		version, ok := versions[key.Version]
+		if !ok || !version.served {
+			report.UnservedVersions = append(report.UnservedVersions, key)
+
+			continue
+		}
+
+		if version.schema == nil {
+			continue
+		}
+
+		object, _ := manifests.Load(key)
+
+		instance, pruned, err := version.apply(key, object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply the schema of %s: %w", key, err)
+		}
+
+		if len(pruned) != 0 {
+			if report.PrunedFields == nil {
+				report.PrunedFields = make(map[ObjectKey][]string)
+			}
+
+			report.PrunedFields[key] = pruned
+		}
+
+		violations, err := validateInstance(version.schema, instance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate %s: %w", key, err)
+		}
+
+		if len(violations) == 0 {
+			continue
+		}
+
+		if report.Violations == nil {
+			report.Violations = make(map[ObjectKey][]OpenAPIViolation)
+		}
+
+		report.Violations[key] = violations
+	}
+
+	return report, nil
+}
+
+// PruneAndDefault replaces the custom resources of the registered CRDs in the manifests
+// with the objects whose unknown fields are pruned and defaults are applied,
+// which are the objects stored by the API server.
+// The custom resources of the versions that are not served are left as they are.
+func (s *CustomResourceSchemas) PruneAndDefault(manifests *Manifests) error {
+	for _, key := range manifests.GetKeys() {
+		version, ok := s.versions[schema.GroupKind{Group: key.Group, Kind: key.Kind}][key.Version]
+		if !ok || !version.served || version.structural == nil {
+			continue
+		}
+
+		object, _ := manifests.Load(key)
+
+		instance, _, err := version.apply(key, object)
+		if err != nil {
+			return fmt.Errorf("failed to apply the schema of %s: %w", key, err)
+		}
+
+		applied, err := manifests.GetScheme().New(key.GetGroupVersionKind())
+		if err != nil {
+			applied = &unstructured.Unstructured{Object: instance}
+		} else if err := runtime.DefaultUnstructuredConverter.FromUnstructured(instance, applied); err != nil {
+			return fmt.Errorf("failed to convert %s from unstructured: %w", key, err)
+		}
+
+		manifests.Store(key, applied)
+	}
+
+	return nil
+}
+
+// apply returns the unstructured object whose unknown fields are pruned and defaults are applied,
+// and the paths of the pruned fields.
+func (v *customResourceVersion) apply(key ObjectKey, object runtime.Object) (map[string]interface{}, []string, error) {
+	// The unstructured content of an unstructured object is not copied by the converter.
+	instance, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object.DeepCopyObject())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert object to unstructured: %w", err)
+	}
+
+	gvk := key.GetGroupVersionKind()
+	instance["apiVersion"] = gvk.GroupVersion().String()
+	instance["kind"] = gvk.Kind
+
+	pruned := pruning.PruneWithOptions(instance, v.structural, true,
+		structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true})
+
+	defaulting.PruneNonNullableNullsWithoutDefaults(instance, v.structural)
+	defaulting.Default(instance, v.structural)
+
+	return instance, pruned, nil
+}
@@ -0,0 +1,324 @@
+package helmut_test
+
+import (
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+)
+
+func TestCustomResourceSchemasValidate(t *testing.T) {
+	t.Parallel()
+
+	var (
+		widgetKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+		alphaWidgetKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "example.com", Version: "v1alpha1", Kind: "Widget"})
+		betaWidgetKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "example.com", Version: "v1beta1", Kind: "Widget"})
+		gadgetKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "example.com", Version: "v1beta1", Kind: "Gadget"})
+		sprocketKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Sprocket"})
+	)
+
+	object := func(key helmut.ObjectKey, spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": key.GetGroupVersionKind().GroupVersion().String(),
+			"kind":       key.Kind,
+			"metadata":   map[string]interface{}{"name": key.Name, "namespace": key.Namespace},
+			"spec":       spec,
+		}}
+	}
+
+	tests := []struct {
+		name           string
+		objects        []*unstructured.Unstructured
+		wantViolations map[helmut.ObjectKey][]helmut.OpenAPIViolation
+		wantPruned     map[helmut.ObjectKey][]string
+		wantUnserved   []helmut.ObjectKey
+	}{
+		{
+			name: "valid",
+			objects: []*unstructured.Unstructured{
+				object(widgetKey, map[string]interface{}{
+					"name":        "foo",
+					"description": nil,
+					"ports":       []interface{}{map[string]interface{}{"port": "http"}, map[string]interface{}{"port": int64(80)}},
+					"config":      map[string]interface{}{"anything": true},
+				}),
+				object(gadgetKey, map[string]interface{}{"enabled": true}),
+				object(sprocketKey, map[string]interface{}{"unknown": true}),
+			},
+		},
+		{
+			name: "invalid",
+			objects: []*unstructured.Unstructured{
+				object(widgetKey, map[string]interface{}{
+					"size":  int64(0),
+					"color": "green",
+					"ports": []interface{}{map[string]interface{}{"port": true}},
+				}),
+				object(gadgetKey, map[string]interface{}{"enabled": "yes"}),
+			},
+			wantViolations: map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+				widgetKey: {
+					{Field: "spec.color", Rule: "enum", Message: "value must be one of 'red', 'blue'"},
+					{Field: "spec.name", Rule: "required", Message: "missing property 'name'"},
+					{Field: "spec.ports[0].port", Rule: "anyOf", Message: "'anyOf' failed"},
+					{Field: "spec.size", Rule: "minimum", Message: "minimum: got 0, want 1"},
+				},
+				gadgetKey: {
+					{Field: "spec.enabled", Rule: "type", Message: "got string, want boolean"},
+				},
+			},
+		},
+		{
+			name: "pruned fields",
+			objects: []*unstructured.Unstructured{
+				object(widgetKey, map[string]interface{}{
+					"name":    "foo",
+					"unknown": "foo",
+					"ports":   []interface{}{map[string]interface{}{"port": int64(80), "unknown": "foo"}},
+				}),
+			},
+			wantPruned: map[helmut.ObjectKey][]string{
+				widgetKey: {"spec.ports[0].unknown", "spec.unknown"},
+			},
+		},
+		{
+			name: "unserved versions",
+			objects: []*unstructured.Unstructured{
+				object(alphaWidgetKey, map[string]interface{}{}),
+				object(betaWidgetKey, map[string]interface{}{}),
+			},
+			wantUnserved: []helmut.ObjectKey{alphaWidgetKey, betaWidgetKey},
+		},
+	}
+
+	crds := helmut.NewCustomResourceSchemas()
+
+	if err := crds.RegisterFiles("testdata/crds"); err != nil {
+		t.Fatalf("failed to register CRDs: %s", err)
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests := helmut.NewManifests()
+
+			for _, o := range tt.objects {
+				key, err := helmut.NewObjectKeyFromObject(o)
+				if err != nil {
+					t.Fatalf("failed to get key: %s", err)
+				}
+
+				manifests.Store(key, o)
+			}
+
+			report, err := crds.Validate(manifests)
+			if err != nil {
+				t.Fatalf("validate error: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.wantViolations, report.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.wantPruned, report.PrunedFields); diff != "" {
+				t.Errorf("pruned fields mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.wantUnserved, report.UnservedVersions); diff != "" {
+				t.Errorf("unserved versions mismatch (-want +got):\n%s", diff)
+			}
+
+			wantEmpty := tt.wantViolations == nil && tt.wantPruned == nil && tt.wantUnserved == nil
+			if got := report.Empty(); got != wantEmpty {
+				t.Errorf("got empty %t, want %t", got, wantEmpty)
+			}
+		})
+	}
+}
+
+func TestCustomResourceSchemasWithIncludeCRDs(t *testing.T) {
+	t.Parallel()
+
+	widgetKey := helmut.NewObjectKey("", "foo",
+		schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+
+	tests := []struct {
+		name           string
+		options        []helmut.Option
+		wantViolations map[helmut.ObjectKey][]helmut.OpenAPIViolation
+		wantSpec       map[string]interface{}
+	}{
+		{
+			name: "default values",
+			wantSpec: map[string]interface{}{
+				"name":  "foo",
+				"size":  int64(3),
+				"color": "red",
+				"ports": []interface{}{
+					map[string]interface{}{"port": "http", "protocol": "TCP"},
+					map[string]interface{}{"port": int64(8080), "protocol": "UDP"},
+				},
+			},
+		},
+		{
+			name:    "invalid values",
+			options: []helmut.Option{helmut.WithSet("widget.size=0", "widget.unknown=foo")},
+			wantViolations: map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+				widgetKey: {{Field: "spec.size", Rule: "minimum", Message: "minimum: got 0, want 1"}},
+			},
+			wantSpec: map[string]interface{}{
+				"name":  "foo",
+				"size":  int64(0),
+				"color": "red",
+				"ports": []interface{}{
+					map[string]interface{}{"port": "http", "protocol": "TCP"},
+					map[string]interface{}{"port": int64(8080), "protocol": "UDP"},
+				},
+			},
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := append([]helmut.Option{helmut.WithIncludeCRDs()}, tt.options...)
+
+			manifests, err := renderer.RenderTemplates("foo", "testdata/crd-chart", options...)
+			if err != nil {
+				t.Fatalf("render error: %s", err)
+			}
+
+			crds := helmut.NewCustomResourceSchemas()
+
+			if err := crds.RegisterManifests(manifests); err != nil {
+				t.Fatalf("failed to register CRDs: %s", err)
+			}
+
+			report, err := crds.Validate(manifests)
+			if err != nil {
+				t.Fatalf("validate error: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.wantViolations, report.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+
+			if err := crds.PruneAndDefault(manifests); err != nil {
+				t.Fatalf("prune and default error: %s", err)
+			}
+
+			object, ok := manifests.Load(widgetKey)
+			if !ok {
+				t.Fatal("widget not found")
+			}
+
+			if diff := cmp.Diff(tt.wantSpec, object.(*unstructured.Unstructured).Object["spec"]); diff != "" {
+				t.Errorf("spec mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCustomResourceSchemasRegister(t *testing.T) {
+	t.Parallel()
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "Widget", Plural: "widgets"},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name:    "v1",
+				Served:  true,
+				Storage: true,
+				Schema: &apiextensionsv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"spec": {
+								Type:     "object",
+								Required: []string{"name"},
+								Properties: map[string]apiextensionsv1.JSONSchemaProps{
+									"name": {Type: "string", MaxLength: pointer.Int64Ptr(3)},
+								},
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+
+	crds := helmut.NewCustomResourceSchemas()
+
+	if err := crds.Register(crd); err != nil {
+		t.Fatalf("failed to register CRD: %s", err)
+	}
+
+	key := helmut.NewObjectKey("", "foo", schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+
+	manifests := helmut.NewManifests()
+	manifests.Store(key, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "foo"},
+		"spec":       map[string]interface{}{"name": "widget"},
+	}})
+
+	report, err := crds.Validate(manifests)
+	if err != nil {
+		t.Fatalf("validate error: %s", err)
+	}
+
+	want := map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+		key: {{Field: "spec.name", Rule: "maxLength", Message: "maxLength: got 6, want 3"}},
+	}
+
+	if diff := cmp.Diff(want, report.Violations); diff != "" {
+		t.Errorf("violations mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCustomResourceSchemasRegisterError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		paths []string
+	}{
+		{name: "not found", paths: []string{"testdata/crds/not-found.yaml"}},
+		{name: "no CRD", paths: []string{"testdata/crd-chart/Chart.yaml"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := helmut.NewCustomResourceSchemas().RegisterFiles(tt.paths...); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package helmut
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Delta is the difference between a baseline manifests and a variant manifests.
+type Delta struct {
+	// Added is a list of keys for objects that exist only in the variant.
+	Added []ObjectKey
+
+	// Removed is a list of keys for objects that exist only in the baseline.
+	Removed []ObjectKey
+
+	// Changed is the field paths that differ for objects that exist in both.
+	// e.g. "spec.replicas", "spec.template.spec.containers[0].image", `metadata.labels["app.kubernetes.io/version"]`
+	Changed map[ObjectKey][]string
+}
+
+// Empty returns true if there is no difference.
+func (d *Delta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// RenderDelta renders the chart with the baseline options and the variant options using the same Renderer,
+// and returns the difference between the two results.
+// The variant options are not merged into the baseline options, so specify all the options required by the variant.
+func (r *Renderer) RenderDelta(name, chart string, baseline, variant []Option) (*Delta, error) {
+	base, err := r.RenderTemplates(name, chart, baseline...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render baseline: %w", err)
+	}
+
+	vari, err := r.RenderTemplates(name, chart, variant...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render variant: %w", err)
+	}
+
+	return DiffManifests(base, vari)
+}
+
+// DiffManifests compares the baseline manifests with the variant manifests and returns the difference.
+func DiffManifests(baseline, variant *Manifests) (*Delta, error) {
+	delta := &Delta{}
+
+	for _, key := range baseline.GetKeys() {
+		if _, ok := variant.Load(key); !ok {
+			delta.Removed = append(delta.Removed, key)
+		}
+	}
+
+	for _, key := range variant.GetKeys() {
+		after, _ := variant.Load(key)
+
+		before, ok := baseline.Load(key)
+		if !ok {
+			delta.Added = append(delta.Added, key)
+
+			continue
+		}
+
+		paths, err := diffObjects(before, after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %w", key, err)
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		if delta.Changed == nil {
+			delta.Changed = make(map[ObjectKey][]string)
+		}
+
+		delta.Changed[key] = paths
+	}
+
+	SortObjectKeys(delta.Added)
+	SortObjectKeys(delta.Removed)
+
+	return delta, nil
+}
+
+// SortObjectKeys sorts the keys in the order of their string representation,
+// and the keys of the same object in the order of their versions.
+func SortObjectKeys(keys []ObjectKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		x, y := keys[i].String(), keys[j].String()
+		if x != y {
+			return x < y
+		}
+
+		return keys[i].Version < keys[j].Version
+	})
+}
+
+// diffObjects returns a sorted list of field paths that differ between two objects.
+func diffObjects(before, after runtime.Object) ([]string, error) {
+	x, err := runtime.DefaultUnstructuredConverter.ToUnstructured(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object to unstructured: %w", err)
+	}
+
+	y, err := runtime.DefaultUnstructuredConverter.ToUnstructured(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object to unstructured: %w", err)
+	}
+
+	var paths []string
+
+	diffFields("", x, y, &paths)
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// diffFields walks two unstructured values and appends the paths of the fields that differ.
+func diffFields(path string, x, y interface{}, paths *[]string) {
+	switch xv := x.(type) {
+	case map[string]interface{}:
+		yv, ok := y.(map[string]interface{})
+		if !ok {
+			*paths = append(*paths, path)
+
+			return
+		}
+
+		for k, v := range xv {
+			w, ok := yv[k]
+			if !ok {
+				*paths = append(*paths, joinFieldPath(path, k))
+
+				continue
+			}
+
+			diffFields(joinFieldPath(path, k), v, w, paths)
+		}
+
+		for k := range yv {
+			if _, ok := xv[k]; !ok {
+				*paths = append(*paths, joinFieldPath(path, k))
+			}
+		}
+	case []interface{}:
+		yv, ok := y.([]interface{})
+		if !ok {
+			*paths = append(*paths, path)
+
+			return
+		}
+
+		for i := 0; i < len(xv) || i < len(yv); i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+
+			if i >= len(xv) || i >= len(yv) {
+				*paths = append(*paths, p)
+
+				continue
+			}
+
+			diffFields(p, xv[i], yv[i], paths)
+		}
+	default:
+		if !reflect.DeepEqual(x, y) {
+			*paths = append(*paths, path)
+		}
+	}
+}
+
+// simpleFieldName matches field names that can be written without brackets.
+var simpleFieldName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// joinFieldPath appends the field name to the path.
+// Field names that contain characters such as "." are quoted with brackets.
+func joinFieldPath(path, name string) string {
+	if !simpleFieldName.MatchString(name) {
+		return path + "[" + strconv.Quote(name) + "]"
+	}
+
+	if len(path) == 0 {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,174 @@
+package helmut_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRenderDelta(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	deploymentKey := helmut.NewObjectKey("", "foo-test-chart", schema.GroupVersionKind{
+		Group:   "apps",
+		Version: "v1",
+		Kind:    "Deployment",
+	})
+	hpaKey := helmut.NewObjectKey("", "foo-test-chart", schema.GroupVersionKind{
+		Group:   "autoscaling",
+		Version: "v2beta1",
+		Kind:    "HorizontalPodAutoscaler",
+	})
+	ingressKey := helmut.NewObjectKey("", "foo-test-chart", schema.GroupVersionKind{
+		Group:   "networking.k8s.io",
+		Version: "v1beta1",
+		Kind:    "Ingress",
+	})
+
+	tests := []struct {
+		name     string
+		baseline []helmut.Option
+		variant  []helmut.Option
+		want     *helmut.Delta
+	}{
+		{
+			name:     "no difference",
+			baseline: nil,
+			variant:  nil,
+			want:     &helmut.Delta{},
+		},
+		{
+			name:    "enable autoscaling",
+			variant: []helmut.Option{helmut.WithSet("autoscaling.enabled=true")},
+			want: &helmut.Delta{
+				Added: []helmut.ObjectKey{hpaKey},
+				Changed: map[helmut.ObjectKey][]string{
+					deploymentKey: {"spec.replicas"},
+				},
+			},
+		},
+		{
+			name:     "disable ingress",
+			baseline: []helmut.Option{helmut.WithSet("ingress.enabled=true")},
+			variant:  nil,
+			want: &helmut.Delta{
+				Removed: []helmut.ObjectKey{ingressKey},
+			},
+		},
+		{
+			name:    "change image tag",
+			variant: []helmut.Option{helmut.WithSet("image.tag=1.21.0", "podAnnotations.foo\\.bar/baz=qux")},
+			want: &helmut.Delta{
+				Changed: map[helmut.ObjectKey][]string{
+					deploymentKey: {
+						`spec.template.metadata.annotations`,
+						`spec.template.spec.containers[0].image`,
+					},
+				},
+			},
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := r.RenderDelta(releaseName, filepath.Join("testdata", chartName), tt.baseline, tt.variant)
+			if err != nil {
+				t.Fatalf("failed to render delta: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("delta mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	t.Parallel()
+
+	const baseline = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  labels:
+    app.kubernetes.io/version: "1.0.0"
+data:
+  a: "1"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  ports:
+  - port: 80
+`
+
+	const variant = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  labels:
+    app.kubernetes.io/version: "2.0.0"
+data:
+  a: "1"
+  b: "2"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  ports:
+  - port: 80
+  - port: 443
+`
+
+	r := newRenderer(t)
+
+	base, err := r.SplitManifests([]byte(baseline))
+	if err != nil {
+		t.Fatalf("failed to split manifests: %s", err)
+	}
+
+	vari, err := r.SplitManifests([]byte(variant))
+	if err != nil {
+		t.Fatalf("failed to split manifests: %s", err)
+	}
+
+	got, err := helmut.DiffManifests(base, vari)
+	if err != nil {
+		t.Fatalf("failed to diff manifests: %s", err)
+	}
+
+	want := &helmut.Delta{
+		Changed: map[helmut.ObjectKey][]string{
+			helmut.NewObjectKey("", "foo", schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}): {
+				"data.b",
+				`metadata.labels["app.kubernetes.io/version"]`,
+			},
+			helmut.NewObjectKey("", "foo", schema.GroupVersionKind{Version: "v1", Kind: "Service"}): {
+				"spec.ports[1]",
+			},
+		},
+	}
+
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("delta mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,301 @@
+package helmut
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// buildDependencies copies the chart directory to a temporary directory and builds the "charts/" directory offline,
+// like the "helm dependency build" command.
+// It returns the path to the copied chart and a function that removes the temporary directory.
+//
+// Dependencies are resolved from the following repositories:
+//   "file://" that refers to a chart directory, a chart archive or a directory containing chart archives
+//   a repository URL mapped to a local directory containing chart archives by WithLocalRepository
+//
+// Chart archives are not supported because they already contain the "charts/" directory.
+func buildDependencies(chartPath string, repositories map[string]string) (string, func(), error) {
+	noop := func() {}
+
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to stat the chart: %w", err)
+	}
+
+	if !info.IsDir() {
+		return chartPath, noop, nil
+	}
+
+	metadata, err := chartutil.LoadChartfile(filepath.Join(chartPath, chartutil.ChartfileName))
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to load %s: %w", chartutil.ChartfileName, err)
+	}
+
+	if len(metadata.Dependencies) == 0 {
+		return chartPath, noop, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "helmut-chart-")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+
+	cleanup := func() {
+		os.RemoveAll(tempDir)
+	}
+
+	dst := filepath.Join(tempDir, filepath.Base(chartPath))
+
+	if err := copyDir(chartPath, dst); err != nil {
+		cleanup()
+
+		return "", noop, fmt.Errorf("failed to copy the chart: %w", err)
+	}
+
+	chartsDir := filepath.Join(dst, "charts")
+
+	if err := os.MkdirAll(chartsDir, 0o755); err != nil {
+		cleanup()
+
+		return "", noop, fmt.Errorf("failed to create charts directory: %w", err)
+	}
+
+	resolved := make([]*chart.Chart, 0, len(metadata.Dependencies))
+
+	for _, dep := range metadata.Dependencies {
+		c, err := buildDependency(chartPath, dep, repositories)
+		if err != nil {
+			cleanup()
+
+			return "", noop, fmt.Errorf("failed to build dependency %q: %w", dep.Name, err)
+		}
+
+		if c != nil {
+			resolved = append(resolved, c)
+		}
+	}
+
+	if err := saveDependencies(chartsDir, resolved); err != nil {
+		cleanup()
+
+		return "", noop, err
+	}
+
+	return dst, cleanup, nil
+}
+
+// buildDependency resolves the dependency and returns the chart.
+// It returns nil if the dependency has no repository,
+// because such a dependency must be placed in the "charts/" directory by the user.
+func buildDependency(chartPath string, dep *chart.Dependency, repositories map[string]string) (*chart.Chart, error) {
+	if len(dep.Repository) == 0 {
+		return nil, nil
+	}
+
+	candidates, err := dependencyCandidates(chartPath, dep, repositories)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveDependency(dep, candidates)
+}
+
+// saveDependencies saves the resolved charts as archives in the charts directory.
+// The stale archives and directories of the charts are removed before saving,
+// so that the charts with the same name at different versions, such as aliased dependencies, are all kept.
+func saveDependencies(chartsDir string, resolved []*chart.Chart) error {
+	versions := make(map[string]map[string]bool)
+
+	for _, c := range resolved {
+		if versions[c.Name()] == nil {
+			versions[c.Name()] = make(map[string]bool)
+		}
+
+		versions[c.Name()][c.Metadata.Version] = true
+	}
+
+	for name, keep := range versions {
+		if err := removeStaleDependency(chartsDir, name, keep); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range resolved {
+		if _, err := chartutil.Save(c, chartsDir); err != nil {
+			return fmt.Errorf("failed to save the chart archive of %q: %w", c.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// dependencyCandidates returns the paths of charts that may satisfy the dependency.
+func dependencyCandidates(chartPath string, dep *chart.Dependency, repositories map[string]string) ([]string, error) {
+	var dir string
+
+	if strings.HasPrefix(dep.Repository, "file://") {
+		dir = strings.TrimPrefix(dep.Repository, "file://")
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(chartPath, dir)
+		}
+	} else {
+		local, ok := repositories[strings.TrimSuffix(dep.Repository, "/")]
+		if !ok {
+			return nil, fmt.Errorf("repository %q is not available offline, map it to a local directory with WithLocalRepository",
+				dep.Repository)
+		}
+
+		dir = local
+	}
+
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist", dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", dir, err)
+	}
+
+	// A chart directory or a chart archive.
+	if !info.IsDir() {
+		return []string{dir}, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, chartutil.ChartfileName)); err == nil {
+		return []string{dir}, nil
+	}
+
+	// A directory containing chart archives.
+	archives, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chart archives: %w", err)
+	}
+
+	return archives, nil
+}
+
+// resolveDependency loads the candidates and returns the chart with the highest version that satisfies the dependency.
+func resolveDependency(dep *chart.Dependency, candidates []string) (*chart.Chart, error) {
+	constraint := dep.Version
+	if len(constraint) == 0 {
+		constraint = "*"
+	}
+
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %w", dep.Version, err)
+	}
+
+	var (
+		found    []string
+		resolved *chart.Chart
+		latest   *semver.Version
+	)
+
+	for _, candidate := range candidates {
+		c, err := loader.Load(candidate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", candidate, err)
+		}
+
+		if c.Name() != dep.Name {
+			continue
+		}
+
+		found = append(found, c.Metadata.Version)
+
+		version, err := semver.NewVersion(c.Metadata.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q of %s: %w", c.Metadata.Version, candidate, err)
+		}
+
+		if !constraints.Check(version) {
+			continue
+		}
+
+		if latest == nil || version.GreaterThan(latest) {
+			resolved = c
+			latest = version
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("chart not found in %s", dep.Repository)
+	}
+
+	if resolved == nil {
+		sort.Strings(found)
+
+		return nil, fmt.Errorf("version constraint %q does not match any version %v in %s",
+			constraint, found, dep.Repository)
+	}
+
+	return resolved, nil
+}
+
+// removeStaleDependency removes the directory of the chart and its archives whose versions are not kept
+// from the charts directory.
+func removeStaleDependency(chartsDir, name string, keep map[string]bool) error {
+	entries, err := os.ReadDir(chartsDir)
+	if err != nil {
+		return fmt.Errorf("failed to read charts directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		stale := entry.Name() == name
+
+		if version := strings.TrimPrefix(entry.Name(), name+"-"); !entry.IsDir() &&
+			version != entry.Name() && strings.HasSuffix(version, ".tgz") {
+			version = strings.TrimSuffix(version, ".tgz")
+			_, err := semver.NewVersion(version)
+			stale = err == nil && !keep[version]
+		}
+
+		if !stale {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(chartsDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove stale dependency: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// copyDir copies the directory recursively.
+// The symbolic links are followed like the chart loader, so the targets are copied.
+func copyDir(src, dst string) error {
+	return walkSymlinks(src, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, data, 0o600)
+	})
+}
@@ -0,0 +1,239 @@
+package helmut_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderTemplatesWithDependencyBuild(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "umbrella-chart"
+		repoURL     = "https://charts.example.com"
+	)
+
+	tests := []struct {
+		name       string
+		options    func(t *testing.T) []helmut.Option
+		wantErr    string
+		assertions func(t *testing.T, manifests *helmut.Manifests)
+	}{
+		{
+			name: "file and local repository",
+			options: func(t *testing.T) []helmut.Option {
+				t.Helper()
+
+				return []helmut.Option{
+					helmut.WithDependencyBuild(),
+					helmut.WithLocalRepository(repoURL, packageChart(t, filepath.Join("testdata", "sub-chart"), "")),
+				}
+			},
+			assertions: func(t *testing.T, manifests *helmut.Manifests) {
+				t.Helper()
+
+				assert.Contains(t, manifests, newDeployment("test-chart", releaseName, withDeploymentReplicas(2)),
+					assert.WithIgnoreHelmManagedLabels())
+				assert.Contains(t, manifests, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo-sub-chart"},
+					Data:       map[string]string{"message": "hello from umbrella"},
+				})
+				assert.Contains(t, manifests, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo-umbrella-chart"},
+					Data:       map[string]string{"dependencies": "2"},
+				})
+			},
+		},
+		{
+			name: "repository is not available offline",
+			options: func(t *testing.T) []helmut.Option {
+				t.Helper()
+
+				return []helmut.Option{helmut.WithDependencyBuild()}
+			},
+			wantErr: `repository "https://charts.example.com" is not available offline`,
+		},
+		{
+			name: "missing dependency",
+			options: func(t *testing.T) []helmut.Option {
+				t.Helper()
+
+				return []helmut.Option{
+					helmut.WithDependencyBuild(),
+					helmut.WithLocalRepository(repoURL, t.TempDir()),
+				}
+			},
+			wantErr: `failed to build dependency "sub-chart": chart not found`,
+		},
+		{
+			name: "version constraint does not match",
+			options: func(t *testing.T) []helmut.Option {
+				t.Helper()
+
+				return []helmut.Option{
+					helmut.WithDependencyBuild(),
+					helmut.WithLocalRepository(repoURL, packageChart(t, filepath.Join("testdata", "sub-chart"), "2.0.0")),
+				}
+			},
+			wantErr: `version constraint "^1.0.0" does not match any version [2.0.0]`,
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, filepath.Join("testdata", chartName), tt.options(t)...)
+
+			if len(tt.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			tt.assertions(t, manifests)
+		})
+	}
+}
+
+// packageChart packages the chart into a temporary directory and returns the directory.
+// If version is not empty, the version of the chart is overwritten.
+func packageChart(t *testing.T, path, version string) string {
+	t.Helper()
+
+	c, err := loader.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	if len(version) != 0 {
+		c.Metadata.Version = version
+	}
+
+	dir := t.TempDir()
+
+	if _, err := chartutil.Save(c, dir); err != nil {
+		t.Fatalf("failed to save chart: %s", err)
+	}
+
+	return dir
+}
+
+func TestRenderTemplatesWithDependencyBuildAliases(t *testing.T) {
+	t.Parallel()
+
+	const repoURL = "https://charts.example.com"
+
+	// The repository has both versions of the subchart.
+	repository := packageChart(t, filepath.Join("testdata", "sub-chart"), "1.0.0")
+
+	if err := os.Rename(
+		filepath.Join(packageChart(t, filepath.Join("testdata", "sub-chart"), "2.0.0"), "sub-chart-2.0.0.tgz"),
+		filepath.Join(repository, "sub-chart-2.0.0.tgz"),
+	); err != nil {
+		t.Fatalf("failed to move chart archive: %s", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "alias-chart")
+
+	writeFiles(t, dir, map[string]string{
+		"Chart.yaml": "apiVersion: v2\nname: alias-chart\nversion: 0.1.0\ndependencies:\n" +
+			"- name: sub-chart\n  alias: one\n  version: 1.0.0\n  repository: " + repoURL + "\n" +
+			"- name: sub-chart\n  alias: two\n  version: 2.0.0\n  repository: " + repoURL + "\n",
+		// The stale archive is removed, and the archives of the other versions are kept.
+		"charts/sub-chart-0.1.0.tgz": "stale",
+	})
+
+	manifests, err := newRenderer(t).RenderTemplates("foo", dir,
+		helmut.WithDependencyBuild(), helmut.WithLocalRepository(repoURL, repository))
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	for _, name := range []string{"foo-one", "foo-two"} {
+		assert.Contains(t, manifests, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       map[string]string{"message": "hello"},
+		})
+	}
+}
+
+func TestRenderTemplatesWithDependencyBuildSymlinks(t *testing.T) {
+	t.Parallel()
+
+	shared := t.TempDir()
+
+	writeFiles(t, shared, map[string]string{
+		"templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}-linked\n",
+		"values.yaml":              "message: linked\n",
+	})
+
+	subChart, err := filepath.Abs(filepath.Join("testdata", "sub-chart"))
+	if err != nil {
+		t.Fatalf("failed to get absolute path: %s", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "link-chart")
+
+	writeFiles(t, dir, map[string]string{
+		"Chart.yaml": "apiVersion: v2\nname: link-chart\nversion: 0.1.0\ndependencies:\n" +
+			"- name: sub-chart\n  version: ^1.0.0\n  repository: file://" + subChart + "\n",
+	})
+
+	// A symbolic link to a directory and a symbolic link to a file.
+	if err := os.Symlink(filepath.Join(shared, "templates"), filepath.Join(dir, "templates")); err != nil {
+		t.Fatalf("failed to create symbolic link: %s", err)
+	}
+
+	if err := os.Symlink(filepath.Join(shared, "values.yaml"), filepath.Join(dir, "values.yaml")); err != nil {
+		t.Fatalf("failed to create symbolic link: %s", err)
+	}
+
+	manifests, err := newRenderer(t).RenderTemplates("foo", dir, helmut.WithDependencyBuild())
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	assert.Contains(t, manifests, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "foo-linked"}})
+	assert.Contains(t, manifests, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-sub-chart"},
+		Data:       map[string]string{"message": "hello"},
+	})
+}
+
+// writeFiles writes the files, whose names are slash-separated paths relative to the directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package helmut
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DeprecatedAPI is an object whose API is deprecated or removed at the target Kubernetes version.
+type DeprecatedAPI struct {
+	Key ObjectKey
+
+	// DeprecatedIn is the Kubernetes version in which the API is deprecated, such as "v1.14".
+	DeprecatedIn string
+
+	// RemovedIn is the Kubernetes version in which the API is no longer served, such as "v1.22".
+	// It is empty if the removal is not scheduled.
+	RemovedIn string
+
+	// Removed is true if the API is no longer served at the target version.
+	Removed bool
+
+	// Replacement is the API to migrate to, which is served at the target version.
+	// It is empty if there is no replacement, such as PodSecurityPolicy.
+	Replacement schema.GroupVersionKind
+}
+
+// String returns the deprecation in the format of the warnings of the API server.
+// e.g. "ingress.extensions/foo: extensions/v1beta1 Ingress is deprecated in v1.14+, unavailable in v1.22+;
+// use networking.k8s.io/v1 Ingress"
+func (d DeprecatedAPI) String() string {
+	gvk := d.Key.GetGroupVersionKind()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s: %s %s is deprecated in %s+", d.Key, gvk.GroupVersion(), gvk.Kind, d.DeprecatedIn)
+
+	if len(d.RemovedIn) != 0 {
+		fmt.Fprintf(&b, ", unavailable in %s+", d.RemovedIn)
+	}
+
+	if !d.Replacement.Empty() {
+		fmt.Fprintf(&b, "; use %s %s", d.Replacement.GroupVersion(), d.Replacement.Kind)
+	}
+
+	return b.String()
+}
+
+// apiLifecycle is the lifecycle of an API.
+type apiLifecycle struct {
+	deprecatedIn string
+	removedIn    string
+
+	// replacement is the "apiVersion/kind" of the API to migrate to.
+	replacement string
+}
+
+// apiLifecycles is the lifecycles of the deprecated APIs of Kubernetes, keyed by "apiVersion/kind".
+// see: https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var apiLifecycles = map[string]apiLifecycle{
+	// v1.16
+	"extensions/v1beta1/DaemonSet":         {"1.8", "1.16", "apps/v1/DaemonSet"},
+	"extensions/v1beta1/Deployment":        {"1.8", "1.16", "apps/v1/Deployment"},
+	"extensions/v1beta1/ReplicaSet":        {"1.8", "1.16", "apps/v1/ReplicaSet"},
+	"extensions/v1beta1/NetworkPolicy":     {"1.9", "1.16", "networking.k8s.io/v1/NetworkPolicy"},
+	"extensions/v1beta1/PodSecurityPolicy": {"1.11", "1.16", "policy/v1beta1/PodSecurityPolicy"},
+	"apps/v1beta1/ControllerRevision":      {"1.8", "1.16", "apps/v1/ControllerRevision"},
+	"apps/v1beta1/Deployment":              {"1.8", "1.16", "apps/v1/Deployment"},
+	"apps/v1beta1/StatefulSet":             {"1.8", "1.16", "apps/v1/StatefulSet"},
+	"apps/v1beta2/ControllerRevision":      {"1.9", "1.16", "apps/v1/ControllerRevision"},
+	"apps/v1beta2/DaemonSet":               {"1.9", "1.16", "apps/v1/DaemonSet"},
+	"apps/v1beta2/Deployment":              {"1.9", "1.16", "apps/v1/Deployment"},
+	"apps/v1beta2/ReplicaSet":              {"1.9", "1.16", "apps/v1/ReplicaSet"},
+	"apps/v1beta2/StatefulSet":             {"1.9", "1.16", "apps/v1/StatefulSet"},
+
+	// v1.22
+	"extensions/v1beta1/Ingress": {"1.14", "1.22", "networking.k8s.io/v1/Ingress"},
+	"admissionregistration.k8s.io/v1beta1/MutatingWebhookConfiguration": {
+		"1.16", "1.22", "admissionregistration.k8s.io/v1/MutatingWebhookConfiguration",
+	},
+	"admissionregistration.k8s.io/v1beta1/ValidatingWebhookConfiguration": {
+		"1.16", "1.22", "admissionregistration.k8s.io/v1/ValidatingWebhookConfiguration",
+	},
+	"apiextensions.k8s.io/v1beta1/CustomResourceDefinition": {
+		"1.16", "1.22", "apiextensions.k8s.io/v1/CustomResourceDefinition",
+	},
+	"apiregistration.k8s.io/v1beta1/APIService": {"1.19", "1.22", "apiregistration.k8s.io/v1/APIService"},
+	"authentication.k8s.io/v1beta1/TokenReview": {"1.19", "1.22", "authentication.k8s.io/v1/TokenReview"},
+	"authorization.k8s.io/v1beta1/LocalSubjectAccessReview": {
+		"1.19", "1.22", "authorization.k8s.io/v1/LocalSubjectAccessReview",
+	},
+	"authorization.k8s.io/v1beta1/SelfSubjectAccessReview": {
+		"1.19", "1.22", "authorization.k8s.io/v1/SelfSubjectAccessReview",
+	},
+	"authorization.k8s.io/v1beta1/SelfSubjectRulesReview": {
+		"1.19", "1.22", "authorization.k8s.io/v1/SelfSubjectRulesReview",
+	},
+	"authorization.k8s.io/v1beta1/SubjectAccessReview": {
+		"1.19", "1.22", "authorization.k8s.io/v1/SubjectAccessReview",
+	},
+	"certificates.k8s.io/v1beta1/CertificateSigningRequest": {
+		"1.19", "1.22", "certificates.k8s.io/v1/CertificateSigningRequest",
+	},
+	"coordination.k8s.io/v1beta1/Lease":      {"1.19", "1.22", "coordination.k8s.io/v1/Lease"},
+	"networking.k8s.io/v1beta1/Ingress":      {"1.19", "1.22", "networking.k8s.io/v1/Ingress"},
+	"networking.k8s.io/v1beta1/IngressClass": {"1.19", "1.22", "networking.k8s.io/v1/IngressClass"},
+	"rbac.authorization.k8s.io/v1beta1/ClusterRole": {
+		"1.17", "1.22", "rbac.authorization.k8s.io/v1/ClusterRole",
+	},
+	"rbac.authorization.k8s.io/v1beta1/ClusterRoleBinding": {
+		"1.17", "1.22", "rbac.authorization.k8s.io/v1/ClusterRoleBinding",
+	},
+	"rbac.authorization.k8s.io/v1beta1/Role":        {"1.17", "1.22", "rbac.authorization.k8s.io/v1/Role"},
+	"rbac.authorization.k8s.io/v1beta1/RoleBinding": {"1.17", "1.22", "rbac.authorization.k8s.io/v1/RoleBinding"},
+	"scheduling.k8s.io/v1beta1/PriorityClass":       {"1.14", "1.22", "scheduling.k8s.io/v1/PriorityClass"},
+	"storage.k8s.io/v1beta1/CSIDriver":              {"1.19", "1.22", "storage.k8s.io/v1/CSIDriver"},
+	"storage.k8s.io/v1beta1/CSINode":                {"1.17", "1.22", "storage.k8s.io/v1/CSINode"},
+	"storage.k8s.io/v1beta1/StorageClass":           {"1.19", "1.22", "storage.k8s.io/v1/StorageClass"},
+	"storage.k8s.io/v1beta1/VolumeAttachment":       {"1.19", "1.22", "storage.k8s.io/v1/VolumeAttachment"},
+
+	// v1.25
+	"batch/v1beta1/CronJob":                       {"1.21", "1.25", "batch/v1/CronJob"},
+	"discovery.k8s.io/v1beta1/EndpointSlice":      {"1.21", "1.25", "discovery.k8s.io/v1/EndpointSlice"},
+	"events.k8s.io/v1beta1/Event":                 {"1.22", "1.25", "events.k8s.io/v1/Event"},
+	"autoscaling/v2beta1/HorizontalPodAutoscaler": {"1.22", "1.25", "autoscaling/v2/HorizontalPodAutoscaler"},
+	"policy/v1beta1/PodDisruptionBudget":          {"1.21", "1.25", "policy/v1/PodDisruptionBudget"},
+	"policy/v1beta1/PodSecurityPolicy":            {"1.21", "1.25", ""},
+	"node.k8s.io/v1beta1/RuntimeClass":            {"1.22", "1.25", "node.k8s.io/v1/RuntimeClass"},
+
+	// v1.26
+	"autoscaling/v2beta2/HorizontalPodAutoscaler": {"1.23", "1.26", "autoscaling/v2/HorizontalPodAutoscaler"},
+	"flowcontrol.apiserver.k8s.io/v1beta1/FlowSchema": {
+		"1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1beta3/FlowSchema",
+	},
+	"flowcontrol.apiserver.k8s.io/v1beta1/PriorityLevelConfiguration": {
+		"1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1beta3/PriorityLevelConfiguration",
+	},
+
+	// v1.27
+	"storage.k8s.io/v1beta1/CSIStorageCapacity": {"1.24", "1.27", "storage.k8s.io/v1/CSIStorageCapacity"},
+
+	// v1.29
+	"flowcontrol.apiserver.k8s.io/v1beta2/FlowSchema": {
+		"1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1beta3/FlowSchema",
+	},
+	"flowcontrol.apiserver.k8s.io/v1beta2/PriorityLevelConfiguration": {
+		"1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1beta3/PriorityLevelConfiguration",
+	},
+
+	// v1.32
+	"flowcontrol.apiserver.k8s.io/v1beta3/FlowSchema": {
+		"1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1/FlowSchema",
+	},
+	"flowcontrol.apiserver.k8s.io/v1beta3/PriorityLevelConfiguration": {
+		"1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1/PriorityLevelConfiguration",
+	},
+
+	// v1.34
+	"admissionregistration.k8s.io/v1beta1/ValidatingAdmissionPolicy": {
+		"1.31", "1.34", "admissionregistration.k8s.io/v1/ValidatingAdmissionPolicy",
+	},
+	"admissionregistration.k8s.io/v1beta1/ValidatingAdmissionPolicyBinding": {
+		"1.31", "1.34", "admissionregistration.k8s.io/v1/ValidatingAdmissionPolicyBinding",
+	},
+
+	// v1.37
+	"storage.k8s.io/v1beta1/VolumeAttributesClass": {"1.34", "1.37", "storage.k8s.io/v1/VolumeAttributesClass"},
+
+	// v1.38
+	"resource.k8s.io/v1beta1/DeviceClass":           {"1.35", "1.38", "resource.k8s.io/v1/DeviceClass"},
+	"resource.k8s.io/v1beta1/ResourceClaim":         {"1.35", "1.38", "resource.k8s.io/v1/ResourceClaim"},
+	"resource.k8s.io/v1beta1/ResourceClaimTemplate": {"1.35", "1.38", "resource.k8s.io/v1/ResourceClaimTemplate"},
+	"resource.k8s.io/v1beta1/ResourceSlice":         {"1.35", "1.38", "resource.k8s.io/v1/ResourceSlice"},
+
+	// v1.39
+	"resource.k8s.io/v1beta2/DeviceClass":           {"1.36", "1.39", "resource.k8s.io/v1/DeviceClass"},
+	"resource.k8s.io/v1beta2/ResourceClaim":         {"1.36", "1.39", "resource.k8s.io/v1/ResourceClaim"},
+	"resource.k8s.io/v1beta2/ResourceClaimTemplate": {"1.36", "1.39", "resource.k8s.io/v1/ResourceClaimTemplate"},
+	"resource.k8s.io/v1beta2/ResourceSlice":         {"1.36", "1.39", "resource.k8s.io/v1/ResourceSlice"},
+
+	// not scheduled
+	"v1/Endpoints": {"1.33", "", "discovery.k8s.io/v1/EndpointSlice"},
+}
+
+// CheckDeprecatedAPIs returns the objects of the manifests whose APIs are deprecated or removed
+// at the Kubernetes version, such as "1.25" and "v1.25.3", with the APIs to migrate to.
+// The APIs of the custom resources are not checked.
+//
+// e.g.
+//
+//  deprecated, err := helmut.CheckDeprecatedAPIs(manifests, "1.25")
+//
+//  for _, d := range deprecated {
+//  	fmt.Println(d.Key, d.Removed, d.Replacement)
+//  }
+//
+func CheckDeprecatedAPIs(manifests *Manifests, kubeVersion string) ([]DeprecatedAPI, error) {
+	target, err := parseMinorVersion(kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := manifests.GetKeys()
+	SortObjectKeys(keys)
+
+	var deprecated []DeprecatedAPI
+
+	for _, key := range keys {
+		lifecycle, ok := apiLifecycles[apiLifecycleKey(key.GetGroupVersionKind())]
+		if !ok || target.LessThan(semver.MustParse(lifecycle.deprecatedIn)) {
+			continue
+		}
+
+		deprecated = append(deprecated, DeprecatedAPI{
+			Key:          key,
+			DeprecatedIn: "v" + lifecycle.deprecatedIn,
+			RemovedIn:    prefixVersion(lifecycle.removedIn),
+			Removed:      isRemoved(lifecycle, target),
+			Replacement:  resolveReplacement(lifecycle, target),
+		})
+	}
+
+	return deprecated, nil
+}
+
+// parseMinorVersion parses the Kubernetes version and drops the patch version and the pre-release.
+func parseMinorVersion(kubeVersion string) (*semver.Version, error) {
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Kubernetes version %q: %w", kubeVersion, err)
+	}
+
+	return semver.New(v.Major(), v.Minor(), 0, "", ""), nil
+}
+
+// apiLifecycleKey returns the key of apiLifecycles.
+func apiLifecycleKey(gvk schema.GroupVersionKind) string {
+	return gvk.GroupVersion().String() + "/" + gvk.Kind
+}
+
+// isRemoved returns true if the API is not served at the target version.
+func isRemoved(lifecycle apiLifecycle, target *semver.Version) bool {
+	return len(lifecycle.removedIn) != 0 && !target.LessThan(semver.MustParse(lifecycle.removedIn))
+}
+
+// resolveReplacement returns the replacement of the API that is served at the target version,
+// following the replacements of the removed replacements.
+func resolveReplacement(lifecycle apiLifecycle, target *semver.Version) schema.GroupVersionKind {
+	replacement := lifecycle.replacement
+
+	for {
+		next, ok := apiLifecycles[replacement]
+		if !ok || !isRemoved(next, target) {
+			break
+		}
+
+		replacement = next.replacement
+	}
+
+	if len(replacement) == 0 {
+		return schema.GroupVersionKind{}
+	}
+
+	i := strings.LastIndex(replacement, "/")
+
+	return schema.FromAPIVersionAndKind(replacement[:i], replacement[i+1:])
+}
+
+// prefixVersion returns the version with the "v" prefix, or an empty string.
+func prefixVersion(version string) string {
+	if len(version) == 0 {
+		return ""
+	}
+
+	return "v" + version
+}
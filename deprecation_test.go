@@ -0,0 +1,192 @@
+package helmut_test
+
+import (
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckDeprecatedAPIs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ingressKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"})
+		podSecurityPolicyKey = helmut.NewObjectKey("", "foo",
+			schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy"})
+		flowSchemaKey = helmut.NewObjectKey("", "foo",
+			schema.GroupVersionKind{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta1", Kind: "FlowSchema"})
+		endpointsKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Version: "v1", Kind: "Endpoints"})
+		deploymentKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	)
+
+	manifests := helmut.NewManifests()
+
+	for _, key := range []helmut.ObjectKey{ingressKey, podSecurityPolicyKey, flowSchemaKey, endpointsKey, deploymentKey} {
+		manifests.Store(key, &unstructured.Unstructured{})
+	}
+
+	var (
+		ingress = helmut.DeprecatedAPI{
+			Key:          ingressKey,
+			DeprecatedIn: "v1.14",
+			RemovedIn:    "v1.22",
+			Replacement:  schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+		}
+		podSecurityPolicy = helmut.DeprecatedAPI{
+			Key:          podSecurityPolicyKey,
+			DeprecatedIn: "v1.21",
+			RemovedIn:    "v1.25",
+		}
+		flowSchema = helmut.DeprecatedAPI{
+			Key:          flowSchemaKey,
+			DeprecatedIn: "v1.23",
+			RemovedIn:    "v1.26",
+		}
+	)
+
+	removed := func(d helmut.DeprecatedAPI) helmut.DeprecatedAPI {
+		d.Removed = true
+
+		return d
+	}
+
+	replaced := func(d helmut.DeprecatedAPI, version string) helmut.DeprecatedAPI {
+		d.Replacement = schema.GroupVersionKind{
+			Group: "flowcontrol.apiserver.k8s.io", Version: version, Kind: "FlowSchema",
+		}
+
+		return d
+	}
+
+	tests := []struct {
+		name        string
+		kubeVersion string
+		want        []helmut.DeprecatedAPI
+	}{
+		{
+			name:        "not deprecated",
+			kubeVersion: "1.13",
+		},
+		{
+			name:        "deprecated",
+			kubeVersion: "v1.21.3",
+			want:        []helmut.DeprecatedAPI{ingress, podSecurityPolicy},
+		},
+		{
+			name:        "removed",
+			kubeVersion: "1.25",
+			want: []helmut.DeprecatedAPI{
+				replaced(flowSchema, "v1beta3"),
+				removed(ingress),
+				removed(podSecurityPolicy),
+			},
+		},
+		{
+			name:        "removed replacement",
+			kubeVersion: "1.33.0-rc.0",
+			want: []helmut.DeprecatedAPI{
+				{
+					Key:          endpointsKey,
+					DeprecatedIn: "v1.33",
+					Replacement:  schema.GroupVersionKind{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"},
+				},
+				removed(replaced(flowSchema, "v1")),
+				removed(ingress),
+				removed(podSecurityPolicy),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := helmut.CheckDeprecatedAPIs(manifests, tt.kubeVersion)
+			if err != nil {
+				t.Fatalf("check error: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("deprecated APIs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckDeprecatedAPIsError(t *testing.T) {
+	t.Parallel()
+
+	for _, kubeVersion := range []string{"", "latest"} {
+		kubeVersion := kubeVersion
+
+		t.Run(kubeVersion, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := helmut.CheckDeprecatedAPIs(helmut.NewManifests(), kubeVersion); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestDeprecatedAPIString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		api  helmut.DeprecatedAPI
+		want string
+	}{
+		{
+			name: "replacement",
+			api: helmut.DeprecatedAPI{
+				Key: helmut.NewObjectKey("default", "foo",
+					schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}),
+				DeprecatedIn: "v1.14",
+				RemovedIn:    "v1.22",
+				Replacement:  schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+			},
+			want: "ingress.extensions/default/foo: extensions/v1beta1 Ingress is deprecated in v1.14+, " +
+				"unavailable in v1.22+; use networking.k8s.io/v1 Ingress",
+		},
+		{
+			name: "no replacement",
+			api: helmut.DeprecatedAPI{
+				Key: helmut.NewObjectKey("", "foo",
+					schema.GroupVersionKind{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy"}),
+				DeprecatedIn: "v1.21",
+				RemovedIn:    "v1.25",
+			},
+			want: "podsecuritypolicy.policy/foo: policy/v1beta1 PodSecurityPolicy is deprecated in v1.21+, unavailable in v1.25+",
+		},
+		{
+			name: "removal not scheduled",
+			api: helmut.DeprecatedAPI{
+				Key:          helmut.NewObjectKey("default", "foo", schema.GroupVersionKind{Version: "v1", Kind: "Endpoints"}),
+				DeprecatedIn: "v1.33",
+				Replacement:  schema.GroupVersionKind{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice"},
+			},
+			want: "endpoints/default/foo: v1 Endpoints is deprecated in v1.33+; use discovery.k8s.io/v1 EndpointSlice",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.api.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package helmut
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"text/template"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// notesFile is the suffix of the notes templates, whose outputs are not the manifests.
+const notesFile = "NOTES.txt"
+
+// defaultDeterministicNow is the current time of the templates if DeterministicFunctions.Now is not specified.
+var defaultDeterministicNow = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DeterministicFunctions is the configuration of the deterministic template functions.
+type DeterministicFunctions struct {
+	// Seed is the seed of the random values, such as the random strings, UUIDs, private keys and certificates.
+	Seed int64
+	// Now is the current time returned by "now" and used by "ago" and the generated certificates.
+	// The default is 2000-01-01T00:00:00Z.
+	Now time.Time
+}
+
+// deterministicCertificate is the certificate returned by the deterministic functions,
+// which has the same fields as the certificates of sprig.
+type deterministicCertificate struct {
+	Cert string
+	Key  string
+}
+
+// renderDeterministic renders the chart once with the deterministic functions,
+// and returns the release whose manifest is built from the outputs in the same way as Helm.
+// If the templates cannot be rendered, the processed release is returned with the error.
+func (r *Renderer) renderDeterministic(
+	name string,
+	chartRequested *chart.Chart,
+	values map[string]interface{},
+	opts *option,
+	postRenderer postrender.PostRenderer,
+) (*release.Release, error) {
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, fmt.Errorf("release name %q: %w", name, err)
+	}
+
+	processed, err := r.processRelease(name, chartRequested, values, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	renderValues, err := renderValues(processed, processed.Chart, opts)
+	if err != nil {
+		return processed, err
+	}
+
+	functions := newDeterministicFunctions(*opts.deterministic)
+	e := templateEngine{funcs: functions.funcMap(), beforeExecute: functions.reset}
+
+	outputs, err := e.Render(processed.Chart, renderValues)
+	if err != nil {
+		return processed, err
+	}
+
+	manifest, err := releaseManifest(processed.Chart, outputs, opts.includeCRDs, postRenderer)
+	if err != nil {
+		return processed, err
+	}
+
+	processed.Manifest = manifest
+
+	return processed, nil
+}
+
+// releaseManifest returns the manifest of the release from the outputs of the templates,
+// which is built like action.Configuration.renderResources of Helm.
+// The notes and the hooks are not included in the manifest.
+func releaseManifest(
+	c *chart.Chart,
+	outputs map[string]string,
+	includeCRDs bool,
+	postRenderer postrender.PostRenderer,
+) (string, error) {
+	if c.Metadata.KubeVersion != "" {
+		kubeVersion := chartutil.DefaultCapabilities.KubeVersion.String()
+		if !chartutil.IsCompatibleRange(c.Metadata.KubeVersion, kubeVersion) {
+			return "", fmt.Errorf("chart requires kubeVersion: %s which is incompatible with Kubernetes %s",
+				c.Metadata.KubeVersion, kubeVersion)
+		}
+	}
+
+	for name := range outputs {
+		if strings.HasSuffix(name, notesFile) {
+			delete(outputs, name)
+		}
+	}
+
+	_, manifests, err := releaseutil.SortManifests(outputs, nil, releaseutil.InstallOrder)
+	if err != nil {
+		return "", err
+	}
+
+	b := &bytes.Buffer{}
+
+	if includeCRDs {
+		for _, crd := range c.CRDObjects() {
+			fmt.Fprintf(b, "---\n# Source: %s\n%s\n", crd.Filename, string(crd.File.Data))
+		}
+	}
+
+	for _, m := range manifests {
+		fmt.Fprintf(b, "---\n# Source: %s\n%s\n", m.Name, m.Content)
+	}
+
+	if postRenderer == nil {
+		return b.String(), nil
+	}
+
+	b, err = postRenderer.Run(b)
+	if err != nil {
+		return "", fmt.Errorf("error while running post render on files: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// deterministicFunctions provides the deterministic template functions during a rendering.
+//
+// The random values of a call are generated from the seed, the template being rendered, the function and its arguments,
+// so that the result does not depend on the calls in the other templates.
+type deterministicFunctions struct {
+	config DeterministicFunctions
+	// template is the name of the template being rendered.
+	template string
+	// calls is the number of the previous calls with the same function and arguments in the template,
+	// such as the calls in a named template included several times.
+	calls map[string]int
+	// usedRSAKeys is the pregenerated RSA keys returned during the rendering.
+	usedRSAKeys map[rsaKeyID]bool
+}
+
+// rsaKeyID identifies a pregenerated RSA key.
+type rsaKeyID struct {
+	bits  int
+	index int
+}
+
+// newDeterministicFunctions returns the deterministic functions with the configuration.
+func newDeterministicFunctions(config DeterministicFunctions) *deterministicFunctions {
+	if config.Now.IsZero() {
+		config.Now = defaultDeterministicNow
+	}
+
+	return &deterministicFunctions{config: config, usedRSAKeys: make(map[rsaKeyID]bool)}
+}
+
+// reset is called before rendering each template.
+func (f *deterministicFunctions) reset(templateName string) {
+	f.template = templateName
+	f.calls = make(map[string]int)
+}
+
+// call returns a call of the function with the arguments, whose random values are generated from its seed.
+func (f *deterministicFunctions) call(name string, args ...interface{}) *deterministicCall {
+	encoded, _ := json.Marshal(args)
+
+	key := f.template + "\x00" + name + "\x00" + string(encoded)
+
+	n := f.calls[key]
+	f.calls[key]++
+
+	h := sha256.New()
+
+	_ = binary.Write(h, binary.BigEndian, f.config.Seed)
+	_ = binary.Write(h, binary.BigEndian, int64(n))
+	h.Write([]byte(key))
+
+	var seed [32]byte
+
+	copy(seed[:], h.Sum(nil))
+
+	source := rand.NewChaCha8(seed)
+
+	return &deterministicCall{
+		now:         f.config.Now,
+		seed:        seed,
+		source:      source,
+		rand:        rand.New(source),
+		usedRSAKeys: f.usedRSAKeys,
+	}
+}
+
+// funcMap returns the deterministic implementations of the template functions whose results change every render.
+// The signatures are the same as sprig.
+//
+//nolint:funlen // The table of the functions.
+func (f *deterministicFunctions) funcMap() template.FuncMap {
+	randString := func(name, chars string) func(int) string {
+		return func(count int) string {
+			return f.call(name, count).randString(chars, count)
+		}
+	}
+
+	return template.FuncMap{
+		"now": func() time.Time {
+			return f.config.Now
+		},
+		"ago": func(date interface{}) string {
+			return deterministicAgo(f.config.Now, date)
+		},
+		"randAlphaNum": randString("randAlphaNum", alphaNumericChars),
+		"randAlpha":    randString("randAlpha", alphaChars),
+		"randNumeric":  randString("randNumeric", numericChars),
+		"randAscii":    randString("randAscii", asciiChars),
+		"randBytes": func(count int) (string, error) {
+			return f.call("randBytes", count).randBytes(count)
+		},
+		"randInt": func(min, max int) (int, error) {
+			return f.call("randInt", min, max).randInt(min, max)
+		},
+		"shuffle": func(s string) string {
+			return f.call("shuffle", s).shuffle(s)
+		},
+		"uuidv4": func() (string, error) {
+			return f.call("uuidv4").uuid()
+		},
+		"genPrivateKey": func(typ string) (string, error) {
+			return f.call("genPrivateKey", typ).privateKey(typ)
+		},
+		"genCA": func(cn string, days int) (deterministicCertificate, error) {
+			return f.call("genCA", cn, days).ca(cn, days, "")
+		},
+		"genCAWithKey": func(cn string, days int, privPEM string) (deterministicCertificate, error) {
+			return f.call("genCAWithKey", cn, days, privPEM).ca(cn, days, privPEM)
+		},
+		"genSelfSignedCert": func(cn string, ips, dnsNames []interface{}, days int) (deterministicCertificate, error) {
+			return f.call("genSelfSignedCert", cn, ips, dnsNames, days).selfSignedCert(cn, ips, dnsNames, days, "")
+		},
+		"genSelfSignedCertWithKey": func(
+			cn string, ips, dnsNames []interface{}, days int, privPEM string,
+		) (deterministicCertificate, error) {
+			return f.call("genSelfSignedCertWithKey", cn, ips, dnsNames, days, privPEM).
+				selfSignedCert(cn, ips, dnsNames, days, privPEM)
+		},
+		"genSignedCert": func(
+			cn string, ips, dnsNames []interface{}, days int, ca deterministicCertificate,
+		) (deterministicCertificate, error) {
+			return f.call("genSignedCert", cn, ips, dnsNames, days, ca).signedCert(cn, ips, dnsNames, days, ca, "")
+		},
+		"genSignedCertWithKey": func(
+			cn string, ips, dnsNames []interface{}, days int, ca deterministicCertificate, privPEM string,
+		) (deterministicCertificate, error) {
+			return f.call("genSignedCertWithKey", cn, ips, dnsNames, days, ca, privPEM).
+				signedCert(cn, ips, dnsNames, days, ca, privPEM)
+		},
+	}
+}
+
+// deterministicCall is a call of a deterministic function.
+type deterministicCall struct {
+	now time.Time
+	// seed is the seed of the call, and source and rand are the random values generated from the seed.
+	seed   [32]byte
+	source *rand.ChaCha8
+	rand   *rand.Rand
+	// usedRSAKeys is shared with the functions.
+	usedRSAKeys map[rsaKeyID]bool
+}
+
+// processRelease returns the release whose chart and config are processed for rendering like Helm,
+// such as disabling the subcharts and importing the values.
+// The chart is copied, so the requested chart is not modified.
+func (r *Renderer) processRelease(
+	name string,
+	chartRequested *chart.Chart,
+	values map[string]interface{},
+	opts *option,
+) (*release.Release, error) {
+	c, err := copyChart(chartRequested)
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &release.Release{Name: name, Namespace: opts.namespace, Chart: c, Config: values, Version: 1}
+
+	if opts.upgrade.previous != nil {
+		previous, err := r.newPreviousRelease(name, chartRequested, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		processed.Config, err = upgradeValues(c, previous, values, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		processed.Version = previous.Version + 1
+	}
+
+	if err := chartutil.ProcessDependenciesWithMerge(c, processed.Config); err != nil {
+		return nil, fmt.Errorf("failed to process dependencies: %w", err)
+	}
+
+	return processed, nil
+}
@@ -0,0 +1,309 @@
+package helmut_test
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRenderTemplatesWithDeterministicFunctions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  helmut.DeterministicFunctions
+		wantNow time.Time
+	}{
+		{
+			name:    "default time",
+			config:  helmut.DeterministicFunctions{Seed: 1},
+			wantNow: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "specified time",
+			config:  helmut.DeterministicFunctions{Seed: 1, Now: time.Date(2024, time.March, 4, 5, 6, 7, 0, time.UTC)},
+			wantNow: time.Date(2024, time.March, 4, 5, 6, 7, 0, time.UTC),
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			configMap, secret := renderDeterministicChart(t, renderer, tt.config)
+
+			want := map[string]string{
+				"now":  tt.wantNow.Format(time.RFC3339),
+				"year": tt.wantNow.Format("2006"),
+				"ago":  "1h30m0s",
+			}
+
+			for key, value := range want {
+				if got := configMap.Data[key]; got != value {
+					t.Errorf("got %s %q, want %q", key, got, value)
+				}
+			}
+
+			if got, want := configMap.Annotations["example.com/template"], "{{ .Values.commonName }}"; got != want {
+				t.Errorf("got annotation %q, want %q", got, want)
+			}
+
+			if got := configMap.Data["first-token"]; got == configMap.Data["second-token"] {
+				t.Errorf("got the same tokens %q for the calls", got)
+			}
+
+			id, err := uuid.Parse(configMap.Data["uuid"])
+			if err != nil {
+				t.Fatalf("failed to parse UUID: %s", err)
+			}
+
+			if got := id.Version(); got != 4 {
+				t.Errorf("got UUID version %d, want 4", got)
+			}
+
+			ca := parseCertificate(t, secret.Data["ca.crt"])
+			cert := parseCertificate(t, secret.Data["tls.crt"])
+
+			if !cert.NotBefore.Equal(tt.wantNow) {
+				t.Errorf("got NotBefore %s, want %s", cert.NotBefore, tt.wantNow)
+			}
+
+			if want := tt.wantNow.AddDate(0, 0, 365); !cert.NotAfter.Equal(want) {
+				t.Errorf("got NotAfter %s, want %s", cert.NotAfter, want)
+			}
+
+			if err := cert.CheckSignatureFrom(ca); err != nil {
+				t.Errorf("certificate is not signed by the CA: %s", err)
+			}
+
+			if cert.PublicKey.(*rsa.PublicKey).Equal(ca.PublicKey) {
+				t.Error("got the same keys for the certificate and the CA")
+			}
+
+			for _, key := range []string{"self.crt", "ecdsa.crt"} {
+				self := parseCertificate(t, secret.Data[key])
+
+				if err := self.CheckSignature(self.SignatureAlgorithm, self.RawTBSCertificate, self.Signature); err != nil {
+					t.Errorf("%s is not self-signed: %s", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderTemplatesWithDeterministicFunctionsSeed(t *testing.T) {
+	t.Parallel()
+
+	renderer := helmut.New()
+
+	configMap, secret := renderDeterministicChart(t, renderer, helmut.DeterministicFunctions{Seed: 1})
+	sameConfigMap, sameSecret := renderDeterministicChart(t, renderer, helmut.DeterministicFunctions{Seed: 1})
+
+	if diff := cmp.Diff(configMap.Data, sameConfigMap.Data); diff != "" {
+		t.Errorf("config map mismatch for the same seed (-first +second):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(secret.Data, sameSecret.Data); diff != "" {
+		t.Errorf("secret mismatch for the same seed (-first +second):\n%s", diff)
+	}
+
+	otherConfigMap, otherSecret := renderDeterministicChart(t, renderer, helmut.DeterministicFunctions{Seed: 2})
+
+	for _, key := range []string{"password", "pin", "uuid", "first-token"} {
+		if configMap.Data[key] == otherConfigMap.Data[key] {
+			t.Errorf("got the same %s %q for the different seeds", key, configMap.Data[key])
+		}
+	}
+
+	for _, key := range []string{"ca.crt", "tls.key", "ecdsa.key", "ed25519.key"} {
+		if string(secret.Data[key]) == string(otherSecret.Data[key]) {
+			t.Errorf("got the same %s for the different seeds", key)
+		}
+	}
+}
+
+func TestRenderTemplatesWithDeterministicFunctionsError(t *testing.T) {
+	t.Parallel()
+
+	renderer := helmut.New()
+
+	_, err := renderer.RenderTemplates("foo", "testdata/deterministic-chart",
+		helmut.WithDeterministicFunctions(helmut.DeterministicFunctions{}),
+		helmut.WithSet("keyType=dsa"))
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+
+	if want := "dsa keys are not supported"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want to contain %q", err, want)
+	}
+}
+
+func renderDeterministicChart(
+	t *testing.T,
+	renderer *helmut.Renderer,
+	config helmut.DeterministicFunctions,
+) (*corev1.ConfigMap, *corev1.Secret) {
+	t.Helper()
+
+	manifests, err := renderer.RenderTemplates("foo", "testdata/deterministic-chart",
+		helmut.WithDeterministicFunctions(config))
+	if err != nil {
+		t.Fatalf("render error: %s", err)
+	}
+
+	configMap, ok := manifests.Load(helmut.NewObjectKey("", "foo-random",
+		schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+	if !ok {
+		t.Fatal("config map not found")
+	}
+
+	secret, ok := manifests.Load(helmut.NewObjectKey("", "foo-tls",
+		schema.GroupVersionKind{Version: "v1", Kind: "Secret"}))
+	if !ok {
+		t.Fatal("secret not found")
+	}
+
+	return configMap.(*corev1.ConfigMap), secret.(*corev1.Secret)
+}
+
+func parseCertificate(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("failed to decode certificate: %q", data)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+
+	return cert
+}
+
+func TestRenderTemplatesWithDeterministicFunctionsSameAsHelm(t *testing.T) {
+	t.Parallel()
+
+	renderer := newRenderer(t)
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+	}{
+		{
+			name:  "chart without the functions",
+			chart: "testdata/test-chart",
+		},
+		{
+			name:    "CRDs",
+			chart:   "testdata/crd-chart",
+			options: []helmut.Option{helmut.WithIncludeCRDs()},
+		},
+		{
+			name:    "upgrade",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithUpgrade(helmut.PreviousRelease{Revision: 3})},
+		},
+		{
+			name:  "post-renderer",
+			chart: "testdata/test-chart",
+			options: []helmut.Option{helmut.WithPostRendererFunc(func(manifests *helmut.Manifests) error {
+				manifests.Delete(helmut.NewObjectKey("", "foo-test-chart",
+					schema.GroupVersionKind{Version: "v1", Kind: "Service"}))
+
+				return nil
+			})},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			want, err := renderer.RenderTemplates("foo", tt.chart, tt.options...)
+			if err != nil {
+				t.Fatalf("render error: %s", err)
+			}
+
+			options := append([]helmut.Option{
+				helmut.WithDeterministicFunctions(helmut.DeterministicFunctions{Seed: 1}),
+			}, tt.options...)
+
+			got, err := renderer.RenderTemplates("foo", tt.chart, options...)
+			if err != nil {
+				t.Fatalf("render error: %s", err)
+			}
+
+			sortKeys := cmpopts.SortSlices(func(a, b helmut.ObjectKey) bool { return a.String() < b.String() })
+
+			if diff := cmp.Diff(want.GetKeys(), got.GetKeys(), sortKeys); diff != "" {
+				t.Errorf("keys mismatch (-helm +deterministic):\n%s", diff)
+			}
+
+			for _, key := range want.GetKeys() {
+				wantObject, _ := want.Load(key)
+				gotObject, _ := got.Load(key)
+
+				if diff := cmp.Diff(wantObject, gotObject); diff != "" {
+					t.Errorf("%s mismatch (-helm +deterministic):\n%s", key, diff)
+				}
+			}
+		})
+	}
+
+	t.Run("comments and trim markers", func(t *testing.T) {
+		t.Parallel()
+
+		render := func() *corev1.ConfigMap {
+			manifests, err := renderer.RenderTemplates("foo", "testdata/deterministic-chart",
+				helmut.WithDeterministicFunctions(helmut.DeterministicFunctions{Seed: 1}))
+			if err != nil {
+				t.Fatalf("render error: %s", err)
+			}
+
+			object, ok := manifests.Load(helmut.NewObjectKey("", "foo-trim",
+				schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+			if !ok {
+				t.Fatal("config map not found")
+			}
+
+			return object.(*corev1.ConfigMap)
+		}
+
+		configMap := render()
+
+		if got, want := configMap.Data["joined"], "ab"; got != want {
+			t.Errorf("got joined %q, want %q", got, want)
+		}
+
+		for _, key := range []string{"token", "tpl-token"} {
+			if got := configMap.Data[key]; len(got) != 4 {
+				t.Errorf("got %s %q, want 4 characters", key, got)
+			}
+		}
+
+		// The functions called by "tpl" are also replaced.
+		if diff := cmp.Diff(configMap.Data, render().Data); diff != "" {
+			t.Errorf("config map mismatch for the same seed (-first +second):\n%s", diff)
+		}
+	})
+}
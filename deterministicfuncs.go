@@ -0,0 +1,453 @@
+package helmut
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The characters of the random strings.
+const (
+	alphaChars        = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	numericChars      = "0123456789"
+	alphaNumericChars = alphaChars + numericChars
+	asciiChars        = " !\"#$%&'()*+,-./" + numericChars + ":;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+)
+
+// The key sizes of sprig.
+const (
+	privateKeyRSABits  = 4096
+	certificateRSABits = 2048
+)
+
+// rsaKeyFiles contains the pregenerated RSA keys of the deterministic functions.
+// Go does not generate RSA keys from a given random source, so the keys cannot be derived from the seeds.
+//
+//go:embed keys/rsa*.pem
+var rsaKeyFiles embed.FS
+
+// rsaKeys returns the pregenerated RSA keys keyed by their bits, which are parsed only once.
+var rsaKeys = sync.OnceValues(func() (map[int][]*rsa.PrivateKey, error) {
+	keys := make(map[int][]*rsa.PrivateKey)
+
+	for _, bits := range []int{certificateRSABits, privateKeyRSABits} {
+		data, err := rsaKeyFiles.ReadFile(fmt.Sprintf("keys/rsa%d.pem", bits))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rsa keys: %w", err)
+		}
+
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse rsa key: %w", err)
+			}
+
+			keys[bits] = append(keys[bits], key)
+		}
+	}
+
+	return keys, nil
+})
+
+// deterministicAgo returns the duration from the time to the current time like "ago" of sprig.
+func deterministicAgo(now time.Time, date interface{}) string {
+	t := now
+
+	switch date := date.(type) {
+	case time.Time:
+		t = date
+	case int64:
+		t = time.Unix(date, 0)
+	case int:
+		t = time.Unix(int64(date), 0)
+	}
+
+	return now.Sub(t).Round(time.Second).String()
+}
+
+// randString returns a random string of the count characters chosen from chars,
+// like "randAlphaNum", "randAlpha", "randNumeric" and "randAscii" of sprig.
+func (c *deterministicCall) randString(chars string, count int) string {
+	b := make([]byte, 0, count)
+
+	for i := 0; i < count; i++ {
+		b = append(b, chars[c.rand.IntN(len(chars))])
+	}
+
+	return string(b)
+}
+
+// randBytes returns the base64 encoded random bytes of the count like "randBytes" of sprig.
+func (c *deterministicCall) randBytes(count int) (string, error) {
+	if count < 0 {
+		return "", fmt.Errorf("invalid count %d", count)
+	}
+
+	b := make([]byte, count)
+
+	_, _ = c.source.Read(b)
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// randInt returns a random integer in [min, max) like "randInt" of sprig.
+func (c *deterministicCall) randInt(min, max int) (int, error) {
+	if max <= min {
+		return 0, fmt.Errorf("invalid range [%d, %d)", min, max)
+	}
+
+	return c.rand.IntN(max-min) + min, nil
+}
+
+// shuffle returns the string whose characters are shuffled like "shuffle" of sprig.
+func (c *deterministicCall) shuffle(s string) string {
+	runes := []rune(s)
+
+	c.rand.Shuffle(len(runes), func(i, j int) {
+		runes[i], runes[j] = runes[j], runes[i]
+	})
+
+	return string(runes)
+}
+
+// uuid returns a random UUID of version 4 like "uuidv4" of sprig.
+func (c *deterministicCall) uuid() (string, error) {
+	id, err := uuid.NewRandomFromReader(c.source)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate UUID: %w", err)
+	}
+
+	return id.String(), nil
+}
+
+// privateKey generates a private key like "genPrivateKey" of sprig.
+// DSA is not supported because its parameters cannot be generated deterministically.
+func (c *deterministicCall) privateKey(typ string) (string, error) {
+	var (
+		priv crypto.Signer
+		err  error
+	)
+
+	switch typ {
+	case "", "rsa":
+		priv, err = c.rsaKey(privateKeyRSABits)
+	case "ecdsa":
+		priv, err = c.ecdsaKey()
+	case "ed25519":
+		priv = c.ed25519Key()
+	case "dsa":
+		return "", errors.New("dsa keys are not supported by the deterministic functions")
+	default:
+		return "Unknown type " + typ, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	block, err := pemBlockForKey(priv)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// ca generates a CA certificate like "genCA" and "genCAWithKey" of sprig.
+// The key is generated if privPEM is empty.
+func (c *deterministicCall) ca(cn string, days int, privPEM string) (deterministicCertificate, error) {
+	priv, err := c.certificateKey(privPEM)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	template, err := c.certificateTemplate(cn, nil, nil, days)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	template.KeyUsage = x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	template.IsCA = true
+
+	return c.certificate(template, priv, template, priv)
+}
+
+// selfSignedCert generates a self-signed certificate like "genSelfSignedCert" and "genSelfSignedCertWithKey" of sprig.
+// The key is generated if privPEM is empty.
+func (c *deterministicCall) selfSignedCert(
+	cn string,
+	ips, dnsNames []interface{},
+	days int,
+	privPEM string,
+) (deterministicCertificate, error) {
+	template, err := c.certificateTemplate(cn, ips, dnsNames, days)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	priv, err := c.certificateKey(privPEM)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	return c.certificate(template, priv, template, priv)
+}
+
+// signedCert generates a certificate signed by the CA like "genSignedCert" and "genSignedCertWithKey" of sprig.
+// The key is generated if privPEM is empty.
+func (c *deterministicCall) signedCert(
+	cn string,
+	ips, dnsNames []interface{},
+	days int,
+	ca deterministicCertificate,
+	privPEM string,
+) (deterministicCertificate, error) {
+	template, err := c.certificateTemplate(cn, ips, dnsNames, days)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	block, _ := pem.Decode([]byte(ca.Cert))
+	if block == nil {
+		return deterministicCertificate{}, errors.New("unable to decode certificate")
+	}
+
+	parent, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return deterministicCertificate{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	signer, err := parsePrivateKeyPEM(ca.Key)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	priv, err := c.certificateKey(privPEM)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	return c.certificate(template, priv, parent, signer)
+}
+
+// certificateKey returns the private key of the certificate,
+// which is the PEM key or an RSA key chosen by rsaKey if the PEM key is empty.
+func (c *deterministicCall) certificateKey(privPEM string) (crypto.Signer, error) {
+	if len(privPEM) == 0 {
+		return c.rsaKey(certificateRSABits)
+	}
+
+	return parsePrivateKeyPEM(privPEM)
+}
+
+// certificateTemplate returns the template of the certificate like sprig,
+// whose serial number is random and which is valid from the current time.
+func (c *deterministicCall) certificateTemplate(cn string, ips, dnsNames []interface{}, days int) (*x509.Certificate, error) {
+	// The serial number has 128 bits like sprig.
+	serial := make([]byte, 16)
+
+	_, _ = c.source.Read(serial)
+
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		IPAddresses:           []net.IP{},
+		DNSNames:              []string{},
+		NotBefore:             c.now,
+		NotAfter:              c.now.Add(time.Hour * 24 * time.Duration(days)),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, ip := range ips {
+		s, ok := ip.(string)
+		if !ok {
+			return nil, fmt.Errorf("error parsing ip: %v is not a string", ip)
+		}
+
+		netIP := net.ParseIP(s)
+		if netIP == nil {
+			return nil, fmt.Errorf("error parsing ip: %s", s)
+		}
+
+		template.IPAddresses = append(template.IPAddresses, netIP)
+	}
+
+	for _, dns := range dnsNames {
+		s, ok := dns.(string)
+		if !ok {
+			return nil, fmt.Errorf("error processing alternate dns name: %v is not a string", dns)
+		}
+
+		template.DNSNames = append(template.DNSNames, s)
+	}
+
+	return template, nil
+}
+
+// certificate creates the certificate and returns it with the key in PEM like the certificates of sprig.
+func (c *deterministicCall) certificate(
+	template *x509.Certificate,
+	priv crypto.Signer,
+	parent *x509.Certificate,
+	signer crypto.Signer,
+) (deterministicCertificate, error) {
+	der, err := x509.CreateCertificate(c.source, template, parent, priv.Public(), deterministicSigner{signer})
+	if err != nil {
+		return deterministicCertificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	block, err := pemBlockForKey(priv)
+	if err != nil {
+		return deterministicCertificate{}, err
+	}
+
+	return deterministicCertificate{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  string(pem.EncodeToMemory(block)),
+	}, nil
+}
+
+// keySource returns the random bytes for the keys, which are independent of the other random values of the call.
+func (c *deterministicCall) keySource() *rand.ChaCha8 {
+	return rand.NewChaCha8(sha256.Sum256(append(c.seed[:], "key"...)))
+}
+
+// rsaKey returns one of the pregenerated RSA keys of the bits chosen by the seed of the call.
+// The keys already returned during the rendering are skipped while any key remains,
+// so that the keys of a release are different.
+func (c *deterministicCall) rsaKey(bits int) (*rsa.PrivateKey, error) {
+	keys, err := rsaKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := keys[bits]
+
+	var b [8]byte
+
+	_, _ = c.keySource().Read(b[:])
+
+	start := int(binary.BigEndian.Uint64(b[:]) % uint64(len(pool)))
+	index := start
+
+	for i := 1; i < len(pool) && c.usedRSAKeys[rsaKeyID{bits: bits, index: index}]; i++ {
+		index = (start + i) % len(pool)
+	}
+
+	c.usedRSAKeys[rsaKeyID{bits: bits, index: index}] = true
+
+	return pool[index], nil
+}
+
+// ecdsaKey generates the ECDSA key on the P-256 curve like sprig from the seed of the call.
+func (c *deterministicCall) ecdsaKey() (*ecdsa.PrivateKey, error) {
+	source := c.keySource()
+	d := make([]byte, 32)
+
+	for {
+		_, _ = source.Read(d)
+
+		// The scalar out of the order of the curve is rejected, which is unlikely.
+		if key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), d); err == nil {
+			return key, nil
+		}
+	}
+}
+
+// ed25519Key generates the ed25519 key from the seed of the call.
+func (c *deterministicCall) ed25519Key() ed25519.PrivateKey {
+	seed := make([]byte, ed25519.SeedSize)
+
+	_, _ = c.keySource().Read(seed)
+
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// deterministicSigner signs without the random source,
+// so that the ECDSA signatures are generated deterministically as RFC 6979.
+type deterministicSigner struct {
+	crypto.Signer
+}
+
+// Sign implements crypto.Signer.
+func (s deterministicSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.Signer.Sign(nil, digest, opts)
+}
+
+// pemBlockForKey returns the PEM block of the private key in the format of sprig.
+func pemBlockForKey(priv crypto.Signer) (*pem.Block, error) {
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}, nil
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ecdsa key: %w", err)
+		}
+
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}, nil
+	default:
+		b, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal private key: %w", err)
+		}
+
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: b}, nil
+	}
+}
+
+// parsePrivateKeyPEM parses the PEM private key of RSA, ECDSA or PKCS #8.
+func parsePrivateKeyPEM(privPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privPEM))
+	if block == nil {
+		return nil, errors.New("no PEM data in input")
+	}
+
+	var (
+		priv interface{}
+		err  error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %s", block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", priv)
+	}
+
+	return signer, nil
+}
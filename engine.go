@@ -0,0 +1,535 @@
+package helmut
+
+// The rendering of this file is a copy of the engine package of helm v3, Copyright The Helm Authors,
+// licensed under the Apache License, Version 2.0.
+// Helm does not allow to override the template functions of engine.Engine,
+// so the templates are rendered once by this copy when the functions must be replaced,
+// and the release is built from the outputs by renderDeterministic.
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/sprig/v3"
+	"github.com/gobwas/glob"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"sigs.k8s.io/yaml"
+	goyaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+const (
+	engineWarnStartDelim   = "HELM_ERR_START"
+	engineWarnEndDelim     = "HELM_ERR_END"
+	engineRecursionMaxNums = 1000
+	engineNoValue          = "<no value>"
+)
+
+var engineWarnRegex = regexp.MustCompile(engineWarnStartDelim + `((?s).*)` + engineWarnEndDelim)
+
+// templateEngine renders the templates of a chart in the same way as engine.Engine of helm for "helm template",
+// except that the template functions are overridden by funcs.
+// Like "helm template", "lookup" returns an empty map and "getHostByName" returns an empty string.
+type templateEngine struct {
+	// funcs overrides the template functions of helm.
+	funcs template.FuncMap
+
+	// beforeExecute is called with the name of each template before executing it, if not nil.
+	beforeExecute func(name string)
+
+	// execute reports whether the template is executed, if not nil.
+	// The other templates are only parsed to define the named templates.
+	execute func(name string) bool
+}
+
+// renderable is a template with the values supplied to it.
+type renderable struct {
+	tpl      string
+	vals     chartutil.Values
+	basePath string
+}
+
+// Render renders the templates of the chart and its subcharts with the values,
+// and returns the outputs keyed by the template names.
+// This is equivalent to engine.Engine.Render.
+func (e templateEngine) Render(c *chart.Chart, values chartutil.Values) (map[string]string, error) {
+	templates := make(map[string]renderable)
+	collectRenderables(c, templates, values)
+
+	return e.render(templates)
+}
+
+// render parses all the templates and executes them except the partials, like engine.Engine.render.
+func (e templateEngine) render(templates map[string]renderable) (rendered map[string]string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("rendering template failed: %v", r)
+		}
+	}()
+
+	t := template.New("gotpl")
+	t.Option("missingkey=zero")
+
+	e.initFuncMap(t)
+
+	keys := sortTemplates(templates)
+
+	for _, filename := range keys {
+		if _, err := t.New(filename).Parse(templates[filename].tpl); err != nil {
+			return map[string]string{}, cleanupParseError(filename, err)
+		}
+	}
+
+	rendered = make(map[string]string, len(keys))
+
+	for _, filename := range keys {
+		// The partials are only included from the other templates.
+		if strings.HasPrefix(path.Base(filename), "_") || (e.execute != nil && !e.execute(filename)) {
+			continue
+		}
+
+		if e.beforeExecute != nil {
+			e.beforeExecute(filename)
+		}
+
+		vals := templates[filename].vals
+		vals["Template"] = chartutil.Values{"Name": filename, "BasePath": templates[filename].basePath}
+
+		var buf strings.Builder
+
+		if err := t.ExecuteTemplate(&buf, filename, vals); err != nil {
+			return map[string]string{}, cleanupExecError(filename, err)
+		}
+
+		rendered[filename] = strings.ReplaceAll(buf.String(), engineNoValue, "")
+	}
+
+	return rendered, nil
+}
+
+// initFuncMap adds the functions of helm and the overriding functions to the template.
+func (e templateEngine) initFuncMap(t *template.Template) {
+	funcMap := helmFuncMap()
+	includedNames := make(map[string]int)
+
+	funcMap["include"] = includeFunc(t, includedNames)
+	funcMap["tpl"] = tplFunc(t, includedNames)
+
+	funcMap["required"] = func(warn string, val interface{}) (interface{}, error) {
+		if val == nil {
+			return val, errors.New(engineWarnStartDelim + warn + engineWarnEndDelim)
+		} else if s, ok := val.(string); ok && s == "" {
+			return val, errors.New(engineWarnStartDelim + warn + engineWarnEndDelim)
+		}
+
+		return val, nil
+	}
+
+	funcMap["fail"] = func(msg string) (string, error) {
+		return "", errors.New(engineWarnStartDelim + msg + engineWarnEndDelim)
+	}
+
+	// DNS lookups are disabled like "helm template".
+	funcMap["getHostByName"] = func(string) string {
+		return ""
+	}
+
+	for name, fn := range e.funcs {
+		funcMap[name] = fn
+	}
+
+	t.Funcs(funcMap)
+}
+
+// includeFunc returns the "include" function, which executes the named template with the data.
+// The nested calls of the same template are limited like helm.
+func includeFunc(t *template.Template, includedNames map[string]int) func(string, interface{}) (string, error) {
+	return func(name string, data interface{}) (string, error) {
+		var buf strings.Builder
+
+		if v, ok := includedNames[name]; ok {
+			if v > engineRecursionMaxNums {
+				return "", fmt.Errorf("rendering template has a nested reference name: %s: unable to execute template", name)
+			}
+
+			includedNames[name]++
+		} else {
+			includedNames[name] = 1
+		}
+
+		err := t.ExecuteTemplate(&buf, name, data)
+		includedNames[name]--
+
+		return buf.String(), err
+	}
+}
+
+// tplFunc returns the "tpl" function, which renders the string as a template with the values
+// in a clone of the parent template, so that the named templates can be included.
+func tplFunc(parent *template.Template, includedNames map[string]int) func(string, interface{}) (string, error) {
+	return func(tpl string, vals interface{}) (string, error) {
+		t, err := parent.Clone()
+		if err != nil {
+			return "", fmt.Errorf("cannot clone template: %w", err)
+		}
+
+		t.Option("missingkey=zero")
+
+		t.Funcs(template.FuncMap{
+			"include": includeFunc(t, includedNames),
+			"tpl":     tplFunc(t, includedNames),
+		})
+
+		t, err = t.New(parent.Name()).Parse(tpl)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse template %q: %w", tpl, err)
+		}
+
+		var buf strings.Builder
+
+		if err := t.Execute(&buf, vals); err != nil {
+			return "", fmt.Errorf("error during tpl function execution for %q: %w", tpl, err)
+		}
+
+		return strings.ReplaceAll(buf.String(), engineNoValue, ""), nil
+	}
+}
+
+// cleanupParseError returns the parse error of the template in the format of helm.
+func cleanupParseError(filename string, err error) error {
+	tokens := strings.Split(err.Error(), ": ")
+	if len(tokens) == 1 {
+		return fmt.Errorf("parse error in (%s): %s", filename, err)
+	}
+
+	location := tokens[1]
+	errMsg := tokens[len(tokens)-1]
+
+	return fmt.Errorf("parse error at (%s): %s", location, errMsg)
+}
+
+// cleanupExecError returns the execution error of the template in the format of helm,
+// which has the message of "required" and "fail" without the delimiters.
+func cleanupExecError(filename string, err error) error {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return err
+	}
+
+	tokens := strings.SplitN(err.Error(), ": ", 3)
+	if len(tokens) != 3 {
+		return fmt.Errorf("execution error in (%s): %s", filename, err)
+	}
+
+	location := tokens[1]
+
+	parts := engineWarnRegex.FindStringSubmatch(tokens[2])
+	if len(parts) >= 2 {
+		return fmt.Errorf("execution error at (%s): %s", location, parts[1])
+	}
+
+	return err
+}
+
+// sortTemplates sorts the template names in the order that favors higher-level templates like helm.
+func sortTemplates(templates map[string]renderable) []string {
+	keys := make([]string, 0, len(templates))
+
+	for key := range templates {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+
+		ca, cb := strings.Count(a, "/"), strings.Count(b, "/")
+		if ca == cb {
+			return a > b
+		}
+
+		return ca > cb
+	})
+
+	return keys
+}
+
+// collectRenderables collects the templates of the chart and its subcharts with the values scoped to each chart.
+func collectRenderables(c *chart.Chart, templates map[string]renderable, vals chartutil.Values) map[string]interface{} {
+	subCharts := make(map[string]interface{})
+	chartMetaData := struct {
+		chart.Metadata
+		IsRoot bool
+	}{*c.Metadata, c.IsRoot()}
+
+	next := map[string]interface{}{
+		"Chart":        chartMetaData,
+		"Files":        newTemplateFiles(c.Files),
+		"Release":      vals["Release"],
+		"Capabilities": vals["Capabilities"],
+		"Values":       make(chartutil.Values),
+		"Subcharts":    subCharts,
+	}
+
+	if c.IsRoot() {
+		next["Values"] = vals["Values"]
+	} else if vs, err := vals.Table("Values." + c.Name()); err == nil {
+		next["Values"] = vs
+	}
+
+	for _, child := range c.Dependencies() {
+		subCharts[child.Name()] = collectRenderables(child, templates, next)
+	}
+
+	parentID := c.ChartFullPath()
+
+	for _, t := range c.Templates {
+		if t == nil {
+			continue
+		}
+
+		// The library charts provide only the partials.
+		if strings.EqualFold(c.Metadata.Type, "library") && !strings.HasPrefix(filepath.Base(t.Name), "_") {
+			continue
+		}
+
+		templates[path.Join(parentID, t.Name)] = renderable{
+			tpl:      string(t.Data),
+			vals:     next,
+			basePath: path.Join(parentID, "templates"),
+		}
+	}
+
+	return next
+}
+
+// templateFiles is the files of a chart that can be accessed by ".Files" of the templates.
+type templateFiles map[string][]byte
+
+// newTemplateFiles returns the files keyed by their names.
+func newTemplateFiles(from []*chart.File) templateFiles {
+	files := make(templateFiles)
+
+	for _, f := range from {
+		files[f.Name] = f.Data
+	}
+
+	return files
+}
+
+// GetBytes returns the content of the file, or an empty slice if not found.
+func (f templateFiles) GetBytes(name string) []byte {
+	if v, ok := f[name]; ok {
+		return v
+	}
+
+	return []byte{}
+}
+
+// Get returns the content of the file as a string.
+func (f templateFiles) Get(name string) string {
+	return string(f.GetBytes(name))
+}
+
+// Glob returns the files that match the pattern.
+func (f templateFiles) Glob(pattern string) templateFiles {
+	g, err := glob.Compile(pattern, '/')
+	if err != nil {
+		g, _ = glob.Compile("**")
+	}
+
+	nf := newTemplateFiles(nil)
+
+	for name, contents := range f {
+		if g.Match(name) {
+			nf[name] = contents
+		}
+	}
+
+	return nf
+}
+
+// AsConfig returns the files as the YAML map of the data of a ConfigMap.
+func (f templateFiles) AsConfig() string {
+	if f == nil {
+		return ""
+	}
+
+	m := make(map[string]string)
+
+	for k, v := range f {
+		m[path.Base(k)] = string(v)
+	}
+
+	return toYAML(m)
+}
+
+// AsSecrets returns the files as the YAML map of the data of a Secret.
+func (f templateFiles) AsSecrets() string {
+	if f == nil {
+		return ""
+	}
+
+	m := make(map[string]string)
+
+	for k, v := range f {
+		m[path.Base(k)] = base64.StdEncoding.EncodeToString(v)
+	}
+
+	return toYAML(m)
+}
+
+// Lines returns the lines of the file.
+func (f templateFiles) Lines(path string) []string {
+	if f == nil || len(f[path]) == 0 {
+		return []string{}
+	}
+
+	s := string(f[path])
+	if s[len(s)-1] == '\n' {
+		s = s[:len(s)-1]
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// helmFuncMap returns the template functions of helm, which are the functions of sprig
+// without "env" and "expandenv", and the functions added by helm.
+func helmFuncMap() template.FuncMap {
+	f := sprig.TxtFuncMap()
+	delete(f, "env")
+	delete(f, "expandenv")
+
+	extra := template.FuncMap{
+		"toToml":        toTOML,
+		"fromToml":      fromTOML,
+		"toYaml":        toYAML,
+		"toYamlPretty":  toYAMLPretty,
+		"fromYaml":      fromYAML,
+		"fromYamlArray": fromYAMLArray,
+		"toJson":        toJSON,
+		"fromJson":      fromJSON,
+		"fromJsonArray": fromJSONArray,
+		"include":       func(string, interface{}) string { return "not implemented" },
+		"tpl":           func(string, interface{}) interface{} { return "not implemented" },
+		"required":      func(string, interface{}) (interface{}, error) { return "not implemented", nil },
+		"lookup": func(string, string, string, string) (map[string]interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	}
+
+	for k, v := range extra {
+		f[k] = v
+	}
+
+	return f
+}
+
+// toYAML returns the YAML of the value, or an empty string if it cannot be marshaled.
+func toYAML(v interface{}) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// toYAMLPretty returns the YAML of the value indented by two spaces, or an empty string if it cannot be marshaled.
+func toYAMLPretty(v interface{}) string {
+	var data bytes.Buffer
+
+	encoder := goyaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(v); err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(data.String(), "\n")
+}
+
+// fromYAML returns the map of the YAML, whose "Error" is the error if it cannot be unmarshaled.
+func fromYAML(str string) map[string]interface{} {
+	m := map[string]interface{}{}
+
+	if err := yaml.Unmarshal([]byte(str), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+
+	return m
+}
+
+// fromYAMLArray returns the array of the YAML, which has only the error if it cannot be unmarshaled.
+func fromYAMLArray(str string) []interface{} {
+	a := []interface{}{}
+
+	if err := yaml.Unmarshal([]byte(str), &a); err != nil {
+		a = []interface{}{err.Error()}
+	}
+
+	return a
+}
+
+// toTOML returns the TOML of the value, or the error message if it cannot be encoded.
+func toTOML(v interface{}) string {
+	b := bytes.NewBuffer(nil)
+
+	if err := toml.NewEncoder(b).Encode(v); err != nil {
+		return err.Error()
+	}
+
+	return b.String()
+}
+
+// fromTOML returns the map of the TOML, whose "Error" is the error if it cannot be unmarshaled.
+func fromTOML(str string) map[string]interface{} {
+	m := make(map[string]interface{})
+
+	if err := toml.Unmarshal([]byte(str), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+
+	return m
+}
+
+// toJSON returns the JSON of the value, or an empty string if it cannot be marshaled.
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// fromJSON returns the map of the JSON, whose "Error" is the error if it cannot be unmarshaled.
+func fromJSON(str string) map[string]interface{} {
+	m := make(map[string]interface{})
+
+	if err := json.Unmarshal([]byte(str), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+
+	return m
+}
+
+// fromJSONArray returns the array of the JSON, which has only the error if it cannot be unmarshaled.
+func fromJSONArray(str string) []interface{} {
+	a := []interface{}{}
+
+	if err := json.Unmarshal([]byte(str), &a); err != nil {
+		a = []interface{}{err.Error()}
+	}
+
+	return a
+}
@@ -0,0 +1,143 @@
+package helmut
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"helm.sh/helm/v3/pkg/cli"
+)
+
+// errRendererClosed is returned when the Renderer is used after closing.
+var errRendererClosed = errors.New("the Renderer is already closed")
+
+// newSettings creates and returns the helm settings based on the options.
+// Unless WithHostEnvironment is specified, the settings point to a hermetic environment
+// that does not depend on the "HELM_*" environment variables and the files of the host.
+//
+// If WithHelmHome is not specified, the hermetic environment has no helm home until the Renderer needs one,
+// so that rendering local charts does not create any directories. See Renderer.ensureHome.
+func newSettings(opts *rendererOption) (*cli.EnvSettings, error) {
+	settings := cli.New()
+
+	if opts.hostEnvironment {
+		return settings, nil
+	}
+
+	// Reset all settings read from the "HELM_*" environment variables.
+	settings.KubeConfig = ""
+	settings.KubeContext = ""
+	settings.KubeToken = ""
+	settings.KubeAsUser = ""
+	settings.KubeAsGroups = nil
+	settings.KubeAPIServer = ""
+	settings.KubeCaFile = ""
+	settings.KubeInsecureSkipTLSVerify = false
+	settings.KubeTLSServerName = ""
+	settings.Debug = false
+	settings.RegistryConfig = ""
+	settings.RepositoryConfig = ""
+	settings.RepositoryCache = ""
+	settings.PluginsDirectory = ""
+
+	if len(opts.helmHome) != 0 {
+		if err := setHelmHome(settings, opts.helmHome); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.repositoryConfig) != 0 {
+		settings.RepositoryConfig = opts.repositoryConfig
+	}
+
+	if len(opts.registryConfig) != 0 {
+		settings.RegistryConfig = opts.registryConfig
+	}
+
+	return settings, nil
+}
+
+// setHelmHome creates the config, cache and data directories in the helm home,
+// and points the settings that have not been specified by the options to them.
+func setHelmHome(settings *cli.EnvSettings, home string) error {
+	home, err := filepath.Abs(home)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	var (
+		configHome = filepath.Join(home, "config")
+		cacheHome  = filepath.Join(home, "cache")
+		dataHome   = filepath.Join(home, "data")
+	)
+
+	for _, dir := range []string{configHome, cacheHome, dataHome} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create a directory: %w", err)
+		}
+	}
+
+	if len(settings.RegistryConfig) == 0 {
+		settings.RegistryConfig = filepath.Join(configHome, "registry.json")
+	}
+
+	if len(settings.RepositoryConfig) == 0 {
+		settings.RepositoryConfig = filepath.Join(configHome, "repositories.yaml")
+	}
+
+	settings.RepositoryCache = filepath.Join(cacheHome, "repository")
+	settings.PluginsDirectory = filepath.Join(dataHome, "plugins")
+
+	return nil
+}
+
+// ensureHome creates a temporary helm home for the hermetic environment if it does not have one yet.
+// It must be called before the settings refer to the files of the helm home,
+// such as downloading charts from repositories and registries.
+func (r *Renderer) ensureHome() error {
+	r.homeOnce.Do(func() {
+		if r.opts.hostEnvironment || len(r.opts.helmHome) != 0 {
+			return
+		}
+
+		if r.closed.Load() {
+			r.homeErr = errRendererClosed
+
+			return
+		}
+
+		dir, err := os.MkdirTemp("", "helmut-")
+		if err != nil {
+			r.homeErr = fmt.Errorf("failed to create a temporary directory: %w", err)
+
+			return
+		}
+
+		r.tempDir = dir
+
+		// The settings may be read by concurrent renders, so a copy is modified and replaces them.
+		settings := *r.settings.Load()
+
+		if err := setHelmHome(&settings, dir); err != nil {
+			r.homeErr = err
+
+			return
+		}
+
+		r.settings.Store(&settings)
+	})
+
+	return r.homeErr
+}
+
+// prepare initializes the helm environment of the Renderer, and returns an error if the Renderer cannot be used.
+func (r *Renderer) prepare() error {
+	if r.closed.Load() {
+		return errRendererClosed
+	}
+
+	r.envOnce.Do(r.initEnvironment)
+
+	return r.err
+}
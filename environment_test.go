@@ -0,0 +1,183 @@
+package helmut_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+)
+
+//nolint:paralleltest // t.Setenv cannot be used in parallel tests.
+func TestHermeticEnvironment(t *testing.T) {
+	hostDir := t.TempDir()
+
+	t.Setenv("HELM_REPOSITORY_CONFIG", filepath.Join(hostDir, "repositories.yaml"))
+	t.Setenv("HELM_REPOSITORY_CACHE", filepath.Join(hostDir, "repository"))
+	t.Setenv("HELM_REGISTRY_CONFIG", filepath.Join(hostDir, "registry.json"))
+	t.Setenv("HELM_PLUGINS", filepath.Join(hostDir, "plugins"))
+	t.Setenv("HELM_DEBUG", "true")
+
+	t.Run("hermetic", func(t *testing.T) {
+		r := helmut.New()
+
+		settings := r.Settings()
+
+		for _, path := range []string{
+			settings.RepositoryConfig,
+			settings.RepositoryCache,
+			settings.RegistryConfig,
+			settings.PluginsDirectory,
+		} {
+			if strings.HasPrefix(path, hostDir) {
+				t.Errorf("%s refers to the host environment", path)
+			}
+		}
+
+		if settings.Debug {
+			t.Error("debug is enabled by the host environment")
+		}
+
+		home := filepath.Dir(filepath.Dir(settings.RepositoryConfig))
+
+		if _, err := os.Stat(home); err != nil {
+			t.Fatalf("failed to stat helm home: %s", err)
+		}
+
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close renderer: %s", err)
+		}
+
+		if _, err := os.Stat(home); !os.IsNotExist(err) {
+			t.Errorf("helm home was not removed: %s", home)
+		}
+	})
+
+	t.Run("helm home", func(t *testing.T) {
+		home := t.TempDir()
+		r := newRenderer(t, helmut.WithHelmHome(home))
+
+		if got := r.Settings().RepositoryCache; !strings.HasPrefix(got, home) {
+			t.Errorf("repository cache %s is not in %s", got, home)
+		}
+	})
+
+	t.Run("repository and registry config", func(t *testing.T) {
+		r := newRenderer(t,
+			helmut.WithRepositoryConfig("testdata/repositories.yaml"),
+			helmut.WithRegistryConfig("testdata/registry.json"),
+		)
+
+		settings := r.Settings()
+
+		if got := settings.RepositoryConfig; got != "testdata/repositories.yaml" {
+			t.Errorf("got repository config %s, want testdata/repositories.yaml", got)
+		}
+
+		if got := settings.RegistryConfig; got != "testdata/registry.json" {
+			t.Errorf("got registry config %s, want testdata/registry.json", got)
+		}
+	})
+
+	t.Run("host environment", func(t *testing.T) {
+		r := newRenderer(t, helmut.WithHostEnvironment())
+
+		if got, want := r.Settings().RepositoryConfig, filepath.Join(hostDir, "repositories.yaml"); got != want {
+			t.Errorf("got repository config %s, want %s", got, want)
+		}
+	})
+
+	t.Run("local chart", func(t *testing.T) {
+		tempDir := t.TempDir()
+		t.Setenv("TMPDIR", tempDir)
+
+		r := helmut.New()
+
+		if _, err := r.RenderTemplates("foo", filepath.Join("testdata", "test-chart")); err != nil {
+			t.Fatalf("failed to render templates: %s", err)
+		}
+
+		entries, err := os.ReadDir(tempDir)
+		if err != nil {
+			t.Fatalf("failed to read temporary directory: %s", err)
+		}
+
+		if len(entries) != 0 {
+			t.Errorf("got %d temporary directories, want none for local charts", len(entries))
+		}
+
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close renderer: %s", err)
+		}
+	})
+
+	t.Run("render after close", func(t *testing.T) {
+		r := helmut.New()
+
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close renderer: %s", err)
+		}
+
+		if _, err := r.RenderTemplates("foo", filepath.Join("testdata", "test-chart")); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+
+	t.Run("render again after close", func(t *testing.T) {
+		r := helmut.New()
+		chart := filepath.Join("testdata", "test-chart")
+
+		if _, err := r.RenderTemplates("foo", chart); err != nil {
+			t.Fatalf("failed to render templates: %s", err)
+		}
+
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close renderer: %s", err)
+		}
+
+		if _, err := r.RenderTemplates("foo", chart); err == nil {
+			t.Error("expected error, but got nil")
+		}
+	})
+}
+
+func TestHermeticEnvironmentConcurrentRenders(t *testing.T) {
+	t.Parallel()
+
+	r := newRenderer(t)
+
+	var wg sync.WaitGroup
+
+	// The helm home is created while the other renders read the settings.
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		_ = r.Settings()
+	}()
+
+	errs := make(chan error, 4)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := r.RenderTemplates("foo", filepath.Join("testdata", "test-chart"))
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("failed to render templates: %s", err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package helmut
+
+import (
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/cli"
+)
+
+// LoadChart exports the chart loading of the Renderer for testing.
+func (r *Renderer) LoadChart(path string) (*chart.Chart, error) {
+	r.once.Do(r.init)
+
+	return r.charts.Load(path)
+}
+
+// Settings exports the helm settings of the Renderer for testing.
+// The helm home of the hermetic environment is created as if it were needed.
+func (r *Renderer) Settings() *cli.EnvSettings {
+	r.envOnce.Do(r.initEnvironment)
+
+	if err := r.ensureHome(); err != nil {
+		panic(err)
+	}
+
+	return r.settings.Load()
+}
+
+// InstrumentTemplate exports the instrumentation of the template coverage for testing.
+func InstrumentTemplate(name, source string) (string, error) {
+	instrumented, _, err := instrumentTemplate(name, source, 0)
+
+	return instrumented, err
+}
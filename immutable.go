@@ -0,0 +1,290 @@
+package helmut
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// UpgradeReport is the report of the changes that break "helm upgrade" between two manifests.
+type UpgradeReport struct {
+	// ImmutableFields is the changed field paths of the immutable fields, keyed by the object of the current manifests.
+	// e.g. "spec.selector.matchLabels.app", "spec.clusterIP"
+	ImmutableFields map[ObjectKey][]string
+
+	// Recreated is a list of objects whose kind or name is changed,
+	// which are the objects of the same name whose kind or API group is changed and the objects declared by WithRenamed.
+	// Helm deletes the previous object and creates the current object instead of updating it.
+	Recreated []Recreation
+}
+
+// Recreation is an object that is deleted and recreated because its kind or name is changed.
+type Recreation struct {
+	Previous ObjectKey
+	Current  ObjectKey
+}
+
+// Empty returns true if there is no change that breaks the upgrade.
+func (r *UpgradeReport) Empty() bool {
+	return len(r.ImmutableFields) == 0 && len(r.Recreated) == 0
+}
+
+// immutableFields is the field paths that cannot be updated, keyed by "group/kind".
+var immutableFields = map[string][]string{
+	"apps/Deployment":  {"spec.selector"},
+	"apps/DaemonSet":   {"spec.selector"},
+	"apps/ReplicaSet":  {"spec.selector"},
+	"apps/StatefulSet": {"spec.selector", "spec.volumeClaimTemplates", "spec.serviceName", "spec.podManagementPolicy"},
+	"batch/Job":        {"spec.selector", "spec.template"},
+	"/Service":         {"spec.clusterIP", "spec.clusterIPs"},
+	"/Secret":          {"type"},
+	"/PersistentVolumeClaim": {
+		"spec.accessModes", "spec.selector", "spec.storageClassName",
+		"spec.volumeMode", "spec.volumeName", "spec.dataSource",
+	},
+	"rbac.authorization.k8s.io/RoleBinding":        {"roleRef"},
+	"rbac.authorization.k8s.io/ClusterRoleBinding": {"roleRef"},
+	"storage.k8s.io/StorageClass":                  {"provisioner", "parameters", "reclaimPolicy", "volumeBindingMode"},
+}
+
+// immutableDataFields is the field paths that cannot be updated if the object is marked as "immutable: true".
+var immutableDataFields = map[string][]string{
+	"/ConfigMap": {"data", "binaryData"},
+	"/Secret":    {"data", "stringData"},
+}
+
+// UpgradeCheckOption is the option used when checking the upgrade between two manifests.
+type UpgradeCheckOption func(*upgradeCheckOption)
+
+// upgradeCheckOption stores the options for the upgrade check.
+type upgradeCheckOption struct {
+	renames map[ObjectKey]ObjectKey
+}
+
+// WithRenamed is an option to declare that the previous object is replaced by the current object,
+// such as renaming a Deployment or changing it to a StatefulSet of another name.
+// The API versions of the keys are ignored.
+func WithRenamed(previous, current ObjectKey) UpgradeCheckOption {
+	return func(o *upgradeCheckOption) {
+		if o.renames == nil {
+			o.renames = make(map[ObjectKey]ObjectKey)
+		}
+
+		o.renames[versionlessKey(previous)] = versionlessKey(current)
+	}
+}
+
+// CheckUpgrade compares the manifests rendered by the previous chart with the manifests rendered by the current chart,
+// and returns the changes that make "helm upgrade" fail or recreate objects.
+//
+// The following changes are reported:
+//   changes to the known immutable fields, such as the selector of a Deployment and the clusterIP of a Service
+//   changes to the data of a ConfigMap or a Secret marked as immutable
+//   objects whose kind or API group is changed with the same namespace and name, such as a Deployment changed to a StatefulSet
+//   objects whose kind or name is changed, which are declared by WithRenamed
+//
+// A removed object and an added object of the same namespace and name are paired automatically,
+// unless more than one object of the name is removed or added, since the pairs cannot be told apart.
+// Renamed objects are not paired automatically, because they cannot be told apart from an unrelated deletion and addition.
+// Declare the replacements with WithRenamed to report them as recreations.
+// It is an error if the previous object of a replacement is not removed, or the current object is not added.
+//
+// Objects are matched regardless of the API version, since changing the API version does not recreate the object.
+func CheckUpgrade(previous, current *Manifests, options ...UpgradeCheckOption) (*UpgradeReport, error) {
+	opts := &upgradeCheckOption{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	report := &UpgradeReport{}
+
+	previousKeys := indexByVersionlessKey(previous)
+	currentKeys := indexByVersionlessKey(current)
+
+	for versionless, key := range currentKeys {
+		previousKey, ok := previousKeys[versionless]
+		if !ok {
+			continue
+		}
+
+		before, _ := previous.Load(previousKey)
+		after, _ := current.Load(key)
+
+		paths, err := diffImmutableFields(key, before, after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %w", key, err)
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		if report.ImmutableFields == nil {
+			report.ImmutableFields = make(map[ObjectKey][]string)
+		}
+
+		report.ImmutableFields[key] = paths
+	}
+
+	recreated, err := declaredRecreations(previousKeys, currentKeys, opts.renames)
+	if err != nil {
+		return nil, err
+	}
+
+	recreated = append(recreated, sameNameRecreations(previousKeys, currentKeys, opts.renames)...)
+
+	sort.Slice(recreated, func(i, j int) bool {
+		return recreated[i].Previous.String() < recreated[j].Previous.String()
+	})
+
+	report.Recreated = recreated
+
+	return report, nil
+}
+
+// indexByVersionlessKey returns the keys of the manifests indexed by the keys without the version.
+func indexByVersionlessKey(manifests *Manifests) map[ObjectKey]ObjectKey {
+	keys := make(map[ObjectKey]ObjectKey, manifests.Length())
+
+	for _, key := range manifests.GetKeys() {
+		keys[versionlessKey(key)] = key
+	}
+
+	return keys
+}
+
+// versionlessKey returns the key without the version.
+func versionlessKey(key ObjectKey) ObjectKey {
+	key.Version = ""
+
+	return key
+}
+
+// diffImmutableFields returns the changed field paths that are immutable for the kind of the object.
+func diffImmutableFields(key ObjectKey, before, after runtime.Object) ([]string, error) {
+	kind := key.Group + "/" + key.Kind
+
+	immutable := immutableFields[kind]
+
+	if fields, ok := immutableDataFields[kind]; ok && isMarkedImmutable(before) {
+		immutable = append(append([]string(nil), immutable...), fields...)
+	}
+
+	if len(immutable) == 0 {
+		return nil, nil
+	}
+
+	paths, err := diffObjects(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+
+	for _, path := range paths {
+		for _, field := range immutable {
+			if path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(path, field+"[") {
+				changed = append(changed, path)
+
+				break
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// isMarkedImmutable returns true if the object has "immutable: true".
+func isMarkedImmutable(object runtime.Object) bool {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return false
+	}
+
+	immutable, _ := u["immutable"].(bool)
+
+	return immutable
+}
+
+// declaredRecreations returns the recreations declared by the renames.
+// The keys of the manifests and the renames are versionless.
+func declaredRecreations(previousKeys, currentKeys, renames map[ObjectKey]ObjectKey) ([]Recreation, error) {
+	recreations := make([]Recreation, 0, len(renames))
+
+	for previous, current := range renames {
+		previousKey, ok := previousKeys[previous]
+		if !ok {
+			return nil, fmt.Errorf("renamed object %s is not found in the previous manifests", previous)
+		}
+
+		if _, ok := currentKeys[previous]; ok {
+			return nil, fmt.Errorf("renamed object %s is not removed from the current manifests", previous)
+		}
+
+		currentKey, ok := currentKeys[current]
+		if !ok {
+			return nil, fmt.Errorf("renamed object %s is not found in the current manifests", current)
+		}
+
+		if _, ok := previousKeys[current]; ok {
+			return nil, fmt.Errorf("renamed object %s already exists in the previous manifests", current)
+		}
+
+		recreations = append(recreations, Recreation{Previous: previousKey, Current: currentKey})
+	}
+
+	return recreations, nil
+}
+
+// objectName is the namespace and the name of an object.
+type objectName struct {
+	namespace string
+	name      string
+}
+
+// sameNameRecreations returns the recreations of the removed objects and the added objects
+// that have the same namespace and name, except the objects declared by the renames.
+// The names of which more than one object is removed or added are skipped.
+// The keys of the manifests and the renames are versionless.
+func sameNameRecreations(previousKeys, currentKeys, renames map[ObjectKey]ObjectKey) []Recreation {
+	declared := make(map[ObjectKey]bool, len(renames)*2)
+
+	for previous, current := range renames {
+		declared[previous] = true
+		declared[current] = true
+	}
+
+	changed := func(from, to map[ObjectKey]ObjectKey) map[objectName][]ObjectKey {
+		keys := make(map[objectName][]ObjectKey)
+
+		for versionless, key := range from {
+			if _, ok := to[versionless]; ok || declared[versionless] {
+				continue
+			}
+
+			name := objectName{namespace: key.Namespace, name: key.Name}
+			keys[name] = append(keys[name], key)
+		}
+
+		return keys
+	}
+
+	removed := changed(previousKeys, currentKeys)
+	added := changed(currentKeys, previousKeys)
+
+	var recreations []Recreation
+
+	for name, previous := range removed {
+		current := added[name]
+		if len(previous) != 1 || len(current) != 1 {
+			continue
+		}
+
+		recreations = append(recreations, Recreation{Previous: previous[0], Current: current[0]})
+	}
+
+	return recreations
+}
@@ -0,0 +1,291 @@
+package helmut_test
+
+import (
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckUpgrade(t *testing.T) {
+	t.Parallel()
+
+	var (
+		deploymentGVK  = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+		statefulSetGVK = schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}
+		serviceGVK     = schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+		configMapGVK   = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+		extensionsGVK  = schema.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}
+		networkingGVK  = schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
+	)
+
+	deployment := func(selector, image string, replicas int32) runtime.Object {
+		return &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": selector}},
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+				},
+			},
+		}
+	}
+
+	statefulSet := func(storage string) runtime.Object {
+		return &appsv1.StatefulSet{
+			Spec: appsv1.StatefulSetSpec{
+				VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+					{Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: &storage}},
+				},
+			},
+		}
+	}
+
+	configMap := func(immutable bool, value string) runtime.Object {
+		return &corev1.ConfigMap{Immutable: &immutable, Data: map[string]string{"key": value}}
+	}
+
+	type object struct {
+		gvk    schema.GroupVersionKind
+		name   string
+		object runtime.Object
+	}
+
+	tests := []struct {
+		name     string
+		previous []object
+		current  []object
+		options  []helmut.UpgradeCheckOption
+		want     *helmut.UpgradeReport
+		wantErr  bool
+	}{
+		{
+			name:     "mutable fields changed",
+			previous: []object{{deploymentGVK, "app", deployment("app", "nginx:1.0", 1)}},
+			current:  []object{{deploymentGVK, "app", deployment("app", "nginx:1.1", 3)}},
+			want:     &helmut.UpgradeReport{},
+		},
+		{
+			name:     "deployment selector changed",
+			previous: []object{{deploymentGVK, "app", deployment("app", "nginx:1.0", 1)}},
+			current:  []object{{deploymentGVK, "app", deployment("web", "nginx:1.1", 1)}},
+			want: &helmut.UpgradeReport{ImmutableFields: map[helmut.ObjectKey][]string{
+				helmut.NewObjectKey("", "app", deploymentGVK): {"spec.selector.matchLabels.app"},
+			}},
+		},
+		{
+			name:     "statefulset volume claim templates changed",
+			previous: []object{{statefulSetGVK, "db", statefulSet("standard")}},
+			current:  []object{{statefulSetGVK, "db", statefulSet("fast")}},
+			want: &helmut.UpgradeReport{ImmutableFields: map[helmut.ObjectKey][]string{
+				helmut.NewObjectKey("", "db", statefulSetGVK): {"spec.volumeClaimTemplates[0].spec.storageClassName"},
+			}},
+		},
+		{
+			name: "service cluster IP changed",
+			previous: []object{{serviceGVK, "app", &corev1.Service{
+				Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.1"},
+			}}},
+			current: []object{{serviceGVK, "app", &corev1.Service{
+				Spec: corev1.ServiceSpec{ClusterIP: "None"},
+			}}},
+			want: &helmut.UpgradeReport{ImmutableFields: map[helmut.ObjectKey][]string{
+				helmut.NewObjectKey("", "app", serviceGVK): {"spec.clusterIP"},
+			}},
+		},
+		{
+			name:     "immutable configmap data changed",
+			previous: []object{{configMapGVK, "config", configMap(true, "a")}},
+			current:  []object{{configMapGVK, "config", configMap(true, "b")}},
+			want: &helmut.UpgradeReport{ImmutableFields: map[helmut.ObjectKey][]string{
+				helmut.NewObjectKey("", "config", configMapGVK): {"data.key"},
+			}},
+		},
+		{
+			name:     "mutable configmap data changed",
+			previous: []object{{configMapGVK, "config", configMap(false, "a")}},
+			current:  []object{{configMapGVK, "config", configMap(false, "b")}},
+			want:     &helmut.UpgradeReport{},
+		},
+		{
+			name:     "name changed",
+			previous: []object{{deploymentGVK, "app", deployment("app", "nginx:1.0", 1)}},
+			current:  []object{{deploymentGVK, "web", deployment("app", "nginx:1.0", 1)}},
+			options: []helmut.UpgradeCheckOption{helmut.WithRenamed(
+				helmut.NewObjectKey("", "app", deploymentGVK),
+				helmut.NewObjectKey("", "web", deploymentGVK),
+			)},
+			want: &helmut.UpgradeReport{Recreated: []helmut.Recreation{{
+				Previous: helmut.NewObjectKey("", "app", deploymentGVK),
+				Current:  helmut.NewObjectKey("", "web", deploymentGVK),
+			}}},
+		},
+		{
+			name:     "kind changed",
+			previous: []object{{deploymentGVK, "db", deployment("db", "mysql:8", 1)}},
+			current:  []object{{statefulSetGVK, "db", statefulSet("standard")}},
+			options: []helmut.UpgradeCheckOption{helmut.WithRenamed(
+				helmut.NewObjectKey("", "db", deploymentGVK),
+				helmut.NewObjectKey("", "db", statefulSetGVK),
+			)},
+			want: &helmut.UpgradeReport{Recreated: []helmut.Recreation{{
+				Previous: helmut.NewObjectKey("", "db", deploymentGVK),
+				Current:  helmut.NewObjectKey("", "db", statefulSetGVK),
+			}}},
+		},
+		{
+			name:     "api version changed",
+			previous: []object{{schema.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "Deployment"}, "app", deployment("app", "nginx:1.0", 1)}},
+			current:  []object{{deploymentGVK, "app", deployment("app", "nginx:1.0", 1)}},
+			want:     &helmut.UpgradeReport{},
+		},
+		{
+			name:     "object added and removed",
+			previous: []object{{serviceGVK, "app", &corev1.Service{}}},
+			current:  []object{{deploymentGVK, "web", deployment("web", "nginx:1.0", 1)}},
+			want:     &helmut.UpgradeReport{},
+		},
+		{
+			name:     "object of the same kind added and removed",
+			previous: []object{{configMapGVK, "a", configMap(false, "a")}},
+			current:  []object{{configMapGVK, "b", configMap(false, "b")}},
+			want:     &helmut.UpgradeReport{},
+		},
+		{
+			name:     "object of the same name added and removed",
+			previous: []object{{serviceGVK, "app", &corev1.Service{}}},
+			current:  []object{{deploymentGVK, "app", deployment("app", "nginx:1.0", 1)}},
+			want: &helmut.UpgradeReport{Recreated: []helmut.Recreation{{
+				Previous: helmut.NewObjectKey("", "app", serviceGVK),
+				Current:  helmut.NewObjectKey("", "app", deploymentGVK),
+			}}},
+		},
+		{
+			name:     "kind changed without renamed",
+			previous: []object{{deploymentGVK, "db", deployment("db", "mysql:8", 1)}},
+			current:  []object{{statefulSetGVK, "db", statefulSet("standard")}},
+			want: &helmut.UpgradeReport{Recreated: []helmut.Recreation{{
+				Previous: helmut.NewObjectKey("", "db", deploymentGVK),
+				Current:  helmut.NewObjectKey("", "db", statefulSetGVK),
+			}}},
+		},
+		{
+			name:     "group changed",
+			previous: []object{{extensionsGVK, "web", &networkingv1.Ingress{}}},
+			current:  []object{{networkingGVK, "web", &networkingv1.Ingress{}}},
+			want: &helmut.UpgradeReport{Recreated: []helmut.Recreation{{
+				Previous: helmut.NewObjectKey("", "web", extensionsGVK),
+				Current:  helmut.NewObjectKey("", "web", networkingGVK),
+			}}},
+		},
+		{
+			name:     "objects of the same name added and removed ambiguously",
+			previous: []object{{serviceGVK, "app", &corev1.Service{}}, {configMapGVK, "app", configMap(false, "a")}},
+			current:  []object{{deploymentGVK, "app", deployment("app", "nginx:1.0", 1)}},
+			want:     &helmut.UpgradeReport{},
+		},
+		{
+			name:     "renamed object not removed",
+			previous: []object{{configMapGVK, "a", configMap(false, "a")}},
+			current:  []object{{configMapGVK, "a", configMap(false, "a")}, {configMapGVK, "b", configMap(false, "b")}},
+			options: []helmut.UpgradeCheckOption{helmut.WithRenamed(
+				helmut.NewObjectKey("", "a", configMapGVK),
+				helmut.NewObjectKey("", "b", configMapGVK),
+			)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			previous := helmut.NewManifests()
+			for _, o := range tt.previous {
+				previous.Store(helmut.NewObjectKey("", o.name, o.gvk), o.object)
+			}
+
+			current := helmut.NewManifests()
+			for _, o := range tt.current {
+				current.Store(helmut.NewObjectKey("", o.name, o.gvk), o.object)
+			}
+
+			got, err := helmut.CheckUpgrade(previous, current, tt.options...)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, but got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to check upgrade: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("report mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckUpgradeWithChart(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartPath   = "testdata/test-chart"
+	)
+
+	r := newRenderer(t)
+
+	previous, err := r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	// Overriding the name changes the names of all objects and the selector labels.
+	current, err := r.RenderTemplates(releaseName, chartPath, helmut.WithSet("fullnameOverride=bar", "nameOverride=bar"))
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	report, err := helmut.CheckUpgrade(previous, current)
+	if err != nil {
+		t.Fatalf("failed to check upgrade: %s", err)
+	}
+
+	if !report.Empty() {
+		t.Errorf("got upgrade breaking changes %+v for objects that are added and removed", report)
+	}
+
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	renamed := helmut.WithRenamed(
+		helmut.NewObjectKey("", "foo-test-chart", deploymentGVK),
+		helmut.NewObjectKey("", "bar", deploymentGVK),
+	)
+
+	report, err = helmut.CheckUpgrade(previous, current, renamed)
+	if err != nil {
+		t.Fatalf("failed to check upgrade: %s", err)
+	}
+
+	want := []helmut.Recreation{{
+		Previous: helmut.NewObjectKey("", "foo-test-chart", deploymentGVK),
+		Current:  helmut.NewObjectKey("", "bar", deploymentGVK),
+	}}
+
+	if diff := cmp.Diff(want, report.Recreated); diff != "" {
+		t.Errorf("recreations mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,137 @@
+package helmut
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// kustomizeOverlayDir is the directory where the overlay is copied in the in-memory filesystem.
+	kustomizeOverlayDir = "/overlay"
+	// kustomizeResourceFile is the file name of the manifests added to the resources of the overlay.
+	kustomizeResourceFile = "helmut-manifests.yaml"
+)
+
+// Kustomize applies the kustomization in the overlay directory to the manifests,
+// like the "kustomize build" command, and returns the result as new Manifests.
+// The given manifests are not modified.
+//
+// The overlay directory is copied to an in-memory filesystem, and the manifests are added to the resources
+// of the kustomization, so the kustomization.yaml does not need to refer to the rendered manifests.
+// Since only the overlay directory is copied, it must not refer to files outside the directory.
+//
+// e.g.
+//
+//  manifests, err := r.RenderTemplates(releaseName, chartPath)
+//
+//  production, err := helmut.Kustomize(manifests, "testdata/overlays/production")
+//
+func Kustomize(manifests *Manifests, overlay string) (*Manifests, error) {
+	fSys := filesys.MakeFsInMemory()
+
+	if err := copyToFileSystem(overlay, kustomizeOverlayDir, fSys); err != nil {
+		return nil, fmt.Errorf("failed to copy the overlay: %w", err)
+	}
+
+	data, err := marshalManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fSys.WriteFile(path.Join(kustomizeOverlayDir, kustomizeResourceFile), data); err != nil {
+		return nil, fmt.Errorf("failed to write the manifests: %w", err)
+	}
+
+	if err := addKustomizationResource(fSys, kustomizeOverlayDir, kustomizeResourceFile); err != nil {
+		return nil, err
+	}
+
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+
+	resMap, err := kustomizer.Run(fSys, kustomizeOverlayDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run kustomize: %w", err)
+	}
+
+	result, err := resMap.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the result of kustomize: %w", err)
+	}
+
+	return New(WithScheme(manifests.GetScheme())).SplitManifests(result)
+}
+
+// addKustomizationResource adds the resource to the resources of the kustomization in dir.
+func addKustomizationResource(fSys filesys.FileSystem, dir, resource string) error {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		file := path.Join(dir, name)
+
+		if !fSys.Exists(file) {
+			continue
+		}
+
+		data, err := fSys.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		// Use a map so that the fields unknown to this version of kustomize are kept.
+		kustomization := make(map[string]interface{})
+
+		if err := yaml.Unmarshal(data, &kustomization); err != nil {
+			return fmt.Errorf("failed to unmarshal %s: %w", name, err)
+		}
+
+		resources, _ := kustomization["resources"].([]interface{})
+		kustomization["resources"] = append(resources, resource)
+
+		data, err = yaml.Marshal(kustomization)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", name, err)
+		}
+
+		if err := fSys.WriteFile(file, data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+
+		return nil
+	}
+
+	return errors.New("kustomization file not found in the overlay")
+}
+
+// copyToFileSystem copies the directory on disk to the directory of the filesystem recursively.
+func copyToFileSystem(src, dst string, fSys filesys.FileSystem) error {
+	return filepath.WalkDir(src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(dst, filepath.ToSlash(rel))
+
+		if d.IsDir() {
+			return fSys.MkdirAll(target)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		return fSys.WriteFile(target, data)
+	})
+}
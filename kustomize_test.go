@@ -0,0 +1,157 @@
+package helmut_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestKustomize(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	r := newRenderer(t)
+
+	manifests, err := r.RenderTemplates(releaseName, "testdata/test-chart")
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	kustomized, err := helmut.Kustomize(manifests, filepath.Join("testdata", "overlays", "production"))
+	if err != nil {
+		t.Fatalf("failed to kustomize: %s", err)
+	}
+
+	if got, want := kustomized.Length(), manifests.Length(); got != want {
+		t.Errorf("got %d objects, want %d", got, want)
+	}
+
+	deploy := loadDeployment(t, kustomized, helmut.NewObjectKey("", "prod-foo-test-chart",
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}))
+
+	if got := *deploy.Spec.Replicas; got != 3 {
+		t.Errorf("got replicas %d, want 3", got)
+	}
+
+	if got, want := deploy.Spec.Template.Spec.Containers[0].Image, "nginx:1.21.0"; got != want {
+		t.Errorf("got image %s, want %s", got, want)
+	}
+
+	if got, want := deploy.Spec.Template.Spec.ServiceAccountName, "prod-foo-test-chart"; got != want {
+		t.Errorf("got service account %s, want %s", got, want)
+	}
+
+	if got, want := deploy.Spec.Template.Labels["env"], "production"; got != want {
+		t.Errorf("got env label %q, want %q", got, want)
+	}
+
+	object, ok := kustomized.Load(helmut.NewObjectKey("", "prod-foo-test-chart",
+		schema.GroupVersionKind{Version: "v1", Kind: "Service"}))
+	if !ok {
+		t.Fatal("service not found")
+	}
+
+	if got, want := object.(*corev1.Service).Spec.Selector["env"], "production"; got != want {
+		t.Errorf("got env selector %q, want %q", got, want)
+	}
+
+	// The original manifests are not modified.
+	original := loadDeployment(t, manifests, helmut.NewObjectKey("", "foo-test-chart",
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}))
+
+	if got := *original.Spec.Replicas; got != 1 {
+		t.Errorf("got original replicas %d, want 1", got)
+	}
+}
+
+func TestKustomizeDoesNotModifyObjects(t *testing.T) {
+	t.Parallel()
+
+	// Objects created by the user may not have the apiVersion and kind.
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Data:       map[string]string{"key": "value"},
+	}
+
+	manifests := helmut.NewManifests()
+	manifests.Store(helmut.NewObjectKey("", "foo", schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}), configMap)
+
+	overlay := t.TempDir()
+	writeFile(t, filepath.Join(overlay, "kustomization.yaml"), "namePrefix: prod-\n")
+
+	kustomized, err := helmut.Kustomize(manifests, overlay)
+	if err != nil {
+		t.Fatalf("failed to kustomize: %s", err)
+	}
+
+	if _, ok := kustomized.Load(helmut.NewObjectKey("", "prod-foo",
+		schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})); !ok {
+		t.Error("kustomized config map not found")
+	}
+
+	if diff := cmp.Diff(metav1.TypeMeta{}, configMap.TypeMeta); diff != "" {
+		t.Errorf("the object of the manifests is modified (-want +got):\n%s", diff)
+	}
+}
+
+func TestKustomizeError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		kustomization string
+		wantErr       string
+	}{
+		{
+			name:    "kustomization not found",
+			wantErr: "kustomization file not found",
+		},
+		{
+			name:          "patch target not found",
+			kustomization: "patches:\n  - path: patch.yaml\n",
+			wantErr:       "failed to run kustomize",
+		},
+	}
+
+	manifests := helmut.NewManifests()
+	manifests.Store(helmut.NewObjectKey("", "foo", schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}),
+		&appsv1.Deployment{})
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			if len(tt.kustomization) != 0 {
+				writeFile(t, filepath.Join(dir, "kustomization.yaml"), tt.kustomization)
+				writeFile(t, filepath.Join(dir, "patch.yaml"),
+					"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: not-found\n")
+			}
+
+			_, err := helmut.Kustomize(manifests, dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
@@ -0,0 +1,248 @@
+package helmut
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/lint/support"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// LintSeverity is the severity of a LintFinding.
+type LintSeverity int
+
+// The severities of the lint findings, which are the same as "helm lint".
+const (
+	LintInfo    LintSeverity = support.InfoSev
+	LintWarning LintSeverity = support.WarningSev
+	LintError   LintSeverity = support.ErrorSev
+)
+
+// String returns the name of the severity printed by "helm lint", such as "WARNING".
+func (s LintSeverity) String() string {
+	switch s {
+	case LintInfo:
+		return "INFO"
+	case LintWarning:
+		return "WARNING"
+	case LintError:
+		return "ERROR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// The rules of the lint findings.
+// The rules are grouped by the files they check, such as "templates/deprecated-api" of "templates",
+// and a group matches all the rules in it when suppressing the rules.
+const (
+	LintRuleChartfile         = "chartfile"
+	LintRuleChartIcon         = "chartfile/icon"
+	LintRuleValues            = "values"
+	LintRuleTemplates         = "templates"
+	LintRuleTemplateExtension = "templates/extension"
+	LintRuleTemplateIndent    = "templates/indent"
+	LintRuleYAML              = "templates/yaml"
+	LintRuleMetadataName      = "templates/metadata-name"
+	LintRuleDeprecatedAPI     = "templates/deprecated-api"
+	LintRuleMatchSelector     = "templates/match-selector"
+	LintRuleListAnnotations   = "templates/list-annotations"
+	LintRuleCRDHook           = "templates/crd-hook"
+	LintRuleReleaseTime       = "templates/release-time"
+	LintRuleDependencies      = "dependencies"
+)
+
+// lintRules identifies the rules of the findings in the groups by the messages,
+// for the rules whose errors Helm reports only as text without a type or a field path.
+// The messages are those of the Helm version in go.mod, and TestLintRules pins each rule.
+var lintRules = map[string][]struct {
+	rule    string
+	message string
+}{
+	LintRuleTemplates: {
+		{rule: LintRuleTemplateExtension, message: "file extension "},
+		{rule: LintRuleTemplateIndent, message: "document starts with an illegal indent"},
+		{rule: LintRuleMatchSelector, message: "must contain matchLabels or matchExpressions"},
+		{rule: LintRuleListAnnotations, message: "Annotation 'helm.sh/resource-policy' within List objects are ignored"},
+		{rule: LintRuleCRDHook, message: "manifest is a crd-install hook"},
+		{rule: LintRuleReleaseTime, message: ".Release.Time has been removed"},
+	},
+}
+
+// deprecatedAPIErrorType is the error type of the rule of the deprecated APIs, which Helm does not export.
+const deprecatedAPIErrorType = "helm.sh/helm/v3/pkg/lint/rules.deprecatedAPIError"
+
+// LintFinding is a finding of the lint rules of Helm.
+type LintFinding struct {
+	// Rule is the rule of the finding, such as LintRuleChartIcon.
+	Rule     string
+	Severity LintSeverity
+	// Path is the path of the file relative to the chart directory, such as "templates/deployment.yaml".
+	// Path is empty for the findings of the chart itself, such as the dependencies.
+	Path    string
+	Message string
+}
+
+// String returns the finding in the format of "helm lint" with the rule.
+func (f LintFinding) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", f.Severity, f.Path, f.Message, f.Rule)
+}
+
+// Lint runs the lint rules of Helm on the chart like the "helm lint" command, and returns the findings.
+// The findings of all the severities are returned, so filter them by the Severity if necessary.
+//
+// The value options, the namespace, the chart path options and the dependency options
+// are applied in the same way as RenderTemplates.
+// An error is returned only if the chart cannot be linted, such as when it is not found.
+//
+// e.g.
+//
+//  findings, err := r.Lint("testdata/test-chart", helmut.WithValues("testdata/values.yaml"))
+//
+func (r *Renderer) Lint(chart string, options ...Option) ([]LintFinding, error) {
+	if err := r.prepare(); err != nil {
+		return nil, err
+	}
+
+	opts := &option{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	chartPath, values, err := r.locateRequest(newClient("", opts), chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.dependencyBuild {
+		built, cleanup, err := buildDependencies(chartPath, opts.localRepositories)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build dependencies: %w", err)
+		}
+		defer cleanup()
+
+		chartPath = built
+	}
+
+	client := action.NewLint()
+	client.Namespace = opts.namespace
+	client.SkipSchemaValidation = opts.skipSchemaValidation
+
+	result := client.Run([]string{chartPath}, values)
+
+	if result.TotalChartsLinted == 0 {
+		return nil, fmt.Errorf("failed to lint the chart: %w", errors.Join(result.Errors...))
+	}
+
+	findings := make([]LintFinding, 0, len(result.Messages))
+
+	for _, m := range result.Messages {
+		findings = append(findings, newLintFinding(m))
+	}
+
+	return findings, nil
+}
+
+// newLintFinding converts the message of "helm lint" to the finding and identifies its rule.
+// The group of the rule is identified by the path of the message.
+// The rule in the group is identified by the severity, the error type or the field path of the error
+// where Helm exposes them, and otherwise by the message.
+func newLintFinding(m support.Message) LintFinding {
+	f := LintFinding{
+		Severity: LintSeverity(m.Severity),
+		Path:     filepath.ToSlash(m.Path),
+		Message:  m.Err.Error(),
+	}
+
+	// The findings of the chart itself have the absolute path of the chart directory.
+	if filepath.IsAbs(m.Path) {
+		f.Path = ""
+	}
+
+	switch {
+	case f.Path == "Chart.yaml":
+		f.Rule = LintRuleChartfile
+	case f.Path == "values.yaml":
+		f.Rule = LintRuleValues
+	case strings.HasPrefix(f.Path, "templates/"):
+		f.Rule = LintRuleTemplates
+	default:
+		f.Rule = LintRuleDependencies
+	}
+
+	switch {
+	case f.Rule == LintRuleChartfile && f.Severity == LintInfo:
+		// The icon is the only rule of Chart.yaml reported as information.
+		f.Rule = LintRuleChartIcon
+
+		return f
+	case f.Rule != LintRuleTemplates:
+		return f
+	case isYAMLSyntaxError(m.Err):
+		f.Rule = LintRuleYAML
+
+		return f
+	case isMetadataNameError(m.Err):
+		f.Rule = LintRuleMetadataName
+
+		return f
+	case isDeprecatedAPIError(m.Err):
+		f.Rule = LintRuleDeprecatedAPI
+
+		return f
+	}
+
+	for _, r := range lintRules[f.Rule] {
+		if strings.Contains(f.Message, r.message) {
+			f.Rule = r.rule
+
+			break
+		}
+	}
+
+	return f
+}
+
+// isYAMLSyntaxError returns true if the error wraps the error of parsing a rendered manifest.
+func isYAMLSyntaxError(err error) bool {
+	var syntaxErr yaml.YAMLSyntaxError
+
+	return errors.As(err, &syntaxErr)
+}
+
+// isMetadataNameError returns true if the error wraps the field errors of "metadata.name".
+func isMetadataNameError(err error) bool {
+	var aggregate utilerrors.Aggregate
+	if !errors.As(err, &aggregate) {
+		return false
+	}
+
+	for _, e := range aggregate.Errors() {
+		var fieldErr *field.Error
+		if errors.As(e, &fieldErr) && fieldErr.Field == "metadata.name" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isDeprecatedAPIError returns true if the error is reported by the rule of the deprecated APIs.
+func isDeprecatedAPIError(err error) bool {
+	t := reflect.TypeOf(err)
+
+	return t.PkgPath()+"."+t.Name() == deprecatedAPIErrorType
+}
+
+// MatchLintRule returns true if the rule is the same as the pattern or in the group of the pattern,
+// e.g. "templates/deprecated-api" matches "templates".
+func MatchLintRule(rule, pattern string) bool {
+	return rule == pattern || strings.HasPrefix(rule, pattern+"/")
+}
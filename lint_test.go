@@ -0,0 +1,301 @@
+package helmut_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	icon := "INFO chartfile/icon Chart.yaml"
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+		// want is the severity, rule and path of the findings.
+		want []string
+	}{
+		{
+			name:  "default values",
+			chart: "testdata/lint-chart",
+			want:  []string{icon},
+		},
+		{
+			name:  "value options",
+			chart: "testdata/lint-chart",
+			options: []helmut.Option{
+				helmut.WithSet("name=Invalid_Name", "ingress.apiVersion=extensions/v1beta1"),
+			},
+			want: []string{
+				icon,
+				"WARNING templates/metadata-name templates/configmap.yaml",
+				"WARNING templates/metadata-name templates/ingress.yaml",
+				"WARNING templates/deprecated-api templates/ingress.yaml",
+			},
+		},
+		{
+			name:  "values map",
+			chart: "testdata/lint-chart",
+			options: []helmut.Option{
+				helmut.WithValuesMap(map[string]interface{}{"ingress": map[string]interface{}{"apiVersion": "extensions/v1beta1"}}),
+			},
+			want: []string{
+				icon,
+				"WARNING templates/deprecated-api templates/ingress.yaml",
+			},
+		},
+		{
+			name:  "missing dependencies",
+			chart: "testdata/umbrella-chart",
+			want:  []string{icon, "WARNING dependencies "},
+		},
+		{
+			name:  "dependency build",
+			chart: "testdata/umbrella-chart",
+			options: []helmut.Option{
+				helmut.WithDependencyBuild(),
+				helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart"),
+			},
+			want: []string{icon},
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			findings, err := renderer.Lint(tt.chart, tt.options...)
+			if err != nil {
+				t.Fatalf("lint error: %s", err)
+			}
+
+			got := make([]string, 0, len(findings))
+
+			for _, f := range findings {
+				got = append(got, strings.Join([]string{f.Severity.String(), f.Rule, f.Path}, " "))
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("findings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLintFinding(t *testing.T) {
+	t.Parallel()
+
+	renderer := helmut.New()
+
+	findings, err := renderer.Lint("testdata/lint-chart", helmut.WithSet("ingress.apiVersion=extensions/v1beta1"))
+	if err != nil {
+		t.Fatalf("lint error: %s", err)
+	}
+
+	var got []string
+
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+
+	want := []string{
+		"[INFO] Chart.yaml: icon is recommended (chartfile/icon)",
+		"[WARNING] templates/ingress.yaml: extensions/v1beta1 Ingress is deprecated in v1.14+, unavailable in v1.22+; " +
+			"use networking.k8s.io/v1 Ingress (templates/deprecated-api)",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLintError(t *testing.T) {
+	t.Parallel()
+
+	renderer := helmut.New()
+
+	if _, err := renderer.Lint("testdata/not-found-chart"); err == nil {
+		t.Error("expected an error, got nil")
+	}
+}
+
+func TestMatchLintRule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rule    string
+		pattern string
+		want    bool
+	}{
+		{rule: helmut.LintRuleDeprecatedAPI, pattern: helmut.LintRuleDeprecatedAPI, want: true},
+		{rule: helmut.LintRuleDeprecatedAPI, pattern: helmut.LintRuleTemplates, want: true},
+		{rule: helmut.LintRuleTemplates, pattern: helmut.LintRuleDeprecatedAPI, want: false},
+		{rule: helmut.LintRuleChartIcon, pattern: helmut.LintRuleTemplates, want: false},
+		{rule: "templatesfoo", pattern: helmut.LintRuleTemplates, want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.rule+" "+tt.pattern, func(t *testing.T) {
+			t.Parallel()
+
+			if got := helmut.MatchLintRule(tt.rule, tt.pattern); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLintRules pins each rule to the real finding of the Helm version in go.mod,
+// because the rules are identified by the severities, the error types and the messages of the findings.
+func TestLintRules(t *testing.T) {
+	t.Parallel()
+
+	chartfile := "apiVersion: v2\nname: rules\nversion: 0.1.0\nicon: https://example.com/icon.png\n"
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: rules\n"
+
+	tests := []struct {
+		rule  string
+		files map[string]string
+		// want is the severity, the path and the message of the finding reported by Helm.
+		want string
+	}{
+		{
+			rule:  helmut.LintRuleChartfile,
+			files: map[string]string{"Chart.yaml": "apiVersion: v2\nname: rules\nicon: https://example.com/icon.png\n"},
+			want:  "ERROR Chart.yaml: version is required",
+		},
+		{
+			rule:  helmut.LintRuleChartIcon,
+			files: map[string]string{"Chart.yaml": "apiVersion: v2\nname: rules\nversion: 0.1.0\n"},
+			want:  "INFO Chart.yaml: icon is recommended",
+		},
+		{
+			rule:  helmut.LintRuleValues,
+			files: map[string]string{"values.yaml": "foo: [\n"},
+			want:  "ERROR values.yaml: unable to parse YAML: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+		},
+		{
+			rule:  helmut.LintRuleTemplates,
+			files: map[string]string{"templates/configmap.yaml": "{{ .Values.foo"},
+			want:  "ERROR templates/: parse error at (rules/templates/configmap.yaml:1): unclosed action",
+		},
+		{
+			rule:  helmut.LintRuleTemplateExtension,
+			files: map[string]string{"templates/configmap.json": "{}"},
+			want:  "ERROR templates/configmap.json: file extension '.json' not valid. Valid extensions are .yaml, .yml, .tpl, or .txt",
+		},
+		{
+			rule:  helmut.LintRuleTemplateIndent,
+			files: map[string]string{"templates/configmap.yaml": "  " + configMap},
+			want: "WARNING templates/configmap.yaml: document starts with an illegal indent: \"  apiVersion: v1\", " +
+				"which may cause parsing problems",
+		},
+		{
+			rule:  helmut.LintRuleYAML,
+			files: map[string]string{"templates/configmap.yaml": "kind: [\n"},
+			want: "ERROR templates/configmap.yaml: unable to parse YAML: error converting YAML to JSON: " +
+				"yaml: line 1: did not find expected node content",
+		},
+		{
+			rule:  helmut.LintRuleMetadataName,
+			files: map[string]string{"templates/configmap.yaml": strings.Replace(configMap, "rules", "Invalid_Name", 1)},
+			want: "WARNING templates/configmap.yaml: object name does not conform to Kubernetes naming requirements: " +
+				`"Invalid_Name": metadata.name: Invalid value: "Invalid_Name": a lowercase RFC 1123 subdomain must consist of ` +
+				"lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character " +
+				`(e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			rule: helmut.LintRuleDeprecatedAPI,
+			files: map[string]string{
+				"templates/ingress.yaml": "apiVersion: extensions/v1beta1\nkind: Ingress\nmetadata:\n  name: rules\n",
+			},
+			want: "WARNING templates/ingress.yaml: extensions/v1beta1 Ingress is deprecated in v1.14+, unavailable in v1.22+; " +
+				"use networking.k8s.io/v1 Ingress",
+		},
+		{
+			rule: helmut.LintRuleMatchSelector,
+			files: map[string]string{
+				"templates/deployment.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: rules\n",
+			},
+			want: `ERROR templates/deployment.yaml: a Deployment must contain matchLabels or matchExpressions, and "rules" does not`,
+		},
+		{
+			rule: helmut.LintRuleListAnnotations,
+			files: map[string]string{
+				"templates/list.yaml": "apiVersion: v1\nkind: List\nitems:\n" +
+					"- metadata:\n    annotations:\n      helm.sh/resource-policy: keep\n",
+			},
+			want: "ERROR templates/list.yaml: Annotation 'helm.sh/resource-policy' within List objects are ignored",
+		},
+		{
+			rule: helmut.LintRuleCRDHook,
+			files: map[string]string{
+				"templates/configmap.yaml": strings.Replace(configMap, "  name: rules\n",
+					"  name: rules\n  annotations:\n    helm.sh/hook: crd-install\n", 1),
+			},
+			want: "WARNING templates/configmap.yaml: manifest is a crd-install hook. " +
+				"This hook is no longer supported in v3 and all CRDs should also exist the crds/ directory at the top level of the chart",
+		},
+		{
+			rule:  helmut.LintRuleReleaseTime,
+			files: map[string]string{"templates/configmap.yaml": configMap + "# {{ .Release.Time }}\n"},
+			want: "ERROR templates/configmap.yaml: .Release.Time has been removed in v3, " +
+				"please replace with the `now` function in your templates",
+		},
+		{
+			rule: helmut.LintRuleDependencies,
+			files: map[string]string{
+				"Chart.yaml": chartfile + "dependencies:\n- name: sub\n  version: 0.1.0\n  repository: https://charts.example.com\n",
+			},
+			want: "WARNING : chart directory is missing these dependencies: sub",
+		},
+	}
+
+	renderer := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.rule, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "rules")
+
+			files := map[string]string{"Chart.yaml": chartfile, "templates/configmap.yaml": configMap}
+			for name, data := range tt.files {
+				files[name] = data
+			}
+
+			writeFiles(t, dir, files)
+
+			findings, err := renderer.Lint(dir)
+			if err != nil {
+				t.Fatalf("lint error: %s", err)
+			}
+
+			var got []string
+
+			for _, f := range findings {
+				if f.Rule == tt.rule {
+					got = append(got, f.Severity.String()+" "+f.Path+": "+f.Message)
+				}
+			}
+
+			if diff := cmp.Diff([]string{tt.want}, got); diff != "" {
+				t.Errorf("findings of the rule mismatch (-want +got):\n%s\nall findings: %v", diff, findings)
+			}
+		})
+	}
+}
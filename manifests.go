@@ -1,15 +1,24 @@
 package helmut
 
 import (
+	"bytes"
+	"fmt"
 	"sync"
 
+	"github.com/d-kuro/helmut/util"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
 )
 
 // Manifests stores the rendered manifests.
 type Manifests struct {
 	objects map[ObjectKey]runtime.Object
+	// documents is the rendered YAML documents of the objects split by SplitManifests.
+	// The document of an object is removed when the object is stored or deleted.
+	documents map[ObjectKey][]byte
 	scheme  *runtime.Scheme
+	values  Values
+	trace   *ValuesTrace
 
 	mu   sync.RWMutex
 	once sync.Once
@@ -42,6 +51,10 @@ func (m *Manifests) init() {
 		m.objects = make(map[ObjectKey]runtime.Object)
 	}
 
+	if m.documents == nil {
+		m.documents = make(map[ObjectKey][]byte)
+	}
+
 	if m.scheme == nil {
 		m.scheme = defaultScheme
 	}
@@ -66,6 +79,7 @@ func (m *Manifests) Delete(key ObjectKey) {
 
 	m.mu.Lock()
 	delete(m.objects, key)
+	delete(m.documents, key)
 	m.mu.Unlock()
 }
 
@@ -75,9 +89,33 @@ func (m *Manifests) Store(key ObjectKey, value runtime.Object) {
 
 	m.mu.Lock()
 	m.objects[key] = value
+	delete(m.documents, key)
+	m.mu.Unlock()
+}
+
+// storeDocument sets the object for a key with the YAML document that the object is decoded from.
+func (m *Manifests) storeDocument(key ObjectKey, value runtime.Object, document []byte) {
+	m.once.Do(m.init)
+
+	m.mu.Lock()
+	m.objects[key] = value
+	m.documents[key] = document
 	m.mu.Unlock()
 }
 
+// loadDocument returns the YAML document that the object for a key is decoded from.
+// The ok result is false if the object is not split from the rendered manifests, or it has been stored again.
+func (m *Manifests) loadDocument(key ObjectKey) ([]byte, bool) {
+	m.once.Do(m.init)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[key]
+
+	return document, ok
+}
+
 // GetScheme returns the scheme.
 func (m *Manifests) GetScheme() *runtime.Scheme {
 	m.once.Do(m.init)
@@ -106,3 +144,63 @@ func (m *Manifests) GetKeys() []ObjectKey {
 
 	return keys
 }
+
+// Values returns the computed values used to render the manifests.
+// It returns empty values if the manifests are not rendered by RenderTemplates.
+func (m *Manifests) Values() Values {
+	m.once.Do(m.init)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.values == nil {
+		return Values{}
+	}
+
+	return m.values
+}
+
+// ValuesTrace returns the sources of the computed values recorded by WithValuesTrace.
+// It returns an empty trace if WithValuesTrace is not specified.
+func (m *Manifests) ValuesTrace() *ValuesTrace {
+	m.once.Do(m.init)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.trace == nil {
+		return &ValuesTrace{}
+	}
+
+	return m.trace
+}
+
+// marshalManifests marshals the objects of the manifests into a multi-document YAML in the order of their keys.
+func marshalManifests(manifests *Manifests) ([]byte, error) {
+	keys := manifests.GetKeys()
+	SortObjectKeys(keys)
+
+	buf := &bytes.Buffer{}
+
+	for _, key := range keys {
+		stored, _ := manifests.Load(key)
+
+		// Objects created by the user may not have the apiVersion and kind,
+		// which are set to a copy so that the objects of the manifests are not modified.
+		object := stored.DeepCopyObject()
+
+		if _, err := util.SetGVKIfDoesNotExist(manifests.GetScheme(), object); err != nil {
+			return nil, fmt.Errorf("failed to set GVK of %s: %w", key, err)
+		}
+
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", key, err)
+		}
+
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,221 @@
+package helmut
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ValuesSet is a named set of options used when rendering a matrix.
+// Typically, it contains value options such as WithValues, WithSet and WithValuesMap.
+type ValuesSet struct {
+	Name    string
+	Options []Option
+}
+
+// NewValuesSet creates and returns a new ValuesSet.
+func NewValuesSet(name string, options ...Option) ValuesSet {
+	return ValuesSet{
+		Name:    name,
+		Options: options,
+	}
+}
+
+// Combine returns the cartesian product of the values sets.
+// The name of a combined set is the names of the original sets joined with "/",
+// and the options are applied in the order of the dimensions.
+// The values of WithValues, WithSet, WithSetString and WithSetFile are added to those of the previous dimensions
+// instead of replacing them, so that a key set again by a later dimension is overridden.
+//
+// e.g. Combine({a, b}, {x, y}) returns {a/x, a/y, b/x, b/y}.
+func Combine(dimensions ...[]ValuesSet) []ValuesSet {
+	if len(dimensions) == 0 {
+		return nil
+	}
+
+	result := []ValuesSet{{}}
+
+	for _, dimension := range dimensions {
+		next := make([]ValuesSet, 0, len(result)*len(dimension))
+
+		for _, prefix := range result {
+			for _, set := range dimension {
+				name := set.Name
+				if len(prefix.Name) != 0 {
+					name = prefix.Name + "/" + set.Name
+				}
+
+				options := make([]Option, 0, len(prefix.Options)+1)
+				options = append(options, prefix.Options...)
+				options = append(options, composeValues(set.Options))
+
+				next = append(next, ValuesSet{Name: name, Options: options})
+			}
+		}
+
+		result = next
+	}
+
+	return result
+}
+
+// MatrixResult is the result of rendering a ValuesSet.
+type MatrixResult struct {
+	Manifests *Manifests
+	Err       error
+}
+
+// matrixOption stores the matrix options.
+type matrixOption struct {
+	concurrency int
+	options     []Option
+}
+
+// MatrixOption is an option to specify when calling RenderMatrix.
+type MatrixOption func(*matrixOption)
+
+// WithConcurrency specifies the maximum number of values sets to be rendered concurrently.
+// If not specified, runtime.GOMAXPROCS(0) is used.
+func WithConcurrency(n int) MatrixOption {
+	return func(o *matrixOption) {
+		o.concurrency = n
+	}
+}
+
+// WithCommonOptions specifies the options applied to all values sets.
+// These options are applied before the options of each values set,
+// and the values of WithValues, WithSet, WithSetString and WithSetFile of a values set
+// are added to those of the common options instead of replacing them.
+func WithCommonOptions(options ...Option) MatrixOption {
+	return func(o *matrixOption) {
+		o.options = append(o.options, options...)
+	}
+}
+
+// RenderMatrix renders the chart with each values set concurrently and returns the results keyed by the set name.
+// A rendering failure of a values set is stored in MatrixResult.Err, so that it can be reported by each subtest.
+// An error is returned only if the values sets are invalid, such as duplicate names.
+//
+// Example of running subtests for each combination:
+//
+//  sets := helmut.Combine(
+//  	[]helmut.ValuesSet{
+//  		helmut.NewValuesSet("default"),
+//  		helmut.NewValuesSet("autoscaling", helmut.WithSet("autoscaling.enabled=true")),
+//  	},
+//  	[]helmut.ValuesSet{
+//  		helmut.NewValuesSet("production", helmut.WithValues("testdata/production.yaml")),
+//  		helmut.NewValuesSet("staging", helmut.WithValues("testdata/staging.yaml")),
+//  	},
+//  )
+//
+//  results, err := r.RenderMatrix(releaseName, chartPath, sets)
+//
+//  for _, set := range sets {
+//  	result := results[set.Name]
+//
+//  	t.Run(set.Name, func(t *testing.T) {
+//  		if result.Err != nil {
+//  			t.Fatalf("failed to render templates: %s", result.Err)
+//  		}
+//  	})
+//  }
+//
+func (r *Renderer) RenderMatrix(
+	name, chart string,
+	sets []ValuesSet,
+	options ...MatrixOption,
+) (map[string]*MatrixResult, error) {
+	opts := &matrixOption{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	if opts.concurrency <= 0 {
+		opts.concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	if err := validateValuesSets(sets); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]*MatrixResult, len(sets))
+		sem     = make(chan struct{}, opts.concurrency)
+	)
+
+	for _, set := range sets {
+		set := set
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			renderOptions := make([]Option, 0, len(opts.options)+1)
+			renderOptions = append(renderOptions, opts.options...)
+			renderOptions = append(renderOptions, composeValues(set.Options))
+
+			manifests, err := r.RenderTemplates(name, chart, renderOptions...)
+			if err != nil {
+				err = fmt.Errorf("failed to render values set %q: %w", set.Name, err)
+			}
+
+			mu.Lock()
+			results[set.Name] = &MatrixResult{Manifests: manifests, Err: err}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
+
+// composeValues returns the option that applies the options of a values set,
+// whose values of WithValues, WithSet, WithSetString and WithSetFile are appended to the values
+// of the previous options instead of replacing them.
+// Within the values set, the last one of each option is used as usual.
+func composeValues(options []Option) Option {
+	return func(o *option) {
+		valueFiles, stringValues, values, fileValues := o.valueFiles, o.stringValues, o.values, o.fileValues
+		o.valueFiles, o.stringValues, o.values, o.fileValues = nil, nil, nil, nil
+
+		for _, opt := range options {
+			opt(o)
+		}
+
+		o.valueFiles = append(append([]string{}, valueFiles...), o.valueFiles...)
+		o.stringValues = append(append([]string{}, stringValues...), o.stringValues...)
+		o.values = append(append([]string{}, values...), o.values...)
+		o.fileValues = append(append([]string{}, fileValues...), o.fileValues...)
+	}
+}
+
+// validateValuesSets returns an error if a name of the values sets is empty or duplicated.
+func validateValuesSets(sets []ValuesSet) error {
+	names := make(map[string]struct{}, len(sets))
+
+	for _, set := range sets {
+		if len(strings.TrimSpace(set.Name)) == 0 {
+			return errors.New("values set name must not be empty")
+		}
+
+		if _, ok := names[set.Name]; ok {
+			return fmt.Errorf("duplicate values set name: %s", set.Name)
+		}
+
+		names[set.Name] = struct{}{}
+	}
+
+	return nil
+}
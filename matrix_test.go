@@ -0,0 +1,180 @@
+package helmut_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestCombine(t *testing.T) {
+	t.Parallel()
+
+	sets := helmut.Combine(
+		[]helmut.ValuesSet{
+			helmut.NewValuesSet("a", helmut.WithSet("a=1")),
+			helmut.NewValuesSet("b", helmut.WithSet("b=1")),
+		},
+		[]helmut.ValuesSet{
+			helmut.NewValuesSet("x", helmut.WithSet("x=1")),
+			helmut.NewValuesSet("y"),
+		},
+	)
+
+	names := make([]string, 0, len(sets))
+	for _, set := range sets {
+		names = append(names, set.Name)
+	}
+
+	if diff := cmp.Diff([]string{"a/x", "a/y", "b/x", "b/y"}, names); diff != "" {
+		t.Errorf("names mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := len(sets[0].Options); got != 2 {
+		t.Errorf("got %d options, want 2", got)
+	}
+}
+
+func TestRenderMatrix(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	sets := helmut.Combine(
+		[]helmut.ValuesSet{
+			helmut.NewValuesSet("replicas-2", helmut.WithSet("replicaCount=2")),
+			helmut.NewValuesSet("replicas-3", helmut.WithValuesMap(map[string]interface{}{"replicaCount": 3})),
+		},
+		[]helmut.ValuesSet{
+			helmut.NewValuesSet("default"),
+			helmut.NewValuesSet("tag", helmut.WithSet("image.tag=1.21.0")),
+		},
+	)
+
+	tests := []struct {
+		name string
+		want []func(*appsv1.Deployment)
+	}{
+		{name: "replicas-2/default", want: []func(*appsv1.Deployment){withDeploymentReplicas(2)}},
+		{name: "replicas-2/tag", want: []func(*appsv1.Deployment){withDeploymentReplicas(2), withDeploymentImage("nginx:1.21.0")}},
+		{name: "replicas-3/default", want: []func(*appsv1.Deployment){withDeploymentReplicas(3)}},
+		{name: "replicas-3/tag", want: []func(*appsv1.Deployment){withDeploymentReplicas(3), withDeploymentImage("nginx:1.21.0")}},
+	}
+
+	r := newRenderer(t)
+
+	results, err := r.RenderMatrix(releaseName, filepath.Join("testdata", chartName), sets, helmut.WithConcurrency(2))
+	if err != nil {
+		t.Fatalf("failed to render matrix: %s", err)
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, ok := results[tt.name]
+			if !ok {
+				t.Fatalf("result not found: %s", tt.name)
+			}
+
+			if result.Err != nil {
+				t.Fatalf("failed to render templates: %s", result.Err)
+			}
+
+			assert.Contains(t, result.Manifests, newDeployment(chartName, releaseName, tt.want...),
+				assert.WithIgnoreHelmManagedLabels())
+		})
+	}
+}
+
+func TestRenderMatrixWithCommonOptions(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	sets := []helmut.ValuesSet{
+		helmut.NewValuesSet("tag", helmut.WithSet("image.tag=1.21.0")),
+		helmut.NewValuesSet("repository", helmut.WithSet("image.repository=busybox")),
+		helmut.NewValuesSet("last", helmut.WithSet("image.tag=1.21.0"), helmut.WithSet("image.tag=1.22.0")),
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "tag", want: "httpd:1.21.0"},
+		{name: "repository", want: "busybox:1.20.0"},
+		{name: "last", want: "httpd:1.22.0"},
+	}
+
+	r := newRenderer(t)
+
+	results, err := r.RenderMatrix(releaseName, filepath.Join("testdata", chartName), sets,
+		helmut.WithCommonOptions(helmut.WithSet("image.repository=httpd", "image.tag=1.20.0")))
+	if err != nil {
+		t.Fatalf("failed to render matrix: %s", err)
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := results[tt.name]
+			if result.Err != nil {
+				t.Fatalf("failed to render templates: %s", result.Err)
+			}
+
+			assert.Contains(t, result.Manifests, newDeployment(chartName, releaseName, withDeploymentImage(tt.want)),
+				assert.WithIgnoreHelmManagedLabels())
+		})
+	}
+}
+
+func TestRenderMatrixInvalidValuesSets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sets []helmut.ValuesSet
+	}{
+		{
+			name: "duplicate name",
+			sets: []helmut.ValuesSet{helmut.NewValuesSet("a"), helmut.NewValuesSet("a")},
+		},
+		{
+			name: "empty name",
+			sets: []helmut.ValuesSet{helmut.NewValuesSet("")},
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := r.RenderMatrix("foo", filepath.Join("testdata", "test-chart"), tt.sets); err == nil {
+				t.Error("expected error, but got nil")
+			}
+		})
+	}
+}
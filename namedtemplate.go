@@ -0,0 +1,137 @@
+package helmut
+
+import (
+	"fmt"
+	"path"
+	"text/template/parse"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// executeTemplateFile is the name of the template added to the chart to execute a named template.
+const executeTemplateFile = "templates/helmut-execute-template"
+
+// ExecuteTemplate executes a named template defined by "define" in the chart or its subcharts,
+// such as the helpers in "_helpers.tpl", and returns the output like the "include" function.
+//
+// The template is executed with the same top-level object as the templates of RenderTemplates,
+// that is .Values, .Release, .Chart, .Capabilities and .Files of the chart,
+// so the values and the other options of RenderTemplates can be specified,
+// including WithUpgrade and WithDeterministicFunctions.
+// The other templates of the chart are parsed but not executed, so their errors do not affect the result.
+//
+// e.g.
+//
+//  fullname, err := r.ExecuteTemplate("foo", "testdata/test-chart", "test-chart.fullname",
+//  	helmut.WithSet("nameOverride=bar"))
+//
+func (r *Renderer) ExecuteTemplate(name, chart, templateName string, options ...Option) (string, error) {
+	if err := r.prepare(); err != nil {
+		return "", err
+	}
+
+	opts := &option{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	c, values, cleanup, err := r.loadRequest(newClient(name, opts), chart, opts)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	defined, err := definedTemplates(c)
+	if err != nil {
+		return "", err
+	}
+
+	if !defined[templateName] {
+		return "", fmt.Errorf("template %q is not defined in the chart %s", templateName, c.Name())
+	}
+
+	// The release is processed in the same way as RenderTemplates, such as merging the values of an upgrade.
+	processed, err := r.processRelease(name, c, values, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if !opts.skipSchemaValidation {
+		computed, err := chartutil.CoalesceValues(processed.Chart, processed.Config)
+		if err != nil {
+			return "", fmt.Errorf("failed to compute values: %w", err)
+		}
+
+		if err := validateValuesSchema(processed.Chart, computed); err != nil {
+			return "", fmt.Errorf("failed to validate values: %w", err)
+		}
+	}
+
+	renderValues, err := renderValues(processed, processed.Chart, opts)
+	if err != nil {
+		return "", err
+	}
+
+	executor := addTemplateExecutor(processed.Chart, templateName)
+	e := templateEngine{execute: func(name string) bool { return name == executor }}
+
+	if opts.deterministic != nil {
+		functions := newDeterministicFunctions(*opts.deterministic)
+		e.funcs, e.beforeExecute = functions.funcMap(), functions.reset
+	}
+
+	rendered, err := e.Render(processed.Chart, renderValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute template %q: %w", templateName, err)
+	}
+
+	return rendered[executor], nil
+}
+
+// addTemplateExecutor adds the template that executes the named template to the chart, and returns its name.
+// The templates are not renamed, so that the named templates are defined in the same order as RenderTemplates.
+func addTemplateExecutor(c *chart.Chart, templateName string) string {
+	c.Templates = append(c.Templates, &chart.File{
+		Name: executeTemplateFile,
+		Data: []byte(fmt.Sprintf("{{ include %q . }}", templateName)),
+	})
+
+	return path.Join(c.Name(), executeTemplateFile)
+}
+
+// definedTemplates returns the names of the templates defined in the chart and its subcharts.
+func definedTemplates(c *chart.Chart) (map[string]bool, error) {
+	defined := make(map[string]bool)
+
+	for _, t := range c.Templates {
+		tree := parse.New(t.Name)
+		tree.Mode = parse.SkipFuncCheck
+
+		trees := make(map[string]*parse.Tree)
+
+		if _, err := tree.Parse(string(t.Data), "{{", "}}", trees); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", path.Join(c.ChartFullPath(), t.Name), err)
+		}
+
+		for name := range trees {
+			if name != t.Name {
+				defined[name] = true
+			}
+		}
+	}
+
+	for _, dep := range c.Dependencies() {
+		depDefined, err := definedTemplates(dep)
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range depDefined {
+			defined[name] = true
+		}
+	}
+
+	return defined, nil
+}
@@ -0,0 +1,203 @@
+package helmut_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRendererExecuteTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		releaseName string
+		chart       string
+		template    string
+		options     []helmut.Option
+		want        string
+	}{
+		{
+			name:        "fullname",
+			releaseName: "foo",
+			chart:       "testdata/test-chart",
+			template:    "test-chart.fullname",
+			want:        "foo-test-chart",
+		},
+		{
+			name:        "fullname with release name containing chart name",
+			releaseName: "test-chart-production",
+			chart:       "testdata/test-chart",
+			template:    "test-chart.fullname",
+			want:        "test-chart-production",
+		},
+		{
+			name:        "fullname with name override",
+			releaseName: "foo",
+			chart:       "testdata/test-chart",
+			template:    "test-chart.fullname",
+			options:     []helmut.Option{helmut.WithSet("nameOverride=bar")},
+			want:        "foo-bar",
+		},
+		{
+			name:        "fullname is truncated without trailing hyphen",
+			releaseName: "foo",
+			chart:       "testdata/test-chart",
+			template:    "test-chart.fullname",
+			options:     []helmut.Option{helmut.WithSet("fullnameOverride=" + strings.Repeat("a", 62) + "-b")},
+			want:        strings.Repeat("a", 62),
+		},
+		{
+			name:        "labels",
+			releaseName: "foo",
+			chart:       "testdata/test-chart",
+			template:    "test-chart.labels",
+			want: `helm.sh/chart: test-chart-0.1.0
+app.kubernetes.io/name: test-chart
+app.kubernetes.io/instance: foo
+app.kubernetes.io/version: "1.16.0"
+app.kubernetes.io/managed-by: Helm`,
+		},
+		{
+			name:        "service account name",
+			releaseName: "foo",
+			chart:       "testdata/test-chart",
+			template:    "test-chart.serviceAccountName",
+			options:     []helmut.Option{helmut.WithSet("serviceAccount.create=false")},
+			want:        "default",
+		},
+		{
+			name:        "subchart helper",
+			releaseName: "foo",
+			chart:       "testdata/umbrella-chart",
+			template:    "test-chart.chart",
+			options: []helmut.Option{
+				helmut.WithDependencyBuild(),
+				helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart"),
+			},
+			want: "umbrella-chart-0.1.0",
+		},
+		{
+			name:        "upgrade",
+			releaseName: "foo",
+			chart:       "testdata/upgrade-chart",
+			template:    "upgrade-chart.release",
+			options: []helmut.Option{
+				helmut.WithUpgrade(helmut.PreviousRelease{Values: map[string]interface{}{"image": "nginx:1.1"}}),
+			},
+			want: "2/true/nginx:1.1",
+		},
+		{
+			// Both templates define the same name, and the definition used by RenderTemplates is executed.
+			name:        "template defined in multiple files",
+			releaseName: "foo",
+			chart:       "testdata/showonly-chart",
+			template:    "showonly-chart.owner",
+			want:        "a",
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := renderer.ExecuteTemplate(tt.releaseName, tt.chart, tt.template, tt.options...)
+			if err != nil {
+				t.Fatalf("execute error: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRendererExecuteTemplateWithDeterministicFunctions(t *testing.T) {
+	t.Parallel()
+
+	renderer := helmut.New()
+
+	execute := func(seed int64) string {
+		t.Helper()
+
+		got, err := renderer.ExecuteTemplate("foo", "testdata/deterministic-chart", "deterministic-chart.token",
+			helmut.WithDeterministicFunctions(helmut.DeterministicFunctions{Seed: seed}))
+		if err != nil {
+			t.Fatalf("execute error: %s", err)
+		}
+
+		return got
+	}
+
+	got := execute(1)
+
+	if len(got) != 8 || strings.ToLower(got) != got {
+		t.Errorf("got token %q, want 8 lower case characters", got)
+	}
+
+	if again := execute(1); again != got {
+		t.Errorf("got token %q for the same seed, want %q", again, got)
+	}
+
+	if other := execute(2); other == got {
+		t.Errorf("got the same token %q for a different seed", other)
+	}
+}
+
+func TestRendererExecuteTemplateError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		chart    string
+		template string
+		options  []helmut.Option
+		want     string
+	}{
+		{
+			name:     "undefined template",
+			chart:    "testdata/test-chart",
+			template: "test-chart.undefined",
+			want:     `template "test-chart.undefined" is not defined in the chart test-chart`,
+		},
+		{
+			name:     "template file is not a named template",
+			chart:    "testdata/test-chart",
+			template: "templates/service.yaml",
+			want:     `template "templates/service.yaml" is not defined in the chart test-chart`,
+		},
+		{
+			name:     "execution error",
+			chart:    "testdata/test-chart",
+			template: "test-chart.fullname",
+			options:  []helmut.Option{helmut.WithSet("fullnameOverride={a,b}")},
+			want:     `failed to execute template "test-chart.fullname"`,
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := renderer.ExecuteTemplate("foo", tt.chart, tt.template, tt.options...)
+			if err == nil {
+				t.Fatal("expected error, but got nil")
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
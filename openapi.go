@@ -0,0 +1,769 @@
+package helmut
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// openAPISchemaURL is the URL of the document that has all the definitions of the loaded schemas.
+	openAPISchemaURL = "file:///openapi.json"
+
+	// openAPIV3RefPrefix is the prefix of the references in the OpenAPI v3 documents,
+	// which are rewritten to the definitions like the OpenAPI v2 documents.
+	openAPIV3RefPrefix = "#/components/schemas/"
+	openAPIV2RefPrefix = "#/definitions/"
+)
+
+// OpenAPIViolation is a violation of the OpenAPI schema of the Kubernetes API.
+type OpenAPIViolation struct {
+	// Field is the path of the offending field, which is empty for the object itself.
+	// e.g. "spec.replicas", "spec.template.spec.containers[0].name"
+	Field string
+
+	// Rule is the keyword of the schema that is violated, such as "type", "enum", "format" and "required".
+	Rule string
+
+	// Message describes the violation.
+	// e.g. "missing property 'name'"
+	Message string
+}
+
+// String returns the violation in a single line.
+func (v OpenAPIViolation) String() string {
+	field := v.Field
+	if len(field) == 0 {
+		field = "(object)"
+	}
+
+	return fmt.Sprintf("%s: %s: %s", field, v.Rule, v.Message)
+}
+
+// OpenAPIReport is the report of the validation of the objects against the OpenAPI schemas.
+type OpenAPIReport struct {
+	// Violations is the violations of the objects sorted by the field paths, keyed by the objects.
+	Violations map[ObjectKey][]OpenAPIViolation
+
+	// MissingSchemas is a list of objects whose group, version and kind have no schema, sorted by the keys.
+	// They are not validated, e.g. custom resources and the kinds removed from the Kubernetes version.
+	MissingSchemas []ObjectKey
+}
+
+// Empty returns true if there is neither a violation nor an object without a schema.
+func (r *OpenAPIReport) Empty() bool {
+	return len(r.Violations) == 0 && len(r.MissingSchemas) == 0
+}
+
+// OpenAPIOption is the option used when validating the objects against the OpenAPI schemas.
+type OpenAPIOption func(*openAPIOption)
+
+// openAPIOption stores the options for the OpenAPI validation.
+type openAPIOption struct {
+	rejectUnknownFields bool
+}
+
+// WithRejectUnknownFields is an option to report the fields that are not defined in the schemas,
+// like the strict field validation of the API server.
+// The fields are allowed if the schema has "x-kubernetes-preserve-unknown-fields".
+func WithRejectUnknownFields() OpenAPIOption {
+	return func(o *openAPIOption) {
+		o.rejectUnknownFields = true
+	}
+}
+
+// OpenAPISchemas is the OpenAPI schemas of the Kubernetes API, used to validate the objects offline
+// like kubeconform.
+// This package does not ship any schemas, because they depend on the Kubernetes version.
+// Download the documents of the Kubernetes version from api/openapi-spec of the Kubernetes repository,
+// or get them from a cluster with "kubectl get --raw /openapi/v2", and load them from the files.
+type OpenAPISchemas struct {
+	// definitions is the schemas keyed by the names of the definitions.
+	definitions map[string]interface{}
+
+	// kinds is the names of the definitions keyed by the group, version and kind.
+	kinds map[schema.GroupVersionKind]string
+
+	mu sync.Mutex
+
+	// compilers is the compilers of the definitions, keyed by whether the unknown fields are rejected.
+	compilers map[bool]*jsonschema.Compiler
+}
+
+// LoadOpenAPISchemas loads the OpenAPI v2 or v3 documents of the Kubernetes API from the files or directories.
+// All the JSON files in the directories are loaded recursively,
+// such as the "swagger.json" of OpenAPI v2 and the "api/v1.json" and "apis/apps/v1.json" of OpenAPI v3.
+// If the same definition is in multiple documents, the first one is used.
+//
+// e.g.
+//
+//  schemas, err := helmut.LoadOpenAPISchemas("testdata/openapi/v1.30.0")
+//
+//  report, err := schemas.Validate(manifests)
+//
+func LoadOpenAPISchemas(paths ...string) (*OpenAPISchemas, error) {
+	s := newOpenAPISchemas()
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OpenAPI schemas: %w", err)
+		}
+
+		if info.IsDir() {
+			err = s.loadDir(os.DirFS(p), ".")
+		} else {
+			err = s.loadFile(os.DirFS(filepath.Dir(p)), filepath.Base(p), true)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// LoadOpenAPISchemasFS loads the OpenAPI documents of the Kubernetes version from the file system
+// that has a directory for each version, such as "v1.30.0" and "v1.31.0".
+// The directory of the same version is used, or the latest patch version of the same minor version.
+// If the version is empty, all the documents in the file system are loaded.
+//
+// The documents are provided by the caller, e.g. embedded in the test binary.
+//
+// e.g.
+//
+//  //go:embed openapi
+//  var openAPI embed.FS
+//
+//  fsys, err := fs.Sub(openAPI, "openapi")
+//
+//  schemas, err := helmut.LoadOpenAPISchemasFS(fsys, "1.30")
+//
+func LoadOpenAPISchemasFS(fsys fs.FS, kubeVersion string) (*OpenAPISchemas, error) {
+	dir := "."
+
+	if len(kubeVersion) != 0 {
+		var err error
+
+		dir, err = findKubeVersionDir(fsys, kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := newOpenAPISchemas()
+
+	if err := s.loadDir(fsys, dir); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// newOpenAPISchemas returns the empty schemas to load the documents into.
+func newOpenAPISchemas() *OpenAPISchemas {
+	return &OpenAPISchemas{
+		definitions: make(map[string]interface{}),
+		kinds:       make(map[schema.GroupVersionKind]string),
+		compilers:   make(map[bool]*jsonschema.Compiler),
+	}
+}
+
+// findKubeVersionDir returns the directory of the Kubernetes version.
+func findKubeVersionDir(fsys fs.FS, kubeVersion string) (string, error) {
+	want, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Kubernetes version %q: %w", kubeVersion, err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return "", fmt.Errorf("failed to read OpenAPI schema directories: %w", err)
+	}
+
+	var (
+		dir    string
+		latest *semver.Version
+	)
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		v, err := semver.NewVersion(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		if v.Equal(want) {
+			return entry.Name(), nil
+		}
+
+		if v.Major() == want.Major() && v.Minor() == want.Minor() && (latest == nil || v.GreaterThan(latest)) {
+			dir, latest = entry.Name(), v
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("no OpenAPI schemas for Kubernetes version %s", kubeVersion)
+	}
+
+	return dir, nil
+}
+
+// loadDir loads all the JSON files in the directory.
+func (s *OpenAPISchemas) loadDir(fsys fs.FS, dir string) error {
+	var names []string
+
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() && path.Ext(name) == ".json" {
+			names = append(names, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read OpenAPI schema directory: %w", err)
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("no OpenAPI schema files in %s", dir)
+	}
+
+	for _, name := range names {
+		if err := s.loadFile(fsys, name, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadFile loads the definitions of the OpenAPI document.
+// The files that are not OpenAPI documents are ignored unless required.
+func (s *OpenAPISchemas) loadFile(fsys fs.FS, name string, required bool) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open OpenAPI schema file: %w", err)
+	}
+	defer f.Close()
+
+	doc, err := jsonschema.UnmarshalJSON(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse OpenAPI schema file %s: %w", name, err)
+	}
+
+	root, _ := doc.(map[string]interface{})
+
+	definitions, ok := root["definitions"].(map[string]interface{})
+	if !ok {
+		components, _ := root["components"].(map[string]interface{})
+		definitions, ok = components["schemas"].(map[string]interface{})
+	}
+
+	if !ok {
+		if required {
+			return fmt.Errorf("%s is not an OpenAPI document: neither definitions nor components.schemas found", name)
+		}
+
+		return nil
+	}
+
+	for defName, definition := range definitions {
+		if _, ok := s.definitions[defName]; ok {
+			continue
+		}
+
+		s.definitions[defName] = convertOpenAPISchema(definition)
+
+		for _, gvk := range openAPIGroupVersionKinds(definition) {
+			if _, ok := s.kinds[gvk]; !ok {
+				s.kinds[gvk] = defName
+			}
+		}
+	}
+
+	return nil
+}
+
+// openAPIGroupVersionKinds returns the group, version and kind of the definition from "x-kubernetes-group-version-kind".
+func openAPIGroupVersionKinds(definition interface{}) []schema.GroupVersionKind {
+	d, _ := definition.(map[string]interface{})
+	extensions, _ := d["x-kubernetes-group-version-kind"].([]interface{})
+
+	gvks := make([]schema.GroupVersionKind, 0, len(extensions))
+
+	for _, e := range extensions {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		group, _ := m["group"].(string)
+		version, _ := m["version"].(string)
+		kind, _ := m["kind"].(string)
+
+		gvks = append(gvks, schema.GroupVersionKind{Group: group, Version: version, Kind: kind})
+	}
+
+	return gvks
+}
+
+// convertOpenAPISchema converts the schema of the OpenAPI document to JSON schema.
+// The references of OpenAPI v3 are rewritten to the definitions,
+// the int-or-string types are rewritten to accept both integers and strings,
+// and the nullable types are rewritten to accept null.
+func convertOpenAPISchema(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+
+		for key, value := range v {
+			converted[key] = convertOpenAPISchema(value)
+		}
+
+		if ref, ok := converted["$ref"].(string); ok && strings.HasPrefix(ref, openAPIV3RefPrefix) {
+			converted["$ref"] = openAPIV2RefPrefix + strings.TrimPrefix(ref, openAPIV3RefPrefix)
+		}
+
+		if nullable, _ := converted["nullable"].(bool); nullable {
+			if t, ok := converted["type"].(string); ok {
+				converted["type"] = []interface{}{t, "null"}
+			}
+		}
+
+		intOrString, _ := converted["x-kubernetes-int-or-string"].(bool)
+
+		if converted["format"] == "int-or-string" || intOrString {
+			delete(converted, "type")
+			delete(converted, "format")
+
+			if _, ok := converted["anyOf"]; !ok {
+				converted["anyOf"] = []interface{}{
+					map[string]interface{}{"type": "integer"},
+					map[string]interface{}{"type": "string"},
+				}
+			}
+		}
+
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, 0, len(v))
+
+		for _, value := range v {
+			converted = append(converted, convertOpenAPISchema(value))
+		}
+
+		return converted
+	default:
+		return v
+	}
+}
+
+// rejectUnknownFields returns the schema that disallows the properties not defined in the objects.
+func rejectUnknownFields(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v)+1)
+
+		for key, value := range v {
+			if key == "properties" {
+				// The property names are not schemas.
+				properties, _ := value.(map[string]interface{})
+				convertedProperties := make(map[string]interface{}, len(properties))
+
+				for name, property := range properties {
+					convertedProperties[name] = rejectUnknownFields(property)
+				}
+
+				converted[key] = convertedProperties
+
+				continue
+			}
+
+			converted[key] = rejectUnknownFields(value)
+		}
+
+		_, hasProperties := v["properties"]
+		_, hasAdditional := v["additionalProperties"]
+		preserve, _ := v["x-kubernetes-preserve-unknown-fields"].(bool)
+
+		if hasProperties && !hasAdditional && !preserve {
+			converted["additionalProperties"] = false
+		}
+
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, 0, len(v))
+
+		for _, value := range v {
+			converted = append(converted, rejectUnknownFields(value))
+		}
+
+		return converted
+	default:
+		return v
+	}
+}
+
+// openAPIFormats is the formats of OpenAPI that are not defined in JSON schema.
+var openAPIFormats = []*jsonschema.Format{
+	{Name: "int32", Validate: validateInt32},
+	{Name: "byte", Validate: validateByte},
+}
+
+// validateInt32 checks if the integer is in the range of int32.
+func validateInt32(v interface{}) error {
+	var n float64
+
+	switch v := v.(type) {
+	case int64:
+		n = float64(v)
+	case int:
+		n = float64(v)
+	case float64:
+		n = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil //nolint:nilerr // The type is checked by the "type" keyword.
+		}
+
+		n = f
+	default:
+		return nil
+	}
+
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return errors.New("out of the range of int32")
+	}
+
+	return nil
+}
+
+// validateByte checks if the string is encoded in base64.
+func validateByte(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+
+	_, err := base64.StdEncoding.DecodeString(s)
+
+	return err
+}
+
+// Validate validates the objects of the manifests against the schemas of their group, version and kind,
+// and returns the violations such as missing required fields, values not in enums and invalid formats.
+// The objects whose group, version and kind have no schema are reported as MissingSchemas.
+//
+// The objects split from the rendered manifests, such as those of RenderTemplates, are validated as their YAML documents,
+// so the unknown fields and the missing required fields are reported even if the objects are decoded into types.
+// The objects stored by Manifests.Store are validated as they are serialized,
+// so a missing required field of a typed object cannot be distinguished from its zero value
+// if the field is not omitted when empty, such as the port of a ServicePort.
+//
+// e.g.
+//
+//  report, err := schemas.Validate(manifests, helmut.WithRejectUnknownFields())
+//
+//  for key, violations := range report.Violations {
+//  	for _, v := range violations {
+//  		fmt.Println(key, v.Field, v.Message)
+//  	}
+//  }
+//
+func (s *OpenAPISchemas) Validate(manifests *Manifests, options ...OpenAPIOption) (*OpenAPIReport, error) {
+	opts := &openAPIOption{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	report := &OpenAPIReport{}
+
+	keys := manifests.GetKeys()
+	SortObjectKeys(keys)
+
+	for _, key := range keys {
+		name, ok := s.kinds[key.GetGroupVersionKind()]
+		if !ok {
+			report.MissingSchemas = append(report.MissingSchemas, key)
+
+			continue
+		}
+
+		sch, err := s.compile(name, opts.rejectUnknownFields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile the OpenAPI schema of %s: %w", key, err)
+		}
+
+		instance, err := openAPIInstance(manifests, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+
+		violations, err := validateInstance(sch, instance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate %s: %w", key, err)
+		}
+
+		if len(violations) == 0 {
+			continue
+		}
+
+		if report.Violations == nil {
+			report.Violations = make(map[ObjectKey][]OpenAPIViolation)
+		}
+
+		report.Violations[key] = violations
+	}
+
+	return report, nil
+}
+
+// compile compiles the schema of the definition.
+// The compiled schemas are cached by the compilers.
+func (s *OpenAPISchemas) compile(name string, strict bool) (*jsonschema.Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	compiler, ok := s.compilers[strict]
+	if !ok {
+		var definitions interface{} = s.definitions
+		if strict {
+			definitions = rejectUnknownFields(definitions)
+		}
+
+		compiler = newOpenAPICompiler()
+
+		if err := compiler.AddResource(openAPISchemaURL, map[string]interface{}{"definitions": definitions}); err != nil {
+			return nil, err
+		}
+
+		s.compilers[strict] = compiler
+	}
+
+	return compiler.Compile(openAPISchemaURL + openAPIV2RefPrefix + escapePointerToken(name))
+}
+
+// newOpenAPICompiler returns a compiler of the schemas of OpenAPI that asserts the formats.
+// The references to the other documents are not resolved, so that the validation does not access the files.
+func newOpenAPICompiler() *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft4)
+	compiler.AssertFormat()
+	compiler.UseLoader(jsonschema.SchemeURLLoader{})
+
+	for _, f := range openAPIFormats {
+		compiler.RegisterFormat(f)
+	}
+
+	return compiler
+}
+
+// openAPIInstance returns the object of the key as the instance of the schema.
+// The objects split from the rendered manifests are read from their YAML documents,
+// because decoding into the types drops the unknown fields and fills the missing fields with their zero values.
+func openAPIInstance(manifests *Manifests, key ObjectKey) (map[string]interface{}, error) {
+	if document, ok := manifests.loadDocument(key); ok {
+		data, err := yaml.YAMLToJSON(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+		}
+
+		instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+
+		if m, ok := instance.(map[string]interface{}); ok {
+			return m, nil
+		}
+
+		return nil, errors.New("the document is not an object")
+	}
+
+	object, _ := manifests.Load(key)
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object to unstructured: %w", err)
+	}
+
+	// The typed objects have the null fields that are omitted by the API server, such as the creation timestamp.
+	instance, _ := pruneNullFields(u).(map[string]interface{})
+
+	gvk := key.GetGroupVersionKind()
+	instance["apiVersion"] = gvk.GroupVersion().String()
+	instance["kind"] = gvk.Kind
+
+	return instance, nil
+}
+
+// validateInstance validates the unstructured object against the schema
+// and returns the violations sorted by the field paths.
+func validateInstance(sch *jsonschema.Schema, instance map[string]interface{}) ([]OpenAPIViolation, error) {
+	err := sch.Validate(instance)
+	if err == nil {
+		return nil, nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError) //nolint:errorlint // Validate returns the error as is.
+	if !ok {
+		return nil, err
+	}
+
+	violations := openAPIViolations(instance, validationErr)
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Field != violations[j].Field {
+			return violations[i].Field < violations[j].Field
+		}
+
+		return violations[i].Rule < violations[j].Rule
+	})
+
+	return violations, nil
+}
+
+// pruneNullFields returns the value without the fields whose values are null.
+func pruneNullFields(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		pruned := make(map[string]interface{}, len(v))
+
+		for key, value := range v {
+			if value != nil {
+				pruned[key] = pruneNullFields(value)
+			}
+		}
+
+		return pruned
+	case []interface{}:
+		pruned := make([]interface{}, 0, len(v))
+
+		for _, value := range v {
+			pruned = append(pruned, pruneNullFields(value))
+		}
+
+		return pruned
+	default:
+		return v
+	}
+}
+
+// openAPIViolations returns the violations of the leaves of the validation error like schemaViolations.
+// The missing properties and the unknown properties are reported for each property.
+func openAPIViolations(instance map[string]interface{}, err *jsonschema.ValidationError) []OpenAPIViolation {
+	switch err.ErrorKind.(type) {
+	case *kind.AnyOf, *kind.OneOf:
+	default:
+		if len(err.Causes) != 0 {
+			var violations []OpenAPIViolation
+
+			for _, cause := range err.Causes {
+				violations = append(violations, openAPIViolations(instance, cause)...)
+			}
+
+			return violations
+		}
+	}
+
+	printer := message.NewPrinter(language.English)
+	field := instanceFieldPath(instance, err.InstanceLocation)
+
+	rule := strings.Join(err.ErrorKind.KeywordPath(), "/")
+	if len(rule) == 0 {
+		rule = "false"
+	}
+
+	switch k := err.ErrorKind.(type) {
+	case *kind.Required:
+		violations := make([]OpenAPIViolation, 0, len(k.Missing))
+
+		for _, property := range k.Missing {
+			violations = append(violations, OpenAPIViolation{
+				Field:   joinFieldPath(field, property),
+				Rule:    rule,
+				Message: (&kind.Required{Missing: []string{property}}).LocalizedString(printer),
+			})
+		}
+
+		return violations
+	case *kind.AdditionalProperties:
+		violations := make([]OpenAPIViolation, 0, len(k.Properties))
+
+		for _, property := range k.Properties {
+			violations = append(violations, OpenAPIViolation{
+				Field:   joinFieldPath(field, property),
+				Rule:    rule,
+				Message: (&kind.AdditionalProperties{Properties: []string{property}}).LocalizedString(printer),
+			})
+		}
+
+		return violations
+	}
+
+	return []OpenAPIViolation{{
+		Field:   field,
+		Rule:    rule,
+		Message: err.ErrorKind.LocalizedString(printer),
+	}}
+}
+
+// instanceFieldPath returns the field path of the location of the JSON instance,
+// such as "spec.template.spec.containers[0].name".
+func instanceFieldPath(instance map[string]interface{}, location []string) string {
+	var (
+		field   string
+		current interface{} = instance
+	)
+
+	for _, token := range location {
+		switch v := current.(type) {
+		case []interface{}:
+			field += "[" + token + "]"
+
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(v) {
+				current = v[i]
+			} else {
+				current = nil
+			}
+		case map[string]interface{}:
+			field = joinFieldPath(field, token)
+			current = v[token]
+		default:
+			field = joinFieldPath(field, token)
+			current = nil
+		}
+	}
+
+	return field
+}
@@ -0,0 +1,351 @@
+package helmut_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestOpenAPISchemasValidate(t *testing.T) {
+	t.Parallel()
+
+	var (
+		configMapKey  = helmut.NewObjectKey("default", "foo", schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+		serviceKey    = helmut.NewObjectKey("default", "foo", schema.GroupVersionKind{Version: "v1", Kind: "Service"})
+		deploymentKey = helmut.NewObjectKey("default", "foo",
+			schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	)
+
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "foo", "namespace": "default", "unknown": "foo"},
+		"data":       map[string]interface{}{"key": "value"},
+		"binaryData": map[string]interface{}{"key": "not base64"},
+	}}
+
+	type object struct {
+		key    helmut.ObjectKey
+		object runtime.Object
+	}
+
+	tests := []struct {
+		name           string
+		objects        []object
+		options        []helmut.OpenAPIOption
+		wantViolations map[helmut.ObjectKey][]helmut.OpenAPIViolation
+		wantMissing    []helmut.ObjectKey
+	}{
+		{
+			name: "valid",
+			objects: []object{
+				{key: serviceKey, object: &corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+					Spec: corev1.ServiceSpec{
+						Type: corev1.ServiceTypeClusterIP,
+						Ports: []corev1.ServicePort{
+							{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP, TargetPort: intstr.FromString("http")},
+							{Name: "metrics", Port: 9090, TargetPort: intstr.FromInt32(9090)},
+						},
+					},
+				}},
+			},
+		},
+		{
+			name: "invalid",
+			objects: []object{
+				{key: serviceKey, object: &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Service",
+					"metadata":   map[string]interface{}{"name": "foo", "namespace": "default"},
+					"spec": map[string]interface{}{
+						"type": "Foo",
+						"ports": []interface{}{
+							map[string]interface{}{"name": "http", "port": int64(80), "protocol": "HTTP"},
+							map[string]interface{}{"name": "metrics", "targetPort": int64(9090)},
+							map[string]interface{}{"name": "admin", "port": int64(3000000000)},
+						},
+					},
+				}}},
+			},
+			wantViolations: map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+				serviceKey: {
+					{Field: "spec.ports[0].protocol", Rule: "enum", Message: "value must be one of 'SCTP', 'TCP', 'UDP'"},
+					{Field: "spec.ports[1].port", Rule: "required", Message: "missing property 'port'"},
+					{
+						Field: "spec.ports[2].port", Rule: "format",
+						Message: "3000000000 is not valid int32: out of the range of int32",
+					},
+					{
+						Field: "spec.type", Rule: "enum",
+						Message: "value must be one of 'ClusterIP', 'ExternalName', 'LoadBalancer', 'NodePort'",
+					},
+				},
+			},
+		},
+		{
+			name: "format",
+			objects: []object{
+				{key: configMapKey, object: configMap},
+			},
+			wantViolations: map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+				configMapKey: {
+					{
+						Field: "binaryData.key", Rule: "format",
+						Message: "'not base64' is not valid byte: illegal base64 data at input byte 3",
+					},
+				},
+			},
+		},
+		{
+			name: "reject unknown fields",
+			objects: []object{
+				{key: configMapKey, object: configMap},
+			},
+			options: []helmut.OpenAPIOption{helmut.WithRejectUnknownFields()},
+			wantViolations: map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+				configMapKey: {
+					{
+						Field: "binaryData.key", Rule: "format",
+						Message: "'not base64' is not valid byte: illegal base64 data at input byte 3",
+					},
+					{Field: "metadata.unknown", Rule: "additionalProperties", Message: "additional properties 'unknown' not allowed"},
+				},
+			},
+		},
+		{
+			name: "missing schema",
+			objects: []object{
+				{key: deploymentKey, object: &appsv1.Deployment{}},
+			},
+			wantMissing: []helmut.ObjectKey{deploymentKey},
+		},
+	}
+
+	schemas, err := helmut.LoadOpenAPISchemas("testdata/openapi/v1.30.0")
+	if err != nil {
+		t.Fatalf("failed to load schemas: %s", err)
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests := helmut.NewManifests()
+
+			for _, o := range tt.objects {
+				manifests.Store(o.key, o.object)
+			}
+
+			report, err := schemas.Validate(manifests, tt.options...)
+			if err != nil {
+				t.Fatalf("validate error: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.wantViolations, report.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.wantMissing, report.MissingSchemas); diff != "" {
+				t.Errorf("missing schemas mismatch (-want +got):\n%s", diff)
+			}
+
+			if got, want := report.Empty(), tt.wantViolations == nil && tt.wantMissing == nil; got != want {
+				t.Errorf("got empty %t, want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenAPISchemasValidateRenderedManifests(t *testing.T) {
+	t.Parallel()
+
+	manifests, err := newRenderer(t).RenderTemplates("foo", "testdata/openapi-chart")
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	schemas, err := helmut.LoadOpenAPISchemas("testdata/openapi/v1.31.0")
+	if err != nil {
+		t.Fatalf("failed to load schemas: %s", err)
+	}
+
+	report, err := schemas.Validate(manifests, helmut.WithRejectUnknownFields())
+	if err != nil {
+		t.Fatalf("validate error: %s", err)
+	}
+
+	deploymentKey := helmut.NewObjectKey("", "foo", schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+
+	// The unknown field and the missing name are dropped and zero-filled by decoding the Deployment.
+	want := map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+		deploymentKey: {
+			{
+				Field: "metadata.bogusField", Rule: "additionalProperties",
+				Message: "additional properties 'bogusField' not allowed",
+			},
+			{
+				Field: "spec.template.spec.containers[0].imagePullPolicy", Rule: "enum",
+				Message: "value must be one of 'Always', 'IfNotPresent', 'Never'",
+			},
+			{
+				Field: "spec.template.spec.containers[0].name", Rule: "required",
+				Message: "missing property 'name'",
+			},
+		},
+	}
+
+	if diff := cmp.Diff(want, report.Violations); diff != "" {
+		t.Errorf("violations mismatch (-want +got):\n%s", diff)
+	}
+
+	// The object stored again is validated as it is serialized.
+	object, _ := manifests.Load(deploymentKey)
+	manifests.Store(deploymentKey, object)
+
+	report, err = schemas.Validate(manifests, helmut.WithRejectUnknownFields())
+	if err != nil {
+		t.Fatalf("validate error: %s", err)
+	}
+
+	if got := len(report.Violations[deploymentKey]); got != 1 {
+		t.Errorf("got %d violations of the stored object, want 1: %v", got, report.Violations[deploymentKey])
+	}
+}
+
+func TestLoadOpenAPISchemasFS(t *testing.T) {
+	t.Parallel()
+
+	deploymentKey := helmut.NewObjectKey("default", "foo",
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	serviceKey := helmut.NewObjectKey("default", "foo", schema.GroupVersionKind{Version: "v1", Kind: "Service"})
+
+	manifests := helmut.NewManifests()
+	manifests.Store(deploymentKey, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: "nginx", ImagePullPolicy: "Sometimes"}}},
+			},
+		},
+	})
+	manifests.Store(serviceKey, &corev1.Service{})
+
+	tests := []struct {
+		name           string
+		kubeVersion    string
+		wantViolations map[helmut.ObjectKey][]helmut.OpenAPIViolation
+		wantMissing    []helmut.ObjectKey
+	}{
+		{
+			name:        "v2",
+			kubeVersion: "v1.30.0",
+			wantMissing: []helmut.ObjectKey{deploymentKey},
+		},
+		{
+			name:        "latest patch version",
+			kubeVersion: "1.31.5",
+			wantViolations: map[helmut.ObjectKey][]helmut.OpenAPIViolation{
+				deploymentKey: {
+					{Field: "spec.selector", Rule: "required", Message: "missing property 'selector'"},
+					{
+						Field: "spec.template.spec.containers[0].imagePullPolicy", Rule: "enum",
+						Message: "value must be one of 'Always', 'IfNotPresent', 'Never'",
+					},
+				},
+			},
+			wantMissing: []helmut.ObjectKey{serviceKey},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schemas, err := helmut.LoadOpenAPISchemasFS(os.DirFS("testdata/openapi"), tt.kubeVersion)
+			if err != nil {
+				t.Fatalf("failed to load schemas: %s", err)
+			}
+
+			report, err := schemas.Validate(manifests)
+			if err != nil {
+				t.Fatalf("validate error: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.wantViolations, report.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.wantMissing, report.MissingSchemas); diff != "" {
+				t.Errorf("missing schemas mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadOpenAPISchemasError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		load func() error
+	}{
+		{
+			name: "not found",
+			load: func() error {
+				_, err := helmut.LoadOpenAPISchemas("testdata/openapi/not-found")
+
+				return err
+			},
+		},
+		{
+			name: "not an OpenAPI document",
+			load: func() error {
+				_, err := helmut.LoadOpenAPISchemas("testdata/schema-chart/values.schema.json")
+
+				return err
+			},
+		},
+		{
+			name: "version not found",
+			load: func() error {
+				_, err := helmut.LoadOpenAPISchemasFS(os.DirFS("testdata/openapi"), "1.29")
+
+				return err
+			},
+		},
+		{
+			name: "invalid version",
+			load: func() error {
+				_, err := helmut.LoadOpenAPISchemasFS(os.DirFS("testdata/openapi"), "latest")
+
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.load(); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
@@ -1,16 +1,57 @@
 package helmut
 
+import (
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
 // option stores the template options.
 type option struct {
 	namespace   string
 	apiVersions []string
 	includeCRDs bool
+	showOnly    []string
+
+	deterministic *DeterministicFunctions
+
+	skipSchemaValidation bool
+
+	// chart path options
+	repoURL               string
+	version               string
+	username              string
+	password              string
+	certFile              string
+	keyFile               string
+	caFile                string
+	insecureSkipTLSVerify bool
+	plainHTTP             bool
+
+	// upgrade options
+	upgrade upgradeOption
+
+	// post-render options
+	postRenderers []postRendererFactory
+
+	// dependency options
+	dependencyBuild   bool
+	localRepositories map[string]string
 
 	// value options
-	valueFiles   []string
-	stringValues []string
-	values       []string
-	fileValues   []string
+	valueFiles    []string
+	stringValues  []string
+	values        []string
+	fileValues    []string
+	jsonValues    []string
+	literalValues []string
+	valuesMaps    []map[string]interface{}
+	valuesTrace   bool
+
+	// coverage is the template coverage of the Renderer, which is set by RenderTemplates.
+	coverage *TemplateCoverage
 }
 
 // Option is an option to specify when calling RenderTemplates.
@@ -34,14 +75,213 @@ func WithAPIVersions(apiVersions ...string) Option {
 
 // WithIncludeCRDs will include CRDs in the templated output.
 // This is equivalent to the "--include-crds" option of the "helm template" command.
+// With WithUpgrade, the CRDs are also included before the manifests, although "helm upgrade" does not render them.
 func WithIncludeCRDs() Option {
 	return func(o *option) {
 		o.includeCRDs = true
 	}
 }
 
+// WithShowOnly renders only the manifests of the template files (can specify multiple).
+// This is equivalent to the "--show-only" option of the "helm template" command.
+// The files are the paths relative to the chart directory, such as "templates/deployment.yaml",
+// "crds/crd.yaml" with WithIncludeCRDs and "charts/test-chart/templates/deployment.yaml" of a subchart,
+// and can be glob patterns.
+// The whole chart is rendered and the manifests are filtered by the source comments that helm adds,
+// so the post-renderers must keep the comments.
+// An error is returned if a file does not exist or renders no manifests.
+func WithShowOnly(files ...string) Option {
+	return func(o *option) {
+		o.showOnly = append(o.showOnly, files...)
+	}
+}
+
+// WithDeterministicFunctions replaces the template functions whose results change every render
+// with the deterministic implementations, so that the manifests are the same for the same seed.
+// The functions are "now", "ago", "randAlphaNum", "randAlpha", "randNumeric", "randAscii", "randBytes",
+// "randInt", "shuffle", "uuidv4", "genPrivateKey", "genCA", "genCAWithKey", "genSelfSignedCert",
+// "genSelfSignedCertWithKey", "genSignedCert" and "genSignedCertWithKey".
+// The certificates are valid from the current time of the config.
+// The RSA keys are chosen from a fixed set of pregenerated keys, so they must not be used outside of tests.
+func WithDeterministicFunctions(config DeterministicFunctions) Option {
+	return func(o *option) {
+		o.deterministic = &config
+	}
+}
+
+// WithSkipSchemaValidation disables the validation of the values against the values.schema.json of the chart.
+// By default, *ValuesSchemaError is returned if the values do not meet the schema.
+// This is equivalent to the "--skip-schema-validation" option of the "helm template" command.
+func WithSkipSchemaValidation() Option {
+	return func(o *option) {
+		o.skipSchemaValidation = true
+	}
+}
+
+// WithRepoURL specifies the chart repository URL where to locate the requested chart.
+// This is equivalent to the "--repo" option of the "helm template" command.
+func WithRepoURL(url string) Option {
+	return func(o *option) {
+		o.repoURL = url
+	}
+}
+
+// WithVersion specifies a version constraint for the chart version to use.
+// If not specified, the latest version is used.
+// For an OCI chart reference, the version is resolved from the tags of the repository.
+// This is equivalent to the "--version" option of the "helm template" command.
+func WithVersion(version string) Option {
+	return func(o *option) {
+		o.version = version
+	}
+}
+
+// WithBasicAuth specifies the username and password used to access the chart repository or the OCI registry.
+// This is equivalent to the "--username" and "--password" options of the "helm template" command.
+func WithBasicAuth(username, password string) Option {
+	return func(o *option) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithTLSClientConfig specifies the client certificate, the client key and the CA bundle
+// used to access the chart repository or the OCI registry.
+// An empty path is ignored.
+// This is equivalent to the "--cert-file", "--key-file" and "--ca-file" options of the "helm template" command.
+func WithTLSClientConfig(certFile, keyFile, caFile string) Option {
+	return func(o *option) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+		o.caFile = caFile
+	}
+}
+
+// WithInsecureSkipTLSVerify skips the TLS certificate checks of the chart repository or the OCI registry.
+// This is equivalent to the "--insecure-skip-tls-verify" option of the "helm template" command.
+func WithInsecureSkipTLSVerify() Option {
+	return func(o *option) {
+		o.insecureSkipTLSVerify = true
+	}
+}
+
+// WithPlainHTTP uses insecure HTTP connections to download the chart from the OCI registry.
+// This is equivalent to the "--plain-http" option of the "helm template" command.
+func WithPlainHTTP() Option {
+	return func(o *option) {
+		o.plainHTTP = true
+	}
+}
+
+// WithUpgrade renders the chart as an upgrade of the previous release,
+// like the "helm upgrade --dry-run" command against a cluster where the previous release is deployed.
+// The ".Release.IsUpgrade" is true and the ".Release.Revision" is the next revision of the previous release,
+// and the values are merged with the values of the previous release according to the upgrade rules of helm.
+// By default, the values of the previous release are reused only if no values are specified.
+func WithUpgrade(previous PreviousRelease) Option {
+	return func(o *option) {
+		o.upgrade.previous = &previous
+	}
+}
+
+// WithReuseValues reuses the values of the previous release and merges the specified values into them,
+// ignoring the default values of the chart being rendered.
+// It applies only with WithUpgrade.
+// This is equivalent to the "--reuse-values" option of the "helm upgrade" command.
+func WithReuseValues() Option {
+	return func(o *option) {
+		o.upgrade.reuseValues = true
+	}
+}
+
+// WithResetValues ignores the values of the previous release and uses only the specified values.
+// It applies only with WithUpgrade.
+// This is equivalent to the "--reset-values" option of the "helm upgrade" command.
+func WithResetValues() Option {
+	return func(o *option) {
+		o.upgrade.resetValues = true
+	}
+}
+
+// WithResetThenReuseValues uses the default values of the chart being rendered,
+// and merges the values of the previous release and the specified values into them.
+// It applies only with WithUpgrade.
+// This is equivalent to the "--reset-then-reuse-values" option of the "helm upgrade" command.
+func WithResetThenReuseValues() Option {
+	return func(o *option) {
+		o.upgrade.resetThenReuseValues = true
+	}
+}
+
+// WithPostRenderer specifies a post-renderer that modifies the rendered manifests (can specify multiple).
+// Post-renderers specified by WithPostRenderer, WithPostRendererExec and WithPostRendererFunc run in order.
+// This is equivalent to the "--post-renderer" option of the "helm template" command.
+func WithPostRenderer(postRenderer postrender.PostRenderer) Option {
+	return func(o *option) {
+		o.postRenderers = append(o.postRenderers, func(*Renderer) (postrender.PostRenderer, error) {
+			return postRenderer, nil
+		})
+	}
+}
+
+// WithPostRendererExec specifies the path to an executable used as a post-renderer (can specify multiple).
+// The executable receives the rendered manifests on stdin and writes the modified manifests to stdout.
+// If the path does not contain a separator, the executable is looked up in PATH.
+// This is equivalent to the "--post-renderer" and "--post-renderer-args" options of the "helm template" command.
+func WithPostRendererExec(path string, args ...string) Option {
+	return func(o *option) {
+		o.postRenderers = append(o.postRenderers, func(*Renderer) (postrender.PostRenderer, error) {
+			postRenderer, err := postrender.NewExec(path, args...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create post-renderer: %w", err)
+			}
+
+			return postRenderer, nil
+		})
+	}
+}
+
+// WithPostRendererFunc specifies a function used as a post-renderer (can specify multiple).
+// The function receives the rendered manifests split by the scheme of the Renderer,
+// and the modified manifests are written back in the order of their keys.
+func WithPostRendererFunc(fn PostRenderFunc) Option {
+	return func(o *option) {
+		o.postRenderers = append(o.postRenderers, func(r *Renderer) (postrender.PostRenderer, error) {
+			return &manifestsPostRenderer{renderer: r, fn: fn}, nil
+		})
+	}
+}
+
+// WithDependencyBuild builds the "charts/" directory of the chart from the dependencies in Chart.yaml before rendering.
+// This is equivalent to running the "helm dependency build" command before the "helm template" command,
+// but it works offline and does not modify the chart, since the dependencies are built in a temporary copy of the chart.
+//
+// Dependencies are resolved from "file://" repositories, that refer to a chart directory,
+// a chart archive or a directory containing chart archives,
+// and from the repositories mapped to local directories by WithLocalRepository.
+// Rendering fails if a dependency is missing or its version constraint does not match.
+func WithDependencyBuild() Option {
+	return func(o *option) {
+		o.dependencyBuild = true
+	}
+}
+
+// WithLocalRepository maps a repository URL of the dependencies in Chart.yaml
+// to a local directory containing chart archives (can specify multiple).
+// It is used to resolve dependencies offline with WithDependencyBuild.
+func WithLocalRepository(url, dir string) Option {
+	return func(o *option) {
+		if o.localRepositories == nil {
+			o.localRepositories = make(map[string]string)
+		}
+
+		o.localRepositories[strings.TrimSuffix(url, "/")] = dir
+	}
+}
+
 // WithValues specifies values in a YAML file or a URL (can specify multiple).
 // This is equivalent to the "--values" or "-f" option of the "helm template" command.
+// If specified more than once, the last one is used.
 func WithValues(files ...string) Option {
 	return func(o *option) {
 		o.valueFiles = files
@@ -51,6 +291,7 @@ func WithValues(files ...string) Option {
 // WithSetString set STRING values just like the command line.
 // (can specify multiple or separate values with commas: key1=val1,key2=val2)
 // This is equivalent to the "--set-string" option of the "helm template" command.
+// If specified more than once, the last one is used.
 func WithSetString(values ...string) Option {
 	return func(o *option) {
 		o.stringValues = values
@@ -60,6 +301,7 @@ func WithSetString(values ...string) Option {
 // WithSet set values just like the command line.
 // (can specify multiple or separate values with commas: key1=val1,key2=val2)
 // This is equivalent to the "--set" option of the "helm template" command.
+// If specified more than once, the last one is used.
 func WithSet(values ...string) Option {
 	return func(o *option) {
 		o.values = values
@@ -69,8 +311,114 @@ func WithSet(values ...string) Option {
 // WithSetFile set values just like the command line.
 // (can specify multiple or separate values with commas: key1=val1,key2=val2)
 // This is equivalent to the "set-file" option of the "helm template" command.
+// If specified more than once, the last one is used.
 func WithSetFile(files ...string) Option {
 	return func(o *option) {
 		o.fileValues = files
 	}
 }
+
+// WithSetJSON set JSON values just like the command line.
+// (can specify multiple or separate values with commas: key1=jsonval1,key2=jsonval2)
+// This is equivalent to the "--set-json" option of the "helm template" command.
+func WithSetJSON(values ...string) Option {
+	return func(o *option) {
+		o.jsonValues = append(o.jsonValues, values...)
+	}
+}
+
+// WithSetLiteral set a literal STRING value just like the command line.
+// The value is not parsed, so it can contain commas and other special characters.
+// This is equivalent to the "--set-literal" option of the "helm template" command.
+func WithSetLiteral(values ...string) Option {
+	return func(o *option) {
+		o.literalValues = append(o.literalValues, values...)
+	}
+}
+
+// WithValuesMap specifies values as a map (can specify multiple).
+// The maps are merged in order after all the other value options, so they take precedence.
+func WithValuesMap(values ...map[string]interface{}) Option {
+	return func(o *option) {
+		o.valuesMaps = append(o.valuesMaps, values...)
+	}
+}
+
+// WithValuesTrace records the source of each leaf of the computed values,
+// such as the default values of the chart, a values file and the "--set" option.
+// The trace can be obtained by Manifests.ValuesTrace.
+func WithValuesTrace() Option {
+	return func(o *option) {
+		o.valuesTrace = true
+	}
+}
+
+// rendererOption stores the Renderer options.
+type rendererOption struct {
+	scheme *runtime.Scheme
+
+	// environment options
+	hostEnvironment  bool
+	helmHome         string
+	repositoryConfig string
+	registryConfig   string
+
+	coverage *TemplateCoverage
+}
+
+// RendererOption is an option to specify when calling New.
+// SchemeOption can also be used as a RendererOption.
+type RendererOption interface {
+	applyRendererOption(*rendererOption)
+}
+
+// rendererOptionFunc is a function that implements the RendererOption interface.
+type rendererOptionFunc func(*rendererOption)
+
+// applyRendererOption implements the RendererOption interface.
+func (f rendererOptionFunc) applyRendererOption(o *rendererOption) {
+	f(o)
+}
+
+// WithHostEnvironment uses the Helm environment of the host instead of the hermetic environment.
+// The "HELM_*" environment variables and the repository config, cache and plugins of the host will be used,
+// so the result may depend on the machine.
+func WithHostEnvironment() RendererOption {
+	return rendererOptionFunc(func(o *rendererOption) {
+		o.hostEnvironment = true
+	})
+}
+
+// WithHelmHome specifies the directory used as the config, cache and data directories of the hermetic environment.
+// For example, specify testing.T.TempDir() so that the directory is removed when the test finishes.
+// If not specified, a temporary directory is created and removed by Renderer.Close.
+func WithHelmHome(dir string) RendererOption {
+	return rendererOptionFunc(func(o *rendererOption) {
+		o.helmHome = dir
+	})
+}
+
+// WithRepositoryConfig specifies the path to the repositories file used in the hermetic environment.
+// This is equivalent to the "--repository-config" option of the "helm" command.
+func WithRepositoryConfig(path string) RendererOption {
+	return rendererOptionFunc(func(o *rendererOption) {
+		o.repositoryConfig = path
+	})
+}
+
+// WithRegistryConfig specifies the path to the registry config file used in the hermetic environment.
+// This is equivalent to the "--registry-config" option of the "helm" command.
+func WithRegistryConfig(path string) RendererOption {
+	return rendererOptionFunc(func(o *rendererOption) {
+		o.registryConfig = path
+	})
+}
+
+// WithTemplateCoverage records the blocks of the templates executed by every RenderTemplates of the Renderer
+// to the coverage. Share the coverage between the Renderers to measure the coverage of a test binary.
+// Each render is executed twice, because the templates are instrumented and rendered again for the coverage.
+func WithTemplateCoverage(coverage *TemplateCoverage) RendererOption {
+	return rendererOptionFunc(func(o *rendererOption) {
+		o.coverage = coverage
+	})
+}
@@ -0,0 +1,80 @@
+package helmut
+
+import (
+	"bytes"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// PostRenderFunc is a function that modifies the rendered manifests in place.
+// It can store, replace and delete objects of the manifests.
+type PostRenderFunc func(manifests *Manifests) error
+
+// postRendererFactory creates a post-renderer when rendering,
+// so that the errors of the options are reported by RenderTemplates.
+type postRendererFactory func(r *Renderer) (postrender.PostRenderer, error)
+
+// newPostRenderer creates and returns a post-renderer that runs the post-renderers of the options in order.
+// It returns nil if no post-renderer is specified.
+func (r *Renderer) newPostRenderer(opts *option) (postrender.PostRenderer, error) {
+	if len(opts.postRenderers) == 0 {
+		return nil, nil //nolint:nilnil // No post-renderer is a valid result.
+	}
+
+	chain := make(postRendererChain, 0, len(opts.postRenderers))
+
+	for _, factory := range opts.postRenderers {
+		postRenderer, err := factory(r)
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, postRenderer)
+	}
+
+	return chain, nil
+}
+
+// postRendererChain is a post-renderer that runs the post-renderers in order.
+type postRendererChain []postrender.PostRenderer
+
+// Run implements the postrender.PostRenderer interface.
+func (c postRendererChain) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	for _, postRenderer := range c {
+		modified, err := postRenderer.Run(renderedManifests)
+		if err != nil {
+			return nil, err
+		}
+
+		renderedManifests = modified
+	}
+
+	return renderedManifests, nil
+}
+
+// manifestsPostRenderer is a post-renderer that runs PostRenderFunc over the manifests split by the Renderer.
+type manifestsPostRenderer struct {
+	renderer *Renderer
+	fn       PostRenderFunc
+}
+
+// Run implements the postrender.PostRenderer interface.
+// The objects are written in the order of their keys.
+func (p *manifestsPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	manifests, err := p.renderer.SplitManifests(renderedManifests.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.fn(manifests); err != nil {
+		return nil, fmt.Errorf("failed to run post-render function: %w", err)
+	}
+
+	data, err := marshalManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(data), nil
+}
@@ -0,0 +1,200 @@
+package helmut_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// labelPostRenderer replaces the managed-by label of all objects.
+type labelPostRenderer struct{}
+
+func (labelPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(strings.ReplaceAll(renderedManifests.String(),
+		"app.kubernetes.io/managed-by: Helm", "app.kubernetes.io/managed-by: pipeline")), nil
+}
+
+func TestRenderTemplatesWithPostRenderer(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartPath   = "testdata/test-chart"
+	)
+
+	var (
+		deploymentKey = helmut.NewObjectKey("", releaseName+"-test-chart",
+			schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+		serviceAccountKey = helmut.NewObjectKey("", releaseName+"-test-chart",
+			schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"})
+		injectedKey = helmut.NewObjectKey("", "injected",
+			schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	)
+
+	injectSidecar := func(manifests *helmut.Manifests) error {
+		object, ok := manifests.Load(deploymentKey)
+		if !ok {
+			return errors.New("deployment not found")
+		}
+
+		deploy, _ := object.(*appsv1.Deployment)
+		deploy.Spec.Template.Spec.Containers = append(deploy.Spec.Template.Spec.Containers,
+			corev1.Container{Name: "sidecar", Image: "envoy:v1"})
+
+		manifests.Delete(serviceAccountKey)
+
+		return nil
+	}
+
+	script := writeScript(t, "#!/bin/sh\ncat\nprintf -- '---\\napiVersion: v1\\nkind: ConfigMap\\nmetadata:\\n  name: %s\\n' \"$1\"\n")
+
+	tests := []struct {
+		name    string
+		options []helmut.Option
+		check   func(t *testing.T, manifests *helmut.Manifests)
+		wantErr string
+	}{
+		{
+			name:    "post-renderer",
+			options: []helmut.Option{helmut.WithPostRenderer(labelPostRenderer{})},
+			check: func(t *testing.T, manifests *helmut.Manifests) {
+				t.Helper()
+
+				deploy := loadDeployment(t, manifests, deploymentKey)
+				if got := deploy.Labels["app.kubernetes.io/managed-by"]; got != "pipeline" {
+					t.Errorf("got managed-by label %q, want %q", got, "pipeline")
+				}
+			},
+		},
+		{
+			name:    "executable",
+			options: []helmut.Option{helmut.WithPostRendererExec(script, "injected")},
+			check: func(t *testing.T, manifests *helmut.Manifests) {
+				t.Helper()
+
+				if _, ok := manifests.Load(injectedKey); !ok {
+					t.Errorf("%s not found", injectedKey)
+				}
+			},
+		},
+		{
+			name:    "function",
+			options: []helmut.Option{helmut.WithPostRendererFunc(injectSidecar)},
+			check: func(t *testing.T, manifests *helmut.Manifests) {
+				t.Helper()
+
+				deploy := loadDeployment(t, manifests, deploymentKey)
+				if got := len(deploy.Spec.Template.Spec.Containers); got != 2 {
+					t.Errorf("got %d containers, want 2", got)
+				}
+
+				if _, ok := manifests.Load(serviceAccountKey); ok {
+					t.Errorf("%s is not deleted", serviceAccountKey)
+				}
+			},
+		},
+		{
+			name: "chain in order",
+			options: []helmut.Option{
+				helmut.WithPostRendererFunc(injectSidecar),
+				helmut.WithPostRendererExec(script, "injected"),
+				helmut.WithPostRenderer(labelPostRenderer{}),
+			},
+			check: func(t *testing.T, manifests *helmut.Manifests) {
+				t.Helper()
+
+				deploy := loadDeployment(t, manifests, deploymentKey)
+				if got := len(deploy.Spec.Template.Spec.Containers); got != 2 {
+					t.Errorf("got %d containers, want 2", got)
+				}
+
+				if got := deploy.Labels["app.kubernetes.io/managed-by"]; got != "pipeline" {
+					t.Errorf("got managed-by label %q, want %q", got, "pipeline")
+				}
+
+				if _, ok := manifests.Load(injectedKey); !ok {
+					t.Errorf("%s not found", injectedKey)
+				}
+			},
+		},
+		{
+			name:    "executable not found",
+			options: []helmut.Option{helmut.WithPostRendererExec(filepath.Join(t.TempDir(), "not-found"))},
+			wantErr: "failed to create post-renderer",
+		},
+		{
+			name: "function error",
+			options: []helmut.Option{helmut.WithPostRendererFunc(func(*helmut.Manifests) error {
+				return errors.New("boom")
+			})},
+			wantErr: "failed to run post-render function: boom",
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, chartPath, tt.options...)
+
+			if len(tt.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			tt.check(t, manifests)
+		})
+	}
+}
+
+func loadDeployment(t *testing.T, manifests *helmut.Manifests, key helmut.ObjectKey) *appsv1.Deployment {
+	t.Helper()
+
+	object, ok := manifests.Load(key)
+	if !ok {
+		t.Fatalf("%s not found", key)
+	}
+
+	deploy, ok := object.(*appsv1.Deployment)
+	if !ok {
+		t.Fatalf("got %T, want *appsv1.Deployment", object)
+	}
+
+	return deploy
+}
+
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "post-render.sh")
+
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil { //nolint:gosec // The script must be executable.
+		t.Fatalf("failed to write script: %s", err)
+	}
+
+	return path
+}
@@ -0,0 +1,128 @@
+package helmut
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"helm.sh/helm/v3/pkg/registry"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// newRegistryClient creates and returns a registry client used to pull the chart from the OCI registry.
+//
+// In the hermetic environment, the credentials are read only from the registry config of the settings,
+// because the registry client of helm falls back to the docker config and the credential helpers of the host.
+func (r *Renderer) newRegistryClient(opts *option) (*registry.Client, error) {
+	if err := r.ensureHome(); err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newRegistryHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []registry.ClientOption{
+		registry.ClientOptEnableCache(true),
+		registry.ClientOptWriter(io.Discard),
+		registry.ClientOptCredentialsFile(r.settings.Load().RegistryConfig),
+		registry.ClientOptHTTPClient(httpClient),
+		registry.ClientOptBasicAuth(opts.username, opts.password),
+	}
+
+	if opts.plainHTTP {
+		options = append(options, registry.ClientOptPlainHTTP())
+	}
+
+	if !r.opts.hostEnvironment {
+		authorizer, err := newRegistryAuthorizer(httpClient, r.settings.Load().RegistryConfig, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, registry.ClientOptAuthorizer(*authorizer))
+	}
+
+	client, err := registry.NewClient(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	return client, nil
+}
+
+// newRegistryHTTPClient creates and returns an HTTP client with the TLS options.
+func newRegistryHTTPClient(opts *option) (*http.Client, error) {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("http.DefaultTransport is not *http.Transport")
+	}
+
+	transport = transport.Clone()
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.insecureSkipTLSVerify, //nolint:gosec
+	}
+
+	if len(opts.certFile) != 0 && len(opts.keyFile) != 0 {
+		cert, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(opts.caFile) != 0 {
+		ca, err := os.ReadFile(opts.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to append certificates from %s", opts.caFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	transport.TLSClientConfig = config
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newRegistryAuthorizer creates and returns an authorizer that uses the basic auth of the options if specified,
+// and otherwise the credentials stored in the registry config.
+func newRegistryAuthorizer(httpClient *http.Client, registryConfig string, opts *option) (*auth.Client, error) {
+	authorizer := &auth.Client{
+		Client: httpClient,
+		Cache:  auth.NewCache(),
+	}
+
+	if len(opts.username) != 0 && len(opts.password) != 0 {
+		credential := auth.Credential{Username: opts.username, Password: opts.password}
+		authorizer.Credential = func(_ context.Context, _ string) (auth.Credential, error) {
+			return credential, nil
+		}
+
+		return authorizer, nil
+	}
+
+	store, err := credentials.NewStore(registryConfig, credentials.StoreOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry config: %w", err)
+	}
+
+	authorizer.Credential = credentials.Credential(store)
+
+	return authorizer, nil
+}
@@ -0,0 +1,160 @@
+package helmut_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"github.com/d-kuro/helmut/registrytest"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderTemplatesFromRegistry(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	srv := registrytest.NewServer(registrytest.WithBasicAuth("user", "secret"))
+	t.Cleanup(srv.Close)
+
+	var ref string
+
+	for version, message := range map[string]string{"1.2.0": "hello", "1.3.0": "hello from 1.3.0"} {
+		archive := saveSubChart(t, t.TempDir(), version, message)
+
+		pushed, err := srv.PushChart("charts", archive)
+		if err != nil {
+			t.Fatalf("failed to push chart: %s", err)
+		}
+
+		ref = pushed
+	}
+
+	registryConfig := filepath.Join(t.TempDir(), "registry.json")
+
+	if err := srv.WriteRegistryConfig(registryConfig); err != nil {
+		t.Fatalf("failed to write registry config: %s", err)
+	}
+
+	auth := helmut.WithBasicAuth("user", "secret")
+
+	tests := []struct {
+		name            string
+		chart           string
+		rendererOptions []helmut.RendererOption
+		options         []helmut.Option
+		wantMessage     string
+		wantErr         string
+	}{
+		{
+			name:        "latest version",
+			chart:       ref,
+			options:     []helmut.Option{helmut.WithPlainHTTP(), auth},
+			wantMessage: "hello from 1.3.0",
+		},
+		{
+			name:        "specific version",
+			chart:       ref,
+			options:     []helmut.Option{helmut.WithPlainHTTP(), auth, helmut.WithVersion("1.2.0")},
+			wantMessage: "hello",
+		},
+		{
+			name:        "version constraint",
+			chart:       ref,
+			options:     []helmut.Option{helmut.WithPlainHTTP(), auth, helmut.WithVersion("~1.2")},
+			wantMessage: "hello",
+		},
+		{
+			name:        "tag in reference",
+			chart:       ref + ":1.2.0",
+			options:     []helmut.Option{helmut.WithPlainHTTP(), auth},
+			wantMessage: "hello",
+		},
+		{
+			name:            "credentials in registry config",
+			chart:           ref,
+			rendererOptions: []helmut.RendererOption{helmut.WithRegistryConfig(registryConfig)},
+			options:         []helmut.Option{helmut.WithPlainHTTP(), helmut.WithVersion("1.2.0")},
+			wantMessage:     "hello",
+		},
+		{
+			name:    "tag and version mismatch",
+			chart:   ref + ":1.2.0",
+			options: []helmut.Option{helmut.WithPlainHTTP(), auth, helmut.WithVersion("1.3.0")},
+			wantErr: "chart reference and version mismatch",
+		},
+		{
+			name:    "version not found",
+			chart:   ref,
+			options: []helmut.Option{helmut.WithPlainHTTP(), auth, helmut.WithVersion("2.0.0")},
+			wantErr: "2.0.0: not found",
+		},
+		{
+			name:    "unauthorized",
+			chart:   ref,
+			options: []helmut.Option{helmut.WithPlainHTTP(), helmut.WithVersion("1.2.0")},
+			wantErr: "basic credential not found",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := newRenderer(t, tt.rendererOptions...)
+
+			manifests, err := r.RenderTemplates(releaseName, tt.chart, tt.options...)
+
+			if len(tt.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			assert.Contains(t, manifests, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-sub-chart"},
+				Data:       map[string]string{"message": tt.wantMessage},
+			})
+		})
+	}
+}
+
+// saveSubChart saves the sub-chart with the version and the message as an archive in dir and returns its path.
+func saveSubChart(t *testing.T, dir, version, message string) string {
+	t.Helper()
+
+	c, err := loader.Load(filepath.Join("testdata", "sub-chart"))
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	c.Metadata.Version = version
+
+	// The values file is saved from the raw data.
+	for _, f := range c.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			f.Data = []byte(fmt.Sprintf("message: %s\n", message))
+		}
+	}
+
+	archive, err := chartutil.Save(c, dir)
+	if err != nil {
+		t.Fatalf("failed to save chart: %s", err)
+	}
+
+	return archive
+}
@@ -0,0 +1,571 @@
+// Package registrytest provides an in-process OCI registry for testing.
+// It implements the endpoints of the distribution API used to push and pull Helm charts,
+// and allows rendering "oci://" chart references without network access.
+package registrytest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// Server is an OCI registry server that stores the blobs and the manifests in memory.
+// It serves plain HTTP, so specify helmut.WithPlainHTTP when rendering the charts of the server.
+type Server struct {
+	*httptest.Server
+
+	username string
+	password string
+
+	blobs     map[digest.Digest][]byte
+	manifests map[string]*repository
+	uploads   map[string]*bytes.Buffer
+
+	mu sync.Mutex
+}
+
+// repository is a repository of the registry.
+type repository struct {
+	manifests map[digest.Digest]*manifest
+	tags      map[string]digest.Digest
+}
+
+// manifest is a manifest stored in the registry.
+type manifest struct {
+	mediaType string
+	data      []byte
+}
+
+// option stores the server options.
+type option struct {
+	username string
+	password string
+}
+
+// Option is an option to specify when calling NewServer.
+type Option func(*option)
+
+// WithBasicAuth requires the basic authentication with the username and password for all requests.
+func WithBasicAuth(username, password string) Option {
+	return func(o *option) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer(options ...Option) *Server {
+	opts := &option{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	s := &Server{
+		username:  opts.username,
+		password:  opts.password,
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string]*repository),
+		uploads:   make(map[string]*bytes.Buffer),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Host returns the host and port of the server, which is used in the "oci://" chart references.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// PushChart pushes the chart directory or archive to the repository like the "helm push" command,
+// and returns the "oci://" reference of the chart without the tag.
+// The chart is stored as "<repository>/<chart name>" tagged with the chart version.
+//
+// e.g. PushChart("charts", "testdata/test-chart") returns "oci://127.0.0.1:12345/charts/test-chart".
+func (s *Server) PushChart(repository, chartPath string) (string, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the chart: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "registrytest-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	archive, err := chartutil.Save(c, tempDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to package the chart: %w", err)
+	}
+
+	chartData, err := os.ReadFile(archive)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the chart archive: %w", err)
+	}
+
+	configData, err := json.Marshal(c.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the chart metadata: %w", err)
+	}
+
+	m := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    s.storeBlob(registry.ConfigMediaType, configData),
+		Layers:    []ocispec.Descriptor{s.storeBlob(registry.ChartLayerMediaType, chartData)},
+		Annotations: map[string]string{
+			ocispec.AnnotationTitle:   c.Name(),
+			ocispec.AnnotationVersion: c.Metadata.Version,
+		},
+	}
+
+	manifestData, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the manifest: %w", err)
+	}
+
+	name := strings.Trim(repository, "/") + "/" + c.Name()
+	// OCI tags cannot contain "+", so helm replaces it with "_".
+	tag := strings.ReplaceAll(c.Metadata.Version, "+", "_")
+
+	s.storeManifest(name, tag, ocispec.MediaTypeImageManifest, manifestData)
+
+	return fmt.Sprintf("%s://%s/%s", registry.OCIScheme, s.Host(), name), nil
+}
+
+// WriteRegistryConfig writes a registry config file to path,
+// in which the credentials of the server are stored like the "helm registry login" command.
+// Specify the file with helmut.WithRegistryConfig to render charts without helmut.WithBasicAuth.
+func (s *Server) WriteRegistryConfig(path string) error {
+	auth := base64.StdEncoding.EncodeToString([]byte(s.username + ":" + s.password))
+
+	config := map[string]interface{}{
+		"auths": map[string]interface{}{
+			s.Host(): map[string]string{"auth": auth},
+		},
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry config: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write registry config: %w", err)
+	}
+
+	return nil
+}
+
+// storeBlob stores the blob and returns its descriptor.
+func (s *Server) storeBlob(mediaType string, data []byte) ocispec.Descriptor {
+	dgst := digest.FromBytes(data)
+
+	s.mu.Lock()
+	s.blobs[dgst] = data
+	s.mu.Unlock()
+
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(data)),
+	}
+}
+
+// storeManifest stores the manifest in the repository and tags it if the reference is a tag.
+func (s *Server) storeManifest(name, reference, mediaType string, data []byte) digest.Digest {
+	dgst := digest.FromBytes(data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, ok := s.manifests[name]
+	if !ok {
+		repo = &repository{
+			manifests: make(map[digest.Digest]*manifest),
+			tags:      make(map[string]digest.Digest),
+		}
+		s.manifests[name] = repo
+	}
+
+	repo.manifests[dgst] = &manifest{mediaType: mediaType, data: data}
+
+	if _, err := digest.Parse(reference); err != nil {
+		repo.tags[reference] = dgst
+	}
+
+	return dgst
+}
+
+// serveHTTP routes the requests of the distribution API.
+//
+//  GET /v2/
+//  GET, HEAD, PUT /v2/<name>/manifests/<reference>
+//  GET, HEAD /v2/<name>/blobs/<digest>
+//  POST /v2/<name>/blobs/uploads/
+//  PATCH, PUT /v2/<name>/blobs/uploads/<id>
+//  GET /v2/<name>/tags/list
+//
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="registrytest"`)
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+	switch {
+	case r.URL.Path == "/v2/" || r.URL.Path == "/v2":
+		w.WriteHeader(http.StatusOK)
+	case path == r.URL.Path:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+	case strings.HasSuffix(path, "/tags/list"):
+		s.serveTags(w, r, strings.TrimSuffix(path, "/tags/list"))
+	case strings.Contains(path, "/blobs/uploads"):
+		name, id := splitPath(path, "/blobs/uploads")
+		s.serveUpload(w, r, name, strings.TrimPrefix(id, "/"))
+	case strings.Contains(path, "/blobs/"):
+		// Blobs are shared by all repositories.
+		_, dgst := splitPath(path, "/blobs/")
+		s.serveBlob(w, r, dgst)
+	case strings.Contains(path, "/manifests/"):
+		name, reference := splitPath(path, "/manifests/")
+		s.serveManifest(w, r, name, reference)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+	}
+}
+
+// authorized returns true if the request has the credentials of the server or no credentials are required.
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.username) == 0 && len(s.password) == 0 {
+		return true
+	}
+
+	username, password, ok := r.BasicAuth()
+
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(s.username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+}
+
+// serveManifest serves and stores the manifests.
+func (s *Server) serveManifest(w http.ResponseWriter, r *http.Request, name, reference string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.mu.Lock()
+		m, dgst := s.lookupManifest(name, reference)
+		s.mu.Unlock()
+
+		if m == nil {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("manifest %s:%s is unknown", name, reference))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(m.data)))
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodGet {
+			w.Write(m.data) //nolint:errcheck // There is nothing to do if the response cannot be written.
+		}
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+
+			return
+		}
+
+		if err := s.validateManifest(data); err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+
+			return
+		}
+
+		if dgst, err := digest.Parse(reference); err == nil && dgst != digest.FromBytes(data) {
+			writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "digest does not match the content")
+
+			return
+		}
+
+		dgst := s.storeManifest(name, reference, r.Header.Get("Content-Type"), data)
+
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, dgst))
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+// lookupManifest returns the manifest of the reference, which is a tag or a digest.
+// The caller must hold the lock.
+func (s *Server) lookupManifest(name, reference string) (*manifest, digest.Digest) {
+	repo, ok := s.manifests[name]
+	if !ok {
+		return nil, ""
+	}
+
+	dgst, err := digest.Parse(reference)
+	if err != nil {
+		dgst = repo.tags[reference]
+	}
+
+	return repo.manifests[dgst], dgst
+}
+
+// validateManifest returns an error if the manifest refers to blobs that do not exist.
+func (s *Server) validateManifest(data []byte) error {
+	m := &ocispec.Manifest{}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, desc := range append([]ocispec.Descriptor{m.Config}, m.Layers...) {
+		if _, ok := s.blobs[desc.Digest]; !ok {
+			return fmt.Errorf("blob %s is unknown", desc.Digest)
+		}
+	}
+
+	return nil
+}
+
+// serveBlob serves the blobs.
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, reference string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", http.StatusText(http.StatusMethodNotAllowed))
+
+		return
+	}
+
+	dgst, err := digest.Parse(reference)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+
+		return
+	}
+
+	s.mu.Lock()
+	data, ok := s.blobs[dgst]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("blob %s is unknown", dgst))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		w.Write(data) //nolint:errcheck // There is nothing to do if the response cannot be written.
+	}
+}
+
+// serveUpload serves the monolithic and chunked blob uploads.
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request, name, id string) {
+	switch {
+	case r.Method == http.MethodPost && len(id) == 0:
+		// Monolithic upload in a single request.
+		if len(r.URL.Query().Get("digest")) != 0 {
+			s.completeUpload(w, r, name, &bytes.Buffer{})
+
+			return
+		}
+
+		id, err := newUploadID()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+
+			return
+		}
+
+		s.mu.Lock()
+		s.uploads[id] = &bytes.Buffer{}
+		s.mu.Unlock()
+
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPatch || r.Method == http.MethodPut:
+		s.mu.Lock()
+		buf, ok := s.uploads[id]
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", fmt.Sprintf("upload %s is unknown", id))
+
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			s.completeUpload(w, r, name, buf)
+
+			s.mu.Lock()
+			delete(s.uploads, id)
+			s.mu.Unlock()
+
+			return
+		}
+
+		if _, err := io.Copy(buf, r.Body); err != nil {
+			writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", buf.Len()-1))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", http.StatusText(http.StatusMethodNotAllowed))
+	}
+}
+
+// completeUpload appends the request body to the uploaded content and stores it as a blob
+// if the digest of the query matches the content.
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, name string, buf *bytes.Buffer) {
+	expected, err := digest.Parse(r.URL.Query().Get("digest"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+
+		return
+	}
+
+	if _, err := io.Copy(buf, r.Body); err != nil {
+		writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+
+		return
+	}
+
+	if dgst := digest.FromBytes(buf.Bytes()); dgst != expected {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("digest %s does not match the content", expected))
+
+		return
+	}
+
+	s.storeBlob("", buf.Bytes())
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, expected))
+	w.Header().Set("Docker-Content-Digest", expected.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveTags serves the sorted tags of the repository.
+// Pagination by the "n" and "last" query parameters is supported.
+func (s *Server) serveTags(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", http.StatusText(http.StatusMethodNotAllowed))
+
+		return
+	}
+
+	s.mu.Lock()
+	repo, ok := s.manifests[name]
+
+	tags := []string{}
+
+	if ok {
+		for tag := range repo.tags {
+			tags = append(tags, tag)
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", fmt.Sprintf("repository %s is unknown", name))
+
+		return
+	}
+
+	sort.Strings(tags)
+
+	query := r.URL.Query()
+
+	if last := query.Get("last"); len(last) != 0 {
+		tags = tags[sort.SearchStrings(tags, last):]
+		if len(tags) != 0 && tags[0] == last {
+			tags = tags[1:]
+		}
+	}
+
+	if n, err := strconv.Atoi(query.Get("n")); err == nil && n >= 0 && n < len(tags) {
+		tags = tags[:n]
+
+		next := url.Values{"n": {strconv.Itoa(n)}, "last": {tags[len(tags)-1]}}
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?%s>; rel="next"`, name, next.Encode()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	//nolint:errcheck // There is nothing to do if the response cannot be written.
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": name,
+		"tags": tags,
+	})
+}
+
+// splitPath splits the path into the repository name and the rest after the separator.
+func splitPath(path, sep string) (string, string) {
+	i := strings.LastIndex(path, sep)
+
+	return path[:i], path[i+len(sep):]
+}
+
+// newUploadID returns a random ID of an upload session.
+func newUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// writeError writes the error response of the distribution API.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	//nolint:errcheck // There is nothing to do if the response cannot be written.
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
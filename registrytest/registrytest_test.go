@@ -0,0 +1,180 @@
+package registrytest_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut/registrytest"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	srv := registrytest.NewServer(registrytest.WithBasicAuth("user", "secret"))
+	t.Cleanup(srv.Close)
+
+	ref, err := srv.PushChart("charts", filepath.Join("..", "testdata", "test-chart"))
+	if err != nil {
+		t.Fatalf("failed to push chart: %s", err)
+	}
+
+	if want := "oci://" + srv.Host() + "/charts/test-chart"; ref != want {
+		t.Errorf("got reference %s, want %s", ref, want)
+	}
+
+	client := newClient(t, "user", "secret")
+
+	// Push another version with the upload endpoints.
+	c, err := loader.Load(filepath.Join("..", "testdata", "test-chart"))
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	c.Metadata.Version = "0.2.0+build.1"
+
+	archive, err := chartutil.Save(c, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to save chart: %s", err)
+	}
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("failed to read chart: %s", err)
+	}
+
+	base := strings.TrimPrefix(ref, "oci://")
+
+	if _, err := client.Push(data, base+":0.2.0_build.1", registry.PushOptStrictMode(false)); err != nil {
+		t.Fatalf("failed to push chart: %s", err)
+	}
+
+	tags, err := client.Tags(base)
+	if err != nil {
+		t.Fatalf("failed to list tags: %s", err)
+	}
+
+	if got, want := strings.Join(tags, ","), "0.2.0+build.1,0.1.0"; got != want {
+		t.Errorf("got tags %s, want %s", got, want)
+	}
+
+	for _, tag := range []string{"0.1.0", "0.2.0_build.1"} {
+		result, err := client.Pull(base + ":" + tag)
+		if err != nil {
+			t.Fatalf("failed to pull chart: %s", err)
+		}
+
+		if got, want := result.Chart.Meta.Name, "test-chart"; got != want {
+			t.Errorf("got chart name %s, want %s", got, want)
+		}
+
+		if len(result.Chart.Data) == 0 {
+			t.Error("got empty chart data")
+		}
+	}
+
+	if _, err := client.Pull(base + ":9.9.9"); err == nil {
+		t.Error("got no error for unknown tag")
+	}
+
+	if _, err := newClient(t, "user", "wrong").Pull(base + ":0.1.0"); err == nil {
+		t.Error("got no error for wrong credentials")
+	}
+}
+
+func TestServerRejectsInvalidRequests(t *testing.T) {
+	t.Parallel()
+
+	srv := registrytest.NewServer()
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{
+			name:   "API version check",
+			method: http.MethodGet,
+			path:   "/v2/",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown repository",
+			method: http.MethodGet,
+			path:   "/v2/charts/unknown/tags/list",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid digest",
+			method: http.MethodGet,
+			path:   "/v2/charts/test-chart/blobs/invalid",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "digest mismatch",
+			method: http.MethodPost,
+			path:   "/v2/charts/test-chart/blobs/uploads/?digest=sha256:" + strings.Repeat("0", 64),
+			body:   "data",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "manifest with unknown blobs",
+			method: http.MethodPut,
+			path:   "/v2/charts/test-chart/manifests/0.1.0",
+			body:   `{"schemaVersion":2,"config":{"digest":"sha256:` + strings.Repeat("0", 64) + `"}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown upload",
+			method: http.MethodPatch,
+			path:   "/v2/charts/test-chart/blobs/uploads/unknown",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body)) //nolint:noctx
+			if err != nil {
+				t.Fatalf("failed to create request: %s", err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func newClient(t *testing.T, username, password string) *registry.Client {
+	t.Helper()
+
+	client, err := registry.NewClient(
+		registry.ClientOptPlainHTTP(),
+		registry.ClientOptBasicAuth(username, password),
+		registry.ClientOptCredentialsFile(filepath.Join(t.TempDir(), "registry.json")),
+	)
+	if err != nil {
+		t.Fatalf("failed to create registry client: %s", err)
+	}
+
+	return client
+}
@@ -0,0 +1,157 @@
+package helmut
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// locateChart looks for the chart and returns the path to the local chart directory or archive.
+// Remote charts are downloaded to the repository cache.
+//
+// In the hermetic environment, the repository indexes are downloaded to the hermetic cache by helmut,
+// because helm downloads the index for a repository URL to the cache of the host,
+// and expects that the indexes of the named repositories have already been downloaded by "helm repo update".
+func (r *Renderer) locateChart(options action.ChartPathOptions, name string) (string, error) {
+	if r.opts.hostEnvironment || isLocalChart(name) {
+		return options.LocateChart(name, r.settings.Load())
+	}
+
+	if err := r.ensureHome(); err != nil {
+		return "", err
+	}
+
+	if err := r.updateRepositories(); err != nil {
+		return "", err
+	}
+
+	if len(options.RepoURL) != 0 {
+		chartURL, err := r.findChartInRepoURL(options, name)
+		if err != nil {
+			return "", err
+		}
+
+		// The chart URL has already been resolved, so prevent helm from resolving it again.
+		options.RepoURL = ""
+		name = chartURL
+	}
+
+	return options.LocateChart(name, r.settings.Load())
+}
+
+// isLocalChart returns true if the chart refers to a local path.
+func isLocalChart(name string) bool {
+	if _, err := os.Stat(name); err == nil {
+		return true
+	}
+
+	return filepath.IsAbs(name) || strings.HasPrefix(name, ".")
+}
+
+// findChartInRepoURL downloads the index of the repository to the hermetic cache
+// and returns the URL of the chart.
+// This is equivalent to repo.FindChartInAuthAndTLSAndPassRepoURL.
+func (r *Renderer) findChartInRepoURL(options action.ChartPathOptions, name string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate repository name: %w", err)
+	}
+
+	entry := &repo.Entry{
+		Name:                  "helmut-" + hex.EncodeToString(buf),
+		URL:                   options.RepoURL,
+		Username:              options.Username,
+		Password:              options.Password,
+		CertFile:              options.CertFile,
+		KeyFile:               options.KeyFile,
+		CAFile:                options.CaFile,
+		InsecureSkipTLSverify: options.InsecureSkipTLSverify,
+		PassCredentialsAll:    options.PassCredentialsAll,
+	}
+
+	chartRepo, err := repo.NewChartRepository(entry, r.providers)
+	if err != nil {
+		return "", fmt.Errorf("failed to create chart repository: %w", err)
+	}
+
+	chartRepo.CachePath = r.settings.Load().RepositoryCache
+
+	index, err := chartRepo.DownloadIndexFile()
+	if err != nil {
+		return "", fmt.Errorf("looks like %q is not a valid chart repository or cannot be reached: %w", options.RepoURL, err)
+	}
+
+	defer func() {
+		os.Remove(index)
+		os.Remove(filepath.Join(chartRepo.CachePath, helmpath.CacheChartsFile(entry.Name)))
+	}()
+
+	indexFile, err := repo.LoadIndexFile(index)
+	if err != nil {
+		return "", fmt.Errorf("failed to load index of %s: %w", options.RepoURL, err)
+	}
+
+	version, err := indexFile.Get(name, options.Version)
+	if err != nil {
+		return "", fmt.Errorf("chart %q version %q not found in %s repository: %w", name, options.Version, options.RepoURL, err)
+	}
+
+	if len(version.URLs) == 0 {
+		return "", fmt.Errorf("chart %q version %q has no downloadable URLs", name, version.Version)
+	}
+
+	chartURL, err := repo.ResolveReferenceURL(options.RepoURL, version.URLs[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to make chart URL absolute: %w", err)
+	}
+
+	return chartURL, nil
+}
+
+// updateRepositories downloads the indexes of the repositories in the repository config to the hermetic cache,
+// like the "helm repo update" command.
+// Helm requires the indexes of all the repositories to resolve a chart, even if the chart is specified by URL.
+// The indexes are downloaded only once per Renderer.
+func (r *Renderer) updateRepositories() error {
+	r.repoMu.Lock()
+	defer r.repoMu.Unlock()
+
+	if r.reposUpdated {
+		return nil
+	}
+
+	file, err := repo.LoadFile(r.settings.Load().RepositoryConfig)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.reposUpdated = true
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load repository config: %w", err)
+	}
+
+	for _, entry := range file.Repositories {
+		chartRepo, err := repo.NewChartRepository(entry, r.providers)
+		if err != nil {
+			return fmt.Errorf("failed to create chart repository: %w", err)
+		}
+
+		chartRepo.CachePath = r.settings.Load().RepositoryCache
+
+		if _, err := chartRepo.DownloadIndexFile(); err != nil {
+			return fmt.Errorf("failed to download index of %q repository: %w", entry.Name, err)
+		}
+	}
+
+	r.reposUpdated = true
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package helmut_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"github.com/d-kuro/helmut/repotest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderTemplatesFromRepository(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	dir := t.TempDir()
+
+	for version, message := range map[string]string{"1.2.0": "hello", "1.3.0": "hello from 1.3.0"} {
+		saveSubChart(t, dir, version, message)
+	}
+
+	srv := repotest.NewServer(dir)
+	t.Cleanup(srv.Close)
+
+	repositoryConfig := filepath.Join(t.TempDir(), "repositories.yaml")
+
+	if err := srv.WriteRepositoryConfig("example", repositoryConfig); err != nil {
+		t.Fatalf("failed to write repository config: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		chart       string
+		options     []helmut.Option
+		wantMessage string
+		wantErr     string
+	}{
+		{
+			name:        "latest version by repository URL",
+			chart:       "sub-chart",
+			options:     []helmut.Option{helmut.WithRepoURL(srv.URL)},
+			wantMessage: "hello from 1.3.0",
+		},
+		{
+			name:        "specific version by repository URL",
+			chart:       "sub-chart",
+			options:     []helmut.Option{helmut.WithRepoURL(srv.URL), helmut.WithVersion("1.2.0")},
+			wantMessage: "hello",
+		},
+		{
+			name:        "version constraint by repository name",
+			chart:       "example/sub-chart",
+			options:     []helmut.Option{helmut.WithVersion("~1.2")},
+			wantMessage: "hello",
+		},
+		{
+			name:    "version not found",
+			chart:   "sub-chart",
+			options: []helmut.Option{helmut.WithRepoURL(srv.URL), helmut.WithVersion("2.0.0")},
+			wantErr: `chart "sub-chart" version "2.0.0" not found`,
+		},
+	}
+
+	r := newRenderer(t, helmut.WithRepositoryConfig(repositoryConfig))
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, tt.chart, tt.options...)
+
+			if len(tt.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			assert.Contains(t, manifests, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-sub-chart"},
+				Data:       map[string]string{"message": tt.wantMessage},
+			})
+		})
+	}
+}
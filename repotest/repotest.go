@@ -0,0 +1,89 @@
+// Package repotest provides a Helm chart repository server for testing.
+// It allows rendering charts by repository URL or by "repo/name" without network access.
+package repotest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+// Server is a Helm chart repository server that serves a directory of packaged charts.
+// The index.yaml is generated from the chart archives in the directory on each request,
+// so charts added to the directory after the server starts are also served.
+type Server struct {
+	*httptest.Server
+
+	dir string
+}
+
+// NewServer starts and returns a new Server serving the chart archives in dir.
+// The caller should call Close when finished, to shut it down.
+func NewServer(dir string) *Server {
+	s := &Server{dir: dir}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// serveHTTP serves the index.yaml and the chart archives.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/index.yaml":
+		s.serveIndex(w)
+	case strings.HasSuffix(r.URL.Path, ".tgz"):
+		http.ServeFile(w, r, filepath.Join(s.dir, filepath.FromSlash(filepath.Clean("/"+r.URL.Path))))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveIndex generates and serves the index.yaml.
+func (s *Server) serveIndex(w http.ResponseWriter) {
+	index, err := repo.IndexDirectory(s.dir, s.URL)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to generate index: %s", err), http.StatusInternalServerError)
+
+		return
+	}
+
+	index.SortEntries()
+
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal index: %s", err), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(data) //nolint:errcheck // There is nothing to do if the response cannot be written.
+}
+
+// WriteRepositoryConfig writes a repositories file to path,
+// in which the server is registered with the name.
+// Specify the file with helmut.WithRepositoryConfig to render charts by "name/chart".
+func (s *Server) WriteRepositoryConfig(name, path string) error {
+	file := repo.NewFile()
+	file.Add(&repo.Entry{
+		Name: name,
+		URL:  s.URL,
+	})
+
+	if err := file.WriteFile(path, 0o600); err != nil {
+		return fmt.Errorf("failed to write repository config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package repotest_test
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/helmut/repotest"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	c, err := loader.Load(filepath.Join("..", "testdata", "test-chart"))
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	if _, err := chartutil.Save(c, dir); err != nil {
+		t.Fatalf("failed to save chart: %s", err)
+	}
+
+	srv := repotest.NewServer(dir)
+	t.Cleanup(srv.Close)
+
+	index := &repo.IndexFile{}
+
+	if err := yaml.Unmarshal(get(t, srv.URL+"/index.yaml", http.StatusOK), index); err != nil {
+		t.Fatalf("failed to unmarshal index: %s", err)
+	}
+
+	version, err := index.Get("test-chart", "0.1.0")
+	if err != nil {
+		t.Fatalf("chart not found in index: %s", err)
+	}
+
+	if got, want := version.URLs[0], srv.URL+"/test-chart-0.1.0.tgz"; got != want {
+		t.Errorf("got URL %s, want %s", got, want)
+	}
+
+	get(t, version.URLs[0], http.StatusOK)
+	get(t, srv.URL+"/not-found-0.1.0.tgz", http.StatusNotFound)
+	get(t, srv.URL+"/../repotest.go", http.StatusNotFound)
+}
+
+func get(t *testing.T, url string, status int) []byte {
+	t.Helper()
+
+	resp, err := http.Get(url) //nolint:gosec,noctx // The URL is of the test server.
+	if err != nil {
+		t.Fatalf("failed to get %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, status, url)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %s", err)
+	}
+
+	return data
+}
@@ -0,0 +1,340 @@
+package helmut
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	// schemaURL is the URL of the values schema used to resolve relative references.
+	schemaURL = "file:///values.schema.json"
+
+	// schemaHTTPTimeout is the timeout to load the remote references of the values schema, same as helm.
+	schemaHTTPTimeout = 15 * time.Second
+)
+
+// SchemaViolation is a violation of the values.schema.json of a chart.
+type SchemaViolation struct {
+	// Chart is the name of the chart whose schema is violated.
+	Chart string
+
+	// Pointer is the JSON pointer to the offending value in the values of the release.
+	// The values of a subchart are prefixed with the name of the subchart.
+	// e.g. "/replicaCount", "/test-chart/image/tag"
+	Pointer string
+
+	// Rule is the keyword of the schema that is violated, such as "type", "minimum" and "required".
+	Rule string
+
+	// Message describes the violation.
+	// e.g. "got string, want integer"
+	Message string
+
+	// Value is the offending value.
+	// It is the object that has the missing properties for "required".
+	Value interface{}
+}
+
+// String returns the violation in a single line.
+func (v SchemaViolation) String() string {
+	value, err := json.Marshal(v.Value)
+	if err != nil {
+		value = []byte(fmt.Sprint(v.Value))
+	}
+
+	return fmt.Sprintf("%s: %s: %s: %s (value: %s)", v.Chart, pointerOrRoot(v.Pointer), v.Rule, v.Message, value)
+}
+
+// ValuesSchemaError is returned if the values do not meet the values.schema.json of the chart or its subcharts.
+//
+// e.g.
+//
+//  var schemaErr *helmut.ValuesSchemaError
+//  if errors.As(err, &schemaErr) {
+//  	for _, v := range schemaErr.Violations {
+//  		fmt.Println(v.Pointer, v.Rule, v.Value)
+//  	}
+//  }
+//
+type ValuesSchemaError struct {
+	Violations []SchemaViolation
+}
+
+// Error implements the error interface.
+func (e *ValuesSchemaError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("values don't meet the specifications of the schema:")
+
+	for _, v := range e.Violations {
+		b.WriteString("\n  ")
+		b.WriteString(v.String())
+	}
+
+	return b.String()
+}
+
+// ValidateValues validates the values against the values.schema.json of the chart and its subcharts
+// without rendering the templates.
+// The chart is a path to a chart directory or archive, and the values are the values supplied by the user,
+// which are coalesced with the default values of the chart like "helm template".
+// If the values do not meet the schema, *ValuesSchemaError is returned.
+//
+// e.g.
+//
+//  values, err := chartutil.ReadValuesFile("values-production.yaml")
+//
+//  err = helmut.ValidateValues("testdata/test-chart", values)
+//
+func ValidateValues(chartPath string, values map[string]interface{}) error {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	if err := chartutil.ProcessDependenciesWithMerge(c, values); err != nil {
+		return fmt.Errorf("failed to process dependencies: %w", err)
+	}
+
+	computed, err := chartutil.CoalesceValues(c, values)
+	if err != nil {
+		return fmt.Errorf("failed to compute values: %w", err)
+	}
+
+	return validateValuesSchema(c, computed)
+}
+
+// validateValuesSchema validates the computed values against the schemas of the processed chart,
+// like the schema validation of helm.
+// Like helm, a panic of the validator caused by a malformed schema is returned as an error.
+func validateValuesSchema(c *chart.Chart, values map[string]interface{}) (reterr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			reterr = fmt.Errorf("unable to validate schema: %v", r)
+		}
+	}()
+
+	var violations []SchemaViolation
+
+	if err := collectSchemaViolations(c, values, "", &violations); err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	sortSchemaViolations(violations)
+
+	return &ValuesSchemaError{Violations: violations}
+}
+
+// collectSchemaViolations appends the violations of the chart and its subcharts.
+// The prefix is the JSON pointer to the values of the chart.
+func collectSchemaViolations(c *chart.Chart, values map[string]interface{}, prefix string, violations *[]SchemaViolation) error {
+	if c.Schema != nil {
+		schema, err := compileSchema(c.Schema)
+		if err != nil {
+			return fmt.Errorf("failed to compile the values schema of %s: %w", c.Name(), err)
+		}
+
+		if err := schema.Validate(values); err != nil {
+			validationErr, ok := err.(*jsonschema.ValidationError) //nolint:errorlint // Validate returns the error as is.
+			if !ok {
+				return fmt.Errorf("failed to validate values of %s: %w", c.Name(), err)
+			}
+
+			*violations = append(*violations, schemaViolations(c.Name(), prefix, values, validationErr)...)
+		}
+	}
+
+	for _, subchart := range c.Dependencies() {
+		raw, ok := values[subchart.Name()]
+		if !ok || raw == nil {
+			continue
+		}
+
+		pointer := prefix + "/" + escapePointerToken(subchart.Name())
+
+		subchartValues, ok := raw.(map[string]interface{})
+		if !ok {
+			*violations = append(*violations, SchemaViolation{
+				Chart:   subchart.Name(),
+				Pointer: pointer,
+				Rule:    "type",
+				Message: fmt.Sprintf("got %s, want object", valueTypeName(raw)),
+				Value:   raw,
+			})
+
+			continue
+		}
+
+		if err := collectSchemaViolations(subchart, subchartValues, pointer, violations); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileSchema compiles the values schema.
+// The references are resolved with the same loaders as chartutil.ValidateAgainstSingleSchema,
+// so that the charts whose schema refers to remote schemas are rendered like "helm template".
+func compileSchema(data []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	httpLoader := newSchemaHTTPLoader()
+
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(jsonschema.SchemeURLLoader{
+		"file":  jsonschema.FileLoader{},
+		"http":  httpLoader,
+		"https": httpLoader,
+		"urn":   schemaURNLoader{},
+	})
+
+	if err := compiler.AddResource(schemaURL, doc); err != nil {
+		return nil, err
+	}
+
+	return compiler.Compile(schemaURL)
+}
+
+// newSchemaHTTPLoader creates a loader for the HTTP and HTTPS references of the values schema.
+// This is equivalent to the loader of chartutil.ValidateAgainstSingleSchema.
+func newSchemaHTTPLoader() *chartutil.HTTPURLLoader {
+	loader := chartutil.HTTPURLLoader(http.Client{
+		Timeout: schemaHTTPTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{}, //nolint:gosec // Same as helm.
+		},
+	})
+
+	return &loader
+}
+
+// schemaURNLoader resolves the URN references of the values schema with chartutil.URNResolver.
+// Like helm, unresolved URNs are treated as permissive schemas.
+type schemaURNLoader struct{}
+
+// Load implements jsonschema.URLLoader.
+// It returns the schema resolved for the URN, or the "true" schema that accepts any value if it cannot be resolved.
+func (schemaURNLoader) Load(urn string) (any, error) {
+	if doc, err := chartutil.URNResolver(urn); err == nil && doc != nil {
+		return doc, nil
+	}
+
+	return jsonschema.UnmarshalJSON(strings.NewReader("true"))
+}
+
+// schemaViolations returns the violations of the leaves of the validation error.
+// A failure of "anyOf" or "oneOf" is reported as a single violation instead of the failures of each subschema.
+func schemaViolations(
+	chartName, prefix string,
+	values map[string]interface{},
+	err *jsonschema.ValidationError,
+) []SchemaViolation {
+	switch err.ErrorKind.(type) {
+	case *kind.AnyOf, *kind.OneOf:
+	default:
+		if len(err.Causes) != 0 {
+			var violations []SchemaViolation
+
+			for _, cause := range err.Causes {
+				violations = append(violations, schemaViolations(chartName, prefix, values, cause)...)
+			}
+
+			return violations
+		}
+	}
+
+	rule := strings.Join(err.ErrorKind.KeywordPath(), "/")
+	if len(rule) == 0 {
+		rule = "false"
+	}
+
+	pointer := prefix
+
+	for _, token := range err.InstanceLocation {
+		pointer += "/" + escapePointerToken(token)
+	}
+
+	return []SchemaViolation{{
+		Chart:   chartName,
+		Pointer: pointer,
+		Rule:    rule,
+		Message: err.ErrorKind.LocalizedString(message.NewPrinter(language.English)),
+		Value:   lookupInstance(values, err.InstanceLocation),
+	}}
+}
+
+// lookupInstance returns the value at the location of the JSON instance.
+func lookupInstance(values map[string]interface{}, location []string) interface{} {
+	var current interface{} = values
+
+	for _, token := range location {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+
+			current = v[i]
+		default:
+			return nil
+		}
+	}
+
+	return current
+}
+
+// escapePointerToken escapes the reference token of the JSON pointer.
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// pointerOrRoot returns "/" for the JSON pointer to the whole values.
+func pointerOrRoot(pointer string) string {
+	if len(pointer) == 0 {
+		return "/"
+	}
+
+	return pointer
+}
+
+// sortSchemaViolations sorts the violations by the pointer and the rule.
+func sortSchemaViolations(violations []SchemaViolation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Pointer != violations[j].Pointer {
+			return violations[i].Pointer < violations[j].Pointer
+		}
+
+		return violations[i].Rule < violations[j].Rule
+	})
+}
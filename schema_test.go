@@ -0,0 +1,316 @@
+package helmut_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderTemplatesWithValuesSchema(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartPath   = "testdata/schema-chart"
+	)
+
+	tests := []struct {
+		name    string
+		options []helmut.Option
+		want    []helmut.SchemaViolation
+	}{
+		{
+			name: "valid values",
+		},
+		{
+			name:    "type",
+			options: []helmut.Option{helmut.WithSetString("replicaCount=2")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/replicaCount",
+					Rule:    "type",
+					Message: "got string, want integer",
+					Value:   "2",
+				},
+			},
+		},
+		{
+			name:    "minimum",
+			options: []helmut.Option{helmut.WithSet("replicaCount=0")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/replicaCount",
+					Rule:    "minimum",
+					Message: "minimum: got 0, want 1",
+					Value:   int64(0),
+				},
+			},
+		},
+		{
+			name:    "required",
+			options: []helmut.Option{helmut.WithSet("image.repository=null")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/image",
+					Rule:    "required",
+					Message: "missing property 'repository'",
+					Value:   map[string]interface{}{"tag": "1.0"},
+				},
+			},
+		},
+		{
+			name:    "multiple violations",
+			options: []helmut.Option{helmut.WithSet("replicaCount=0", "image.tag=1")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/image/tag",
+					Rule:    "type",
+					Message: "got number, want string",
+					Value:   int64(1),
+				},
+				{
+					Chart:   "schema-chart",
+					Pointer: "/replicaCount",
+					Rule:    "minimum",
+					Message: "minimum: got 0, want 1",
+					Value:   int64(0),
+				},
+			},
+		},
+		{
+			name:    "subchart",
+			options: []helmut.Option{helmut.WithSet("child.message=hi")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "child",
+					Pointer: "/child/message",
+					Rule:    "enum",
+					Message: "value must be one of 'hello', 'goodbye'",
+					Value:   "hi",
+				},
+			},
+		},
+		{
+			name:    "violation takes precedence over rendering error",
+			options: []helmut.Option{helmut.WithSet("name=")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/name",
+					Rule:    "minLength",
+					Message: "minLength: got 0, want 1",
+					Value:   "",
+				},
+			},
+		},
+		{
+			name:    "violation takes precedence over rendering error on upgrade",
+			options: []helmut.Option{helmut.WithUpgrade(helmut.PreviousRelease{}), helmut.WithSet("name=")},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/name",
+					Rule:    "minLength",
+					Message: "minLength: got 0, want 1",
+					Value:   "",
+				},
+			},
+		},
+		{
+			name:    "skip schema validation",
+			options: []helmut.Option{helmut.WithSet("replicaCount=0"), helmut.WithSkipSchemaValidation()},
+		},
+		{
+			name:    "upgrade",
+			options: []helmut.Option{helmut.WithUpgrade(helmut.PreviousRelease{Values: map[string]interface{}{"replicaCount": 0}})},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/replicaCount",
+					Rule:    "minimum",
+					Message: "minimum: got 0, want 1",
+					Value:   0,
+				},
+			},
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := r.RenderTemplates(releaseName, chartPath, tt.options...)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("failed to render templates: %s", err)
+				}
+
+				return
+			}
+
+			var schemaErr *helmut.ValuesSchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("got error %v, want ValuesSchemaError", err)
+			}
+
+			if diff := cmp.Diff(tt.want, schemaErr.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   []helmut.SchemaViolation
+	}{
+		{
+			name: "default values",
+		},
+		{
+			name:   "valid values",
+			values: map[string]interface{}{"replicaCount": 3, "child": map[string]interface{}{"message": "goodbye"}},
+		},
+		{
+			name:   "invalid values",
+			values: map[string]interface{}{"replicaCount": 0.5},
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-chart",
+					Pointer: "/replicaCount",
+					Rule:    "type",
+					Message: "got number, want integer",
+					Value:   0.5,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := helmut.ValidateValues("testdata/schema-chart", tt.values)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("failed to validate values: %s", err)
+				}
+
+				return
+			}
+
+			var schemaErr *helmut.ValuesSchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("got error %v, want ValuesSchemaError", err)
+			}
+
+			if diff := cmp.Diff(tt.want, schemaErr.Violations); diff != "" {
+				t.Errorf("violations mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValuesSchemaErrorMessage(t *testing.T) {
+	t.Parallel()
+
+	err := helmut.ValidateValues("testdata/schema-chart", map[string]interface{}{"replicaCount": "two"})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	want := `schema-chart: /replicaCount: type: got string, want integer (value: "two")`
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
+
+func TestValidateValuesWithReferences(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"type": "integer"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		schema  string
+		want    []helmut.SchemaViolation
+		wantErr bool
+	}{
+		{
+			name:   "http",
+			schema: `{"properties": {"replicaCount": {"$ref": "` + srv.URL + `/integer.json"}}}`,
+			want: []helmut.SchemaViolation{
+				{
+					Chart:   "schema-test",
+					Pointer: "/replicaCount",
+					Rule:    "type",
+					Message: "got string, want integer",
+					Value:   "two",
+				},
+			},
+		},
+		{
+			name:   "unresolved urn",
+			schema: `{"properties": {"replicaCount": {"$ref": "urn:example:integer"}}}`,
+		},
+		{
+			name:    "malformed schema",
+			schema:  `{"properties": {"replicaCount": {"type": 1}}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chart := createSchemaChart(t, "replicaCount: two\n", tt.schema)
+
+			err := helmut.ValidateValues(chart, nil)
+
+			switch {
+			case tt.wantErr:
+				if err == nil {
+					t.Fatal("expected error, but got nil")
+				}
+			case tt.want == nil:
+				if err != nil {
+					t.Fatalf("failed to validate values: %s", err)
+				}
+			default:
+				var schemaErr *helmut.ValuesSchemaError
+				if !errors.As(err, &schemaErr) {
+					t.Fatalf("got error %v, want ValuesSchemaError", err)
+				}
+
+				if diff := cmp.Diff(tt.want, schemaErr.Violations); diff != "" {
+					t.Errorf("violations mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package helmut
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	yaml "go.yaml.in/yaml/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	// schemaAnnotation is the prefix of the comments that override the inferred schema.
+	schemaAnnotation = "@schema"
+	// schemaDraft is the JSON schema draft of the generated schema.
+	schemaDraft = "http://json-schema.org/draft-07/schema#"
+)
+
+// schemaTypes is the valid types of the type annotation.
+var schemaTypes = map[string]bool{
+	"string": true, "integer": true, "number": true, "boolean": true, "object": true, "array": true, "null": true,
+}
+
+// generatedSchema is the JSON schema inferred from the values.
+type generatedSchema struct {
+	Schema     string                      `json:"$schema,omitempty"`
+	Type       interface{}                 `json:"type,omitempty"`
+	Required   []string                    `json:"required,omitempty"`
+	Properties map[string]*generatedSchema `json:"properties,omitempty"`
+	Items      *generatedSchema            `json:"items,omitempty"`
+}
+
+// types returns the types of the schema.
+func (s *generatedSchema) types() []string {
+	return schemaTypeList(s.Type)
+}
+
+// GenerateValuesSchema infers a JSON schema from the values.yaml of the chart
+// and returns it in the format of values.schema.json.
+// The chart is a path to a chart directory or archive.
+//
+// The types are inferred from the default values: a map is an object with properties,
+// a list is an array whose items are inferred from the first element, and a null has no type.
+// The comments starting with "@schema" override the inferred schema of the key:
+//
+//  # @schema type=integer|null required
+//  replicaCount: 1
+//
+// "type" overrides the inferred types, separated by "|",
+// and "required" adds the key to the required properties of its parent.
+func GenerateValuesSchema(chartPath string) ([]byte, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	schema, err := inferChartSchema(c)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// SchemaDrift is a difference between the values.schema.json of a chart and the schema inferred from its values.yaml.
+type SchemaDrift struct {
+	// Pointer is the JSON pointer to the subschema in the values.schema.json.
+	// e.g. "/properties/image/properties/tag"
+	Pointer string
+
+	// Message describes the difference.
+	// e.g. "type is integer, want string"
+	Message string
+}
+
+// String returns the drift in a single line.
+func (d SchemaDrift) String() string {
+	return pointerOrRoot(d.Pointer) + ": " + d.Message
+}
+
+// CheckValuesSchema compares the values.schema.json of the chart with the schema inferred from the values.yaml
+// by GenerateValuesSchema, and returns the differences in the order of the pointers.
+// Only the inferred keywords, that are "type", "properties", "items" and the required keys
+// annotated in the values.yaml, are compared, so the schema can have other keywords such as "minimum".
+// The types are compared only if both schemas declare them, and the properties are compared only
+// if the values.schema.json declares the properties of the object.
+func CheckValuesSchema(chartPath string) ([]SchemaDrift, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+
+	if c.Schema == nil {
+		return nil, errors.New("values.schema.json not found in the chart")
+	}
+
+	generated, err := inferChartSchema(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing map[string]interface{}
+
+	if err := json.Unmarshal(c.Schema, &existing); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal values.schema.json: %w", err)
+	}
+
+	var drifts []SchemaDrift
+
+	diffSchema("", generated, existing, &drifts)
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		return drifts[i].Pointer < drifts[j].Pointer
+	})
+
+	return drifts, nil
+}
+
+// inferChartSchema infers the schema from the values.yaml of the chart.
+func inferChartSchema(c *chart.Chart) (*generatedSchema, error) {
+	var data []byte
+
+	for _, f := range c.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			data = f.Data
+
+			break
+		}
+	}
+
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse values.yaml: %w", err)
+	}
+
+	schema := &generatedSchema{Type: "object"}
+
+	if len(doc.Content) != 0 {
+		var err error
+
+		schema, err = inferSchema(doc.Content[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schema.Schema = schemaDraft
+
+	return schema, nil
+}
+
+// inferSchema infers the schema from the YAML node.
+func inferSchema(node *yaml.Node) (*generatedSchema, error) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		schema := &generatedSchema{Type: "object"}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			if key.Value == "<<" {
+				// Merge keys are resolved by the value of the merged keys, so they are not supported.
+				return nil, fmt.Errorf("line %d: merge keys are not supported", key.Line)
+			}
+
+			property, err := inferSchema(value)
+			if err != nil {
+				return nil, err
+			}
+
+			annotation, err := parseSchemaAnnotation(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(annotation.types) != 0 {
+				property.setTypes(annotation.types)
+			}
+
+			if annotation.required {
+				schema.Required = append(schema.Required, key.Value)
+			}
+
+			if schema.Properties == nil {
+				schema.Properties = make(map[string]*generatedSchema)
+			}
+
+			schema.Properties[key.Value] = property
+		}
+
+		return schema, nil
+	case yaml.SequenceNode:
+		schema := &generatedSchema{Type: "array"}
+
+		if len(node.Content) != 0 {
+			items, err := inferSchema(node.Content[0])
+			if err != nil {
+				return nil, err
+			}
+
+			schema.Items = items
+		}
+
+		return schema, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!str", "!!binary", "!!timestamp":
+			return &generatedSchema{Type: "string"}, nil
+		case "!!int":
+			return &generatedSchema{Type: "integer"}, nil
+		case "!!float":
+			return &generatedSchema{Type: "number"}, nil
+		case "!!bool":
+			return &generatedSchema{Type: "boolean"}, nil
+		case "!!null":
+			// A null is usually a placeholder to be set by the user, so any type is allowed.
+			return &generatedSchema{}, nil
+		}
+
+		return nil, fmt.Errorf("line %d: unsupported tag %s", node.Line, node.ShortTag())
+	}
+
+	return nil, fmt.Errorf("line %d: unsupported node", node.Line)
+}
+
+// setTypes overrides the types of the schema.
+// The properties and the items are kept only if the types allow them.
+func (s *generatedSchema) setTypes(types []string) {
+	if len(types) == 1 {
+		s.Type = types[0]
+	} else {
+		s.Type = types
+	}
+
+	if !containsString(types, "object") {
+		s.Properties = nil
+		s.Required = nil
+	}
+
+	if !containsString(types, "array") {
+		s.Items = nil
+	}
+}
+
+// schemaAnnotationValue is the overrides of a key annotated in the comments.
+type schemaAnnotationValue struct {
+	types    []string
+	required bool
+}
+
+// parseSchemaAnnotation parses the "@schema" comments of the key and its value.
+func parseSchemaAnnotation(key, value *yaml.Node) (schemaAnnotationValue, error) {
+	var annotation schemaAnnotationValue
+
+	for _, comment := range []string{key.HeadComment, key.LineComment, value.HeadComment, value.LineComment} {
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+
+			if !strings.HasPrefix(line, schemaAnnotation) {
+				continue
+			}
+
+			for _, field := range strings.Fields(strings.TrimPrefix(line, schemaAnnotation)) {
+				name, arg, _ := strings.Cut(field, "=")
+
+				switch name {
+				case "required":
+					annotation.required = true
+				case "type":
+					types := strings.Split(arg, "|")
+
+					for _, t := range types {
+						if !schemaTypes[t] {
+							return annotation, fmt.Errorf("line %d: invalid type %q of %s", key.Line, t, key.Value)
+						}
+					}
+
+					annotation.types = types
+				default:
+					return annotation, fmt.Errorf("line %d: unknown annotation %q of %s", key.Line, field, key.Value)
+				}
+			}
+		}
+	}
+
+	return annotation, nil
+}
+
+// diffSchema appends the differences of the inferred keywords between the generated and the existing schema.
+func diffSchema(pointer string, generated *generatedSchema, existing map[string]interface{}, drifts *[]SchemaDrift) {
+	add := func(pointer, format string, args ...interface{}) {
+		*drifts = append(*drifts, SchemaDrift{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	want := generated.types()
+	got := schemaTypeList(existing["type"])
+
+	if len(want) != 0 && len(got) != 0 && !sameStrings(want, got) {
+		add(pointer, "type is %s, want %s", strings.Join(got, "|"), strings.Join(want, "|"))
+	}
+
+	required := make(map[string]bool)
+
+	if list, ok := existing["required"].([]interface{}); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	for _, name := range generated.Required {
+		if !required[name] {
+			add(pointer, "%q is not required", name)
+		}
+	}
+
+	if properties, ok := existing["properties"].(map[string]interface{}); ok {
+		for name, property := range generated.Properties {
+			propertyPointer := pointer + "/properties/" + escapePointerToken(name)
+
+			existingProperty, ok := properties[name].(map[string]interface{})
+			if !ok {
+				add(propertyPointer, "not declared in the schema")
+
+				continue
+			}
+
+			diffSchema(propertyPointer, property, existingProperty, drifts)
+		}
+
+		for name := range properties {
+			// An empty map in values.yaml, such as "resources: {}", is a placeholder for the properties.
+			if _, ok := generated.Properties[name]; !ok && len(generated.Properties) != 0 {
+				add(pointer+"/properties/"+escapePointerToken(name), "not found in values.yaml")
+			}
+		}
+	}
+
+	if items, ok := existing["items"].(map[string]interface{}); ok && generated.Items != nil {
+		diffSchema(pointer+"/items", generated.Items, items, drifts)
+	}
+}
+
+// schemaTypeList returns the value of the "type" keyword as a sorted list.
+func schemaTypeList(value interface{}) []string {
+	var types []string
+
+	switch v := value.(type) {
+	case string:
+		types = []string{v}
+	case []string:
+		types = append(types, v...)
+	case []interface{}:
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
+// sameStrings returns true if the sorted lists are equal.
+func sameStrings(x, y []string) bool {
+	return strings.Join(x, "\x00") == strings.Join(y, "\x00")
+}
+
+// containsString returns true if the list contains the string.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,279 @@
+package helmut_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateValuesSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values string
+		want   string
+	}{
+		{
+			name:   "empty values",
+			values: "",
+			want:   `{"$schema": "http://json-schema.org/draft-07/schema#", "type": "object"}`,
+		},
+		{
+			name:   "scalars",
+			values: "name: web\nreplicas: 1\nratio: 0.5\nenabled: true\ntag: \"1.0\"\nplaceholder: null\n",
+			want: `{
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"replicas": {"type": "integer"},
+					"ratio": {"type": "number"},
+					"enabled": {"type": "boolean"},
+					"tag": {"type": "string"},
+					"placeholder": {}
+				}
+			}`,
+		},
+		{
+			name:   "objects and arrays",
+			values: "image:\n  repository: nginx\nports:\n  - name: http\n    port: 80\nannotations: {}\nsecrets: []\n",
+			want: `{
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"type": "object",
+				"properties": {
+					"image": {"type": "object", "properties": {"repository": {"type": "string"}}},
+					"ports": {
+						"type": "array",
+						"items": {"type": "object", "properties": {"name": {"type": "string"}, "port": {"type": "integer"}}}
+					},
+					"annotations": {"type": "object"},
+					"secrets": {"type": "array"}
+				}
+			}`,
+		},
+		{
+			name: "annotations",
+			values: `# The name of the application.
+# @schema required
+name: web
+replicas: 1 # @schema type=integer|string
+image:
+  # @schema required type=string
+  repository: nginx
+  # @schema type=string|null
+  tag: null
+# @schema type=string
+resources: {}
+`,
+			want: `{
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string"},
+					"replicas": {"type": ["integer", "string"]},
+					"image": {
+						"type": "object",
+						"required": ["repository"],
+						"properties": {"repository": {"type": "string"}, "tag": {"type": ["string", "null"]}}
+					},
+					"resources": {"type": "string"}
+				}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chartPath := createSchemaChart(t, tt.values, "")
+
+			got, err := helmut.GenerateValuesSchema(chartPath)
+			if err != nil {
+				t.Fatalf("failed to generate schema: %s", err)
+			}
+
+			if diff := cmp.Diff(unmarshalJSON(t, tt.want), unmarshalJSON(t, string(got))); diff != "" {
+				t.Errorf("schema mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateValuesSchemaError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values string
+	}{
+		{
+			name:   "unknown annotation",
+			values: "# @schema optional\nname: web\n",
+		},
+		{
+			name:   "invalid type",
+			values: "# @schema type=text\nname: web\n",
+		},
+		{
+			name:   "merge key",
+			values: "base: &base\n  name: web\nimage:\n  <<: *base\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := helmut.GenerateValuesSchema(createSchemaChart(t, tt.values, "")); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestCheckValuesSchema(t *testing.T) {
+	t.Parallel()
+
+	const values = `# @schema required
+name: web
+replicas: 1
+image:
+  repository: nginx
+ports:
+  - port: 80
+resources: {}
+`
+
+	tests := []struct {
+		name   string
+		schema string
+		want   []helmut.SchemaDrift
+	}{
+		{
+			name: "no drift",
+			schema: `{
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string", "minLength": 1},
+					"replicas": {"type": "integer", "minimum": 1},
+					"image": {"type": "object", "properties": {"repository": {"type": "string"}}},
+					"ports": {"type": "array", "items": {"type": "object", "properties": {"port": {"type": "integer"}}}},
+					"resources": {"type": "object", "properties": {"limits": {"type": "object"}}}
+				}
+			}`,
+		},
+		{
+			name: "no properties declared",
+			schema: `{
+				"type": "object",
+				"required": ["name"]
+			}`,
+		},
+		{
+			name: "drift",
+			schema: `{
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"replicas": {"type": "string"},
+					"image": {"type": "object", "properties": {"registry": {"type": "string"}}},
+					"ports": {"type": "array", "items": {"type": "object", "properties": {"port": {"type": "string"}}}}
+				}
+			}`,
+			want: []helmut.SchemaDrift{
+				{Pointer: "", Message: `"name" is not required`},
+				{Pointer: "/properties/image/properties/registry", Message: "not found in values.yaml"},
+				{Pointer: "/properties/image/properties/repository", Message: "not declared in the schema"},
+				{Pointer: "/properties/ports/items/properties/port", Message: "type is string, want integer"},
+				{Pointer: "/properties/replicas", Message: "type is string, want integer"},
+				{Pointer: "/properties/resources", Message: "not declared in the schema"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := helmut.CheckValuesSchema(createSchemaChart(t, values, tt.schema))
+			if err != nil {
+				t.Fatalf("failed to check schema: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("drifts mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckValuesSchemaGenerated(t *testing.T) {
+	t.Parallel()
+
+	chartPath := createSchemaChart(t, "name: web\nimage:\n  tag: \"1.0\"\n", "")
+
+	schema, err := helmut.GenerateValuesSchema(chartPath)
+	if err != nil {
+		t.Fatalf("failed to generate schema: %s", err)
+	}
+
+	writeFile(t, filepath.Join(chartPath, "values.schema.json"), string(schema))
+
+	drifts, err := helmut.CheckValuesSchema(chartPath)
+	if err != nil {
+		t.Fatalf("failed to check schema: %s", err)
+	}
+
+	if len(drifts) != 0 {
+		t.Errorf("got drifts from the generated schema: %v", drifts)
+	}
+}
+
+func TestCheckValuesSchemaWithoutSchema(t *testing.T) {
+	t.Parallel()
+
+	if _, err := helmut.CheckValuesSchema(createSchemaChart(t, "name: web\n", "")); err == nil {
+		t.Error("got no error for the chart without values.schema.json")
+	}
+}
+
+// createSchemaChart creates a chart with the values.yaml and the values.schema.json, if not empty.
+func createSchemaChart(t *testing.T, values, schema string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "Chart.yaml"), "apiVersion: v2\nname: schema-test\nversion: 0.1.0\n")
+	writeFile(t, filepath.Join(dir, "values.yaml"), values)
+
+	if len(schema) != 0 {
+		writeFile(t, filepath.Join(dir, "values.schema.json"), schema)
+	}
+
+	return dir
+}
+
+func unmarshalJSON(t *testing.T, data string) interface{} {
+	t.Helper()
+
+	var v interface{}
+
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %s", err)
+	}
+
+	return v
+}
@@ -39,3 +39,14 @@ func WithScheme(scheme *runtime.Scheme) SchemeOption {
 		o.scheme = scheme
 	}
 }
+
+// applyRendererOption implements the RendererOption interface.
+func (o SchemeOption) applyRendererOption(opts *rendererOption) {
+	scheme := &schemeOption{}
+
+	o(scheme)
+
+	if !scheme.Empty() {
+		opts.scheme = scheme.scheme
+	}
+}
@@ -0,0 +1,113 @@
+package helmut
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// sourceCommentRegexp matches the comments that helm adds to the manifests to show the template files,
+// and captures the path of the file relative to the directory of the chart.
+var sourceCommentRegexp = regexp.MustCompile("# Source: [^/]+/(.+)")
+
+// checkShowOnlyFiles returns an error if a pattern is invalid or matches no file of the chart and its subcharts.
+// The patterns are matched with the paths relative to the chart directory,
+// such as "templates/deployment.yaml", "crds/crd.yaml" and "charts/test-chart/templates/deployment.yaml".
+func checkShowOnlyFiles(c *chart.Chart, patterns []string) error {
+	files := chartFiles(c, "")
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid show-only pattern %q: %w", pattern, err)
+		}
+
+		if !matchAny(pattern, files) {
+			return fmt.Errorf("could not find template %s in chart %s", pattern, c.Name())
+		}
+	}
+
+	return nil
+}
+
+// showOnly returns the manifests of the release rendered from the files that match the patterns,
+// like the "--show-only" option of the "helm template" command.
+// The manifests are identified by the source comments that helm adds to the manifests,
+// so the CRDs are also filtered, and an error is returned if a pattern matches no manifest.
+func showOnly(manifest string, patterns []string) (string, error) {
+	split := releaseutil.SplitManifests(manifest)
+
+	keys := make([]string, 0, len(split))
+	for key := range split {
+		keys = append(keys, key)
+	}
+
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var b strings.Builder
+
+	shown := make(map[string]bool, len(keys))
+
+	for _, pattern := range patterns {
+		found := false
+
+		for _, key := range keys {
+			submatch := sourceCommentRegexp.FindStringSubmatch(split[key])
+			if len(submatch) == 0 {
+				continue
+			}
+
+			if ok, _ := path.Match(pattern, submatch[1]); !ok {
+				continue
+			}
+
+			found = true
+
+			if !shown[key] {
+				shown[key] = true
+				fmt.Fprintf(&b, "---\n%s\n", split[key])
+			}
+		}
+
+		if !found {
+			return "", fmt.Errorf("template %s rendered no manifests", pattern)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// chartFiles returns the paths of the templates and the CRD files of the chart and its subcharts,
+// relative to the chart directory and prefixed with the prefix.
+func chartFiles(c *chart.Chart, prefix string) []string {
+	files := make([]string, 0, len(c.Templates))
+
+	for _, t := range c.Templates {
+		files = append(files, prefix+t.Name)
+	}
+
+	for _, crd := range c.CRDs() {
+		files = append(files, prefix+crd.Name)
+	}
+
+	for _, dep := range c.Dependencies() {
+		files = append(files, chartFiles(dep, prefix+"charts/"+dep.Name()+"/")...)
+	}
+
+	return files
+}
+
+// matchAny reports whether the pattern matches any of the paths.
+func matchAny(pattern string, paths []string) bool {
+	for _, p := range paths {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
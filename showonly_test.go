@@ -0,0 +1,185 @@
+package helmut_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRenderTemplatesWithShowOnly(t *testing.T) {
+	t.Parallel()
+
+	umbrellaOptions := []helmut.Option{
+		helmut.WithDependencyBuild(),
+		helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart"),
+	}
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+		want    []string
+	}{
+		{
+			name:    "single file",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithShowOnly("templates/deployment.yaml")},
+			want:    []string{"Deployment/foo-test-chart"},
+		},
+		{
+			name:    "multiple files",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithShowOnly("templates/service.yaml", "templates/serviceaccount.yaml")},
+			want:    []string{"Service/foo-test-chart", "ServiceAccount/foo-test-chart"},
+		},
+		{
+			name:    "glob pattern",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithShowOnly("templates/service*.yaml")},
+			want:    []string{"Service/foo-test-chart", "ServiceAccount/foo-test-chart"},
+		},
+		{
+			name:    "subchart file",
+			chart:   "testdata/umbrella-chart",
+			options: append([]helmut.Option{helmut.WithShowOnly("charts/test-chart/templates/service.yaml")}, umbrellaOptions...),
+			want:    []string{"Service/foo-test-chart"},
+		},
+		{
+			name:    "crd file",
+			chart:   "testdata/crd-chart",
+			options: []helmut.Option{helmut.WithIncludeCRDs(), helmut.WithShowOnly("crds/widget.yaml")},
+			want:    []string{"CustomResourceDefinition/widgets.example.com"},
+		},
+		{
+			name:    "crds are filtered",
+			chart:   "testdata/crd-chart",
+			options: []helmut.Option{helmut.WithIncludeCRDs(), helmut.WithShowOnly("templates/widget.yaml")},
+			want:    []string{"Widget/foo"},
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := renderer.RenderTemplates("foo", tt.chart, tt.options...)
+			if err != nil {
+				t.Fatalf("render error: %s", err)
+			}
+
+			var got []string
+
+			for _, key := range manifests.GetKeys() {
+				got = append(got, key.Kind+"/"+key.Name)
+			}
+
+			sort.Strings(got)
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("manifests mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderTemplatesWithShowOnlyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+		want    string
+	}{
+		{
+			name:    "file does not exist",
+			options: []helmut.Option{helmut.WithShowOnly("templates/configmap.yaml")},
+			want:    "could not find template templates/configmap.yaml in chart test-chart",
+		},
+		{
+			name:    "file renders nothing",
+			options: []helmut.Option{helmut.WithShowOnly("templates/hpa.yaml")},
+			want:    "template templates/hpa.yaml rendered no manifests",
+		},
+		{
+			name:    "crd file without including crds",
+			chart:   "testdata/crd-chart",
+			options: []helmut.Option{helmut.WithShowOnly("crds/widget.yaml")},
+			want:    "template crds/widget.yaml rendered no manifests",
+		},
+		{
+			name:    "invalid pattern",
+			options: []helmut.Option{helmut.WithShowOnly("templates/[")},
+			want:    `invalid show-only pattern "templates/["`,
+		},
+	}
+
+	renderer := helmut.New()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chart := tt.chart
+			if len(chart) == 0 {
+				chart = "testdata/test-chart"
+			}
+
+			_, err := renderer.RenderTemplates("foo", chart, tt.options...)
+			if err == nil {
+				t.Fatal("expected error, but got nil")
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplatesWithShowOnlyKeepsNamedTemplates(t *testing.T) {
+	t.Parallel()
+
+	renderer := helmut.New()
+
+	// Both templates define "showonly-chart.owner", so the definition used by helm must not change
+	// depending on the shown files.
+	all, err := renderer.RenderTemplates("foo", "testdata/showonly-chart")
+	if err != nil {
+		t.Fatalf("render error: %s", err)
+	}
+
+	for _, file := range []string{"templates/a.yaml", "templates/b.yaml"} {
+		file := file
+
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := renderer.RenderTemplates("foo", "testdata/showonly-chart", helmut.WithShowOnly(file))
+			if err != nil {
+				t.Fatalf("render error: %s", err)
+			}
+
+			keys := manifests.GetKeys()
+			if len(keys) != 1 {
+				t.Fatalf("got %d manifests, want 1", len(keys))
+			}
+
+			got, _ := manifests.Load(keys[0])
+			want, _ := all.Load(keys[0])
+
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("manifest mismatch (-all +show-only):\n%s", diff)
+			}
+		})
+	}
+}
@@ -4,14 +4,21 @@ package helmut
 import (
 	"errors"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/d-kuro/helmut/util"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,37 +28,115 @@ import (
 // Renderer will perform the equivalent of the "helm template" command to render the manifests.
 type Renderer struct {
 	scheme *runtime.Scheme
+	opts   *rendererOption
 
-	once sync.Once
+	// settings and providers are created once and reused across renders.
+	// The settings are not modified after they are stored, but replaced with a copy by ensureHome,
+	// so that concurrent renders can read them.
+	settings  atomic.Pointer[cli.EnvSettings]
+	providers getter.Providers
+
+	// tempDir is the temporary helm home created for the hermetic environment.
+	tempDir  string
+	homeErr  error
+	homeOnce sync.Once
+
+	// closed indicates whether Close has been called.
+	closed atomic.Bool
+
+	// reposUpdated indicates whether the indexes of the repositories have been downloaded.
+	reposUpdated bool
+	repoMu       sync.Mutex
+
+	// charts caches the loaded charts across renders.
+	charts *chartCache
+
+	// err is the error that occurred during initialization of the helm environment.
+	err error
+
+	once    sync.Once
+	envOnce sync.Once
 }
 
 // New creates and returns a new Renderer.
 // option allows you to specify the scheme to be used by the Renderer.
 // For example, specify the scheme in which the custom resource is registered.
-func New(options ...SchemeOption) *Renderer {
-	opts := &schemeOption{}
+//
+// By default, the Renderer uses a hermetic Helm environment with temporary config, cache and data directories,
+// so that the result does not depend on the "HELM_*" environment variables and the files of the host.
+// The temporary directories are created only when they are needed, such as rendering charts from
+// repositories and registries, and they are removed by Close.
+// Callers must call Close when the Renderer is no longer used.
+func New(options ...RendererOption) *Renderer {
+	opts := &rendererOption{}
 
 	for _, o := range options {
-		o(opts)
+		o.applyRendererOption(opts)
 	}
 
-	if opts.Empty() {
-		return &Renderer{scheme: defaultScheme}
+	if opts.scheme == nil {
+		opts.scheme = defaultScheme
 	}
 
-	return &Renderer{scheme: opts.scheme}
+	return &Renderer{scheme: opts.scheme, opts: opts}
 }
 
-// init registers the default scheme to the Renderer if no scheme is specified.
+// init registers the default scheme to the Renderer if no scheme is specified,
+// and initializes the chart cache.
 func (r *Renderer) init() {
 	if r.scheme == nil {
 		r.scheme = defaultScheme
 	}
+
+	if r.opts == nil {
+		r.opts = &rendererOption{}
+	}
+
+	r.charts = newChartCache()
+}
+
+// initEnvironment is executed by sync.Once to initialize the helm environment.
+// It is separated from init so that the directories are not created unless rendering.
+func (r *Renderer) initEnvironment() {
+	r.once.Do(r.init)
+
+	settings, err := newSettings(r.opts)
+	if err != nil {
+		r.err = fmt.Errorf("failed to initialize helm environment: %w", err)
+
+		return
+	}
+
+	r.settings.Store(settings)
+	r.providers = getter.All(settings)
+}
+
+// Close removes the temporary directories created for the hermetic environment.
+// The Renderer cannot be used after closing.
+func (r *Renderer) Close() error {
+	r.closed.Store(true)
+
+	// Prevent the temporary directories from being created after closing.
+	r.homeOnce.Do(func() {
+		r.homeErr = errRendererClosed
+	})
+
+	if len(r.tempDir) == 0 {
+		return nil
+	}
+
+	if err := os.RemoveAll(r.tempDir); err != nil {
+		return fmt.Errorf("failed to remove temporary directory: %w", err)
+	}
+
+	return nil
 }
 
 // RenderTemplates will execute the equivalent of the "helm template" command and return the result.
 func (r *Renderer) RenderTemplates(name, chart string, options ...Option) (*Manifests, error) {
-	r.once.Do(r.init)
+	if err := r.prepare(); err != nil {
+		return nil, err
+	}
 
 	opts := &option{}
 
@@ -59,40 +144,226 @@ func (r *Renderer) RenderTemplates(name, chart string, options ...Option) (*Mani
 		o(opts)
 	}
 
+	opts.coverage = r.opts.coverage
+
+	manifests, _, err := r.render(name, chart, opts)
+
+	return manifests, err
+}
+
+// render renders the chart with the options and returns the manifests with the chart of the release,
+// whose dependencies have been processed.
+func (r *Renderer) render(name, chart string, opts *option) (*Manifests, *chart.Chart, error) {
 	client := newClient(name, opts)
 
-	valueOpts := &values.Options{
-		ValueFiles:   opts.valueFiles,
-		StringValues: opts.stringValues,
-		Values:       opts.values,
-		FileValues:   opts.fileValues,
+	postRenderer, err := r.newPostRenderer(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chartRequested, values, cleanup, err := r.loadRequest(client, chart, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	if err := checkShowOnlyFiles(chartRequested, opts.showOnly); err != nil {
+		return nil, nil, err
+	}
+
+	var rendered *release.Release
+
+	if opts.deterministic != nil {
+		rendered, err = r.renderDeterministic(name, chartRequested, values, opts, postRenderer)
+	} else {
+		rendered, err = r.runRelease(name, client, chartRequested, values, opts, postRenderer)
+	}
+
+	if err != nil {
+		// Helm validates the values before rendering, so the violations take precedence over the rendering error.
+		if rendered != nil && !opts.skipSchemaValidation {
+			if computed, coalesceErr := chartutil.CoalesceValues(rendered.Chart, rendered.Config); coalesceErr == nil {
+				if schemaErr := validateValuesSchema(rendered.Chart, computed); schemaErr != nil {
+					return nil, nil, fmt.Errorf("failed to validate values: %w", schemaErr)
+				}
+			}
+		}
+
+		return nil, nil, fmt.Errorf("failed to render templates: %w", err)
+	}
+
+	manifest := rendered.Manifest
+
+	if len(opts.showOnly) != 0 {
+		manifest, err = showOnly(manifest, opts.showOnly)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	manifests, err := r.SplitManifests([]byte(manifest))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The chart of the release has been processed for rendering, such as disabling subcharts and importing values.
+	computed, err := chartutil.CoalesceValues(rendered.Chart, rendered.Config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute values: %w", err)
+	}
+
+	if !opts.skipSchemaValidation {
+		if err := validateValuesSchema(rendered.Chart, computed); err != nil {
+			return nil, nil, fmt.Errorf("failed to validate values: %w", err)
+		}
+	}
+
+	manifests.values = Values(computed)
+
+	if opts.valuesTrace {
+		manifests.trace, err = traceValues(rendered.Chart, opts, manifests.values, r.providers)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if opts.coverage != nil {
+		if err := opts.coverage.record(rendered, opts); err != nil {
+			return nil, nil, fmt.Errorf("failed to record template coverage: %w", err)
+		}
+	}
+
+	return manifests, rendered.Chart, nil
+}
+
+// runRelease renders the chart as an installation or an upgrade of the release.
+func (r *Renderer) runRelease(
+	name string,
+	client *action.Install,
+	c *chart.Chart,
+	values map[string]interface{},
+	opts *option,
+	postRenderer postrender.PostRenderer,
+) (*release.Release, error) {
+	if opts.upgrade.previous != nil {
+		return r.upgrade(name, c, values, opts, postRenderer)
+	}
+
+	client.PostRenderer = postRenderer
+
+	return client.Run(c, values)
+}
+
+// renderValues returns the top-level object of the templates used to render the release,
+// so that the templates of the chart can be rendered again by the engine with the same values.
+func renderValues(rendered *release.Release, c *chart.Chart, opts *option) (chartutil.Values, error) {
+	capabilities := chartutil.DefaultCapabilities.Copy()
+	capabilities.APIVersions = append(capabilities.APIVersions, opts.apiVersions...)
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      rendered.Name,
+		Namespace: rendered.Namespace,
+		Revision:  rendered.Version,
+		IsInstall: opts.upgrade.previous == nil,
+		IsUpgrade: opts.upgrade.previous != nil,
+	}
+
+	values, err := chartutil.ToRenderValuesWithSchemaValidation(c, rendered.Config, releaseOptions, capabilities, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute values: %w", err)
 	}
 
-	settings := cli.New()
+	return values, nil
+}
+
+// loadRequest locates and loads the chart, and merges the values of the options.
+// It returns the chart with the values and a function that cleans up the temporary files.
+func (r *Renderer) loadRequest(
+	client *action.Install,
+	chart string,
+	opts *option,
+) (*chart.Chart, map[string]interface{}, func(), error) {
+	noop := func() {}
 
-	chartPath, err := client.ChartPathOptions.LocateChart(chart, settings)
+	chartPath, values, err := r.locateRequest(client, chart, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find chart directory: %w", err)
+		return nil, nil, noop, err
 	}
 
-	providers := getter.All(settings)
+	chartRequested, cleanup, err := r.loadChart(chartPath, opts)
+	if err != nil {
+		return nil, nil, noop, err
+	}
+
+	return chartRequested, values, cleanup, nil
+}
+
+// locateRequest locates the chart and merges the values of the options.
+func (r *Renderer) locateRequest(
+	client *action.Install,
+	chart string,
+	opts *option,
+) (string, map[string]interface{}, error) {
+	if registry.IsOCI(chart) {
+		registryClient, err := r.newRegistryClient(opts)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to create registry client: %w", err)
+		}
 
-	values, err := valueOpts.MergeValues(providers)
+		client.SetRegistryClient(registryClient)
+	}
+
+	valueOpts := &values.Options{
+		ValueFiles:    opts.valueFiles,
+		StringValues:  opts.stringValues,
+		Values:        opts.values,
+		FileValues:    opts.fileValues,
+		JSONValues:    opts.jsonValues,
+		LiteralValues: opts.literalValues,
+	}
+
+	chartPath, err := r.locateChart(client.ChartPathOptions, chart)
 	if err != nil {
-		return nil, fmt.Errorf("failed to merge values: %w", err)
+		return "", nil, fmt.Errorf("failed to find chart directory: %w", err)
 	}
 
-	chartRequested, err := loader.Load(chartPath)
+	values, err := valueOpts.MergeValues(r.providers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load the chart: %w", err)
+		return "", nil, fmt.Errorf("failed to merge values: %w", err)
+	}
+
+	for _, m := range opts.valuesMaps {
+		values = mergeMaps(values, m)
 	}
 
-	release, err := client.Run(chartRequested, values)
+	return chartPath, values, nil
+}
+
+// loadChart loads the chart and returns it with a function that cleans up the temporary files.
+// If WithDependencyBuild is specified, the dependencies are built in a temporary copy of the chart.
+// Otherwise, the chart is loaded from the cache.
+func (r *Renderer) loadChart(chartPath string, opts *option) (*chart.Chart, func(), error) {
+	noop := func() {}
+
+	if !opts.dependencyBuild {
+		c, err := r.charts.Load(chartPath)
+
+		return c, noop, err
+	}
+
+	built, cleanup, err := buildDependencies(chartPath, opts.localRepositories)
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to build dependencies: %w", err)
+	}
+
+	c, err := loader.Load(built)
 	if err != nil {
-		return nil, fmt.Errorf("failed to render templates: %w", err)
+		cleanup()
+
+		return nil, noop, fmt.Errorf("failed to load the chart: %w", err)
 	}
 
-	return r.SplitManifests([]byte(release.Manifest))
+	return c, cleanup, nil
 }
 
 // SplitManifests takes a single large manifest and splits it into individual manifests.
@@ -132,7 +403,7 @@ func (r *Renderer) SplitManifests(data []byte) (*Manifests, error) {
 
 		key := NewObjectKey(accessor.GetNamespace(), accessor.GetName(), *gvk)
 
-		manifests.Store(key, object)
+		manifests.storeDocument(key, object, manifest)
 	}
 
 	return manifests, nil
@@ -149,6 +420,16 @@ func newClient(name string, opts *option) *action.Install {
 	client.ClientOnly = true
 	client.APIVersions = opts.apiVersions
 	client.IncludeCRDs = opts.includeCRDs
+	client.SkipSchemaValidation = true // Validated by helmut to report the violations as ValuesSchemaError
+	client.ChartPathOptions.RepoURL = opts.repoURL
+	client.ChartPathOptions.Version = opts.version
+	client.ChartPathOptions.Username = opts.username
+	client.ChartPathOptions.Password = opts.password
+	client.ChartPathOptions.CertFile = opts.certFile
+	client.ChartPathOptions.KeyFile = opts.keyFile
+	client.ChartPathOptions.CaFile = opts.caFile
+	client.ChartPathOptions.InsecureSkipTLSverify = opts.insecureSkipTLSVerify
+	client.ChartPathOptions.PlainHTTP = opts.plainHTTP
 
 	return client
 }
@@ -82,6 +82,18 @@ func TestRenderTemplates(t *testing.T) {
 			},
 			want: newDeployment(chartName, releaseName, withDeploymentReplicas(2)),
 		},
+		{
+			name: "set helm values with multiple options",
+			options: []helmut.Option{
+				helmut.WithSet("replicaCount=2"),
+				helmut.WithSet("image.tag=1.21.0"),
+				helmut.WithValuesMap(map[string]interface{}{"replicaCount": 3}),
+			},
+			assertOptions: []assert.Option{
+				assert.WithIgnoreHelmManagedLabels(),
+			},
+			want: newDeployment(chartName, releaseName, withDeploymentReplicas(3), withDeploymentImage("nginx:1.21.0")),
+		},
 		{
 			name: "sort volumes",
 			assertOptions: []assert.Option{
@@ -114,7 +126,7 @@ func TestRenderTemplates(t *testing.T) {
 		},
 	}
 
-	r := helmut.New()
+	r := newRenderer(t)
 
 	for _, tt := range tests {
 		tt := tt
@@ -137,6 +149,142 @@ func TestRenderTemplates(t *testing.T) {
 	}
 }
 
+func TestRenderTemplatesWithValueOptions(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartName   = "test-chart"
+	)
+
+	valuesFile := createTempValuesFile(t, []byte("image:\n  tag: 1.20.0\n"))
+	tagFile := createTempValuesFile(t, []byte("1.21.0"))
+	repositoryFile := createTempValuesFile(t, []byte("image:\n  repository: httpd\n"))
+	repositoryNameFile := createTempValuesFile(t, []byte("httpd"))
+	newTagFile := createTempValuesFile(t, []byte("image:\n  tag: 1.22.0\n"))
+
+	tests := []struct {
+		name    string
+		options []helmut.Option
+		want    string
+	}{
+		{
+			name:    "values file",
+			options: []helmut.Option{helmut.WithValues(valuesFile)},
+			want:    "nginx:1.20.0",
+		},
+		{
+			name:    "set",
+			options: []helmut.Option{helmut.WithSet("image.tag=1.21.0")},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "set-string",
+			options: []helmut.Option{helmut.WithSetString("image.tag=1.21.0")},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "set-file",
+			options: []helmut.Option{helmut.WithSetFile("image.tag=" + tagFile)},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "set-json",
+			options: []helmut.Option{helmut.WithSetJSON(`image={"tag":"1.21.0"}`)},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "set-literal",
+			options: []helmut.Option{helmut.WithSetLiteral("image.tag=1.21.0,latest")},
+			want:    "nginx:1.21.0,latest",
+		},
+		{
+			name:    "set-json takes precedence over values file",
+			options: []helmut.Option{helmut.WithSetJSON(`image.tag="1.21.0"`), helmut.WithValues(valuesFile)},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "set takes precedence over set-json",
+			options: []helmut.Option{helmut.WithSet("image.tag=1.22.0"), helmut.WithSetJSON(`image.tag="1.21.0"`)},
+			want:    "nginx:1.22.0",
+		},
+		{
+			name:    "set-string takes precedence over set",
+			options: []helmut.Option{helmut.WithSetString("image.tag=1.22.0"), helmut.WithSet("image.tag=1.21.0")},
+			want:    "nginx:1.22.0",
+		},
+		{
+			name:    "set-file takes precedence over set-string",
+			options: []helmut.Option{helmut.WithSetFile("image.tag=" + tagFile), helmut.WithSetString("image.tag=1.22.0")},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "set-literal takes precedence over set-file",
+			options: []helmut.Option{helmut.WithSetLiteral("image.tag=1.22.0"), helmut.WithSetFile("image.tag=" + tagFile)},
+			want:    "nginx:1.22.0",
+		},
+		{
+			name:    "repeated values uses the last one",
+			options: []helmut.Option{helmut.WithValues(repositoryFile), helmut.WithValues(valuesFile)},
+			want:    "nginx:1.20.0",
+		},
+		{
+			name:    "later values file takes precedence",
+			options: []helmut.Option{helmut.WithValues(valuesFile), helmut.WithValues(newTagFile)},
+			want:    "nginx:1.22.0",
+		},
+		{
+			name:    "repeated set uses the last one",
+			options: []helmut.Option{helmut.WithSet("image.repository=httpd"), helmut.WithSet("image.tag=1.21.0")},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name:    "later set takes precedence",
+			options: []helmut.Option{helmut.WithSet("image.tag=1.21.0"), helmut.WithSet("image.tag=1.22.0")},
+			want:    "nginx:1.22.0",
+		},
+		{
+			name:    "repeated set-string uses the last one",
+			options: []helmut.Option{helmut.WithSetString("image.repository=httpd"), helmut.WithSetString("image.tag=1.21.0")},
+			want:    "nginx:1.21.0",
+		},
+		{
+			name: "repeated set-file uses the last one",
+			options: []helmut.Option{
+				helmut.WithSetFile("image.repository=" + repositoryNameFile),
+				helmut.WithSetFile("image.tag=" + tagFile),
+			},
+			want: "nginx:1.21.0",
+		},
+		{
+			name: "values map takes precedence over set-literal",
+			options: []helmut.Option{
+				helmut.WithValuesMap(map[string]interface{}{"image": map[string]interface{}{"tag": "1.23.0"}}),
+				helmut.WithSetLiteral("image.tag=1.22.0"),
+			},
+			want: "nginx:1.23.0",
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, filepath.Join("testdata", chartName), tt.options...)
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			assert.Contains(t, manifests, newDeployment(chartName, releaseName, withDeploymentImage(tt.want)),
+				assert.WithIgnoreHelmManagedLabels())
+		})
+	}
+}
+
 func newServiceAccount(
 	chartName, releaseName string,
 	options ...func(account *corev1.ServiceAccount),
@@ -228,7 +376,7 @@ func newDeployment(chartName, releaseName string, options ...func(*appsv1.Deploy
 								},
 							},
 							LivenessProbe: &corev1.Probe{
-								Handler: corev1.Handler{
+								ProbeHandler: corev1.ProbeHandler{
 									HTTPGet: &corev1.HTTPGetAction{
 										Path: "/",
 										Port: intstr.IntOrString{
@@ -239,7 +387,7 @@ func newDeployment(chartName, releaseName string, options ...func(*appsv1.Deploy
 								},
 							},
 							ReadinessProbe: &corev1.Probe{
-								Handler: corev1.Handler{
+								ProbeHandler: corev1.ProbeHandler{
 									HTTPGet: &corev1.HTTPGetAction{
 										Path: "/",
 										Port: intstr.IntOrString{
@@ -269,12 +417,32 @@ func withDeploymentReplicas(replicas int32) func(*appsv1.Deployment) {
 	}
 }
 
+func withDeploymentImage(image string) func(*appsv1.Deployment) {
+	return func(deploy *appsv1.Deployment) {
+		deploy.Spec.Template.Spec.Containers[0].Image = image
+	}
+}
+
 func withDeploymentVolumes(volumes []corev1.Volume) func(*appsv1.Deployment) {
 	return func(deploy *appsv1.Deployment) {
 		deploy.Spec.Template.Spec.Volumes = volumes
 	}
 }
 
+func newRenderer(t testing.TB, options ...helmut.RendererOption) *helmut.Renderer {
+	t.Helper()
+
+	r := helmut.New(options...)
+
+	t.Cleanup(func() {
+		if err := r.Close(); err != nil {
+			t.Errorf("failed to close renderer: %s", err)
+		}
+	})
+
+	return r
+}
+
 func createTempValuesFile(t *testing.T, data []byte) string {
 	t.Helper()
 
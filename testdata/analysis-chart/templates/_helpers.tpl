@@ -0,0 +1,3 @@
+{{- define "analysis-chart.name" -}}
+{{ .Values.name }}
+{{- end }}
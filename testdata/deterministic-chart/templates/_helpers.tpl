@@ -0,0 +1,3 @@
+{{- define "deterministic-chart.token" -}}
+{{ randAlpha 8 | lower }}
+{{- end }}
@@ -0,0 +1,3 @@
+{{- define "upgrade-chart.release" -}}
+{{ .Release.Revision }}/{{ .Release.IsUpgrade }}/{{ .Values.image }}
+{{- end }}
@@ -0,0 +1,442 @@
+package helmut
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// ValueSourceType is the type of the source of a value.
+type ValueSourceType string
+
+const (
+	// ValueSourceChart is the default values of the chart.
+	ValueSourceChart ValueSourceType = "chart"
+	// ValueSourceSubchart is the default values of a subchart.
+	ValueSourceSubchart ValueSourceType = "subchart"
+	// ValueSourcePreviousRelease is the values of the previous release specified by WithUpgrade.
+	ValueSourcePreviousRelease ValueSourceType = "previous-release"
+	// ValueSourceFile is a values file specified by WithValues.
+	ValueSourceFile ValueSourceType = "file"
+	// ValueSourceSet is a value specified by WithSet.
+	ValueSourceSet ValueSourceType = "set"
+	// ValueSourceSetString is a value specified by WithSetString.
+	ValueSourceSetString ValueSourceType = "set-string"
+	// ValueSourceSetFile is a value specified by WithSetFile.
+	ValueSourceSetFile ValueSourceType = "set-file"
+	// ValueSourceSetJSON is a value specified by WithSetJSON.
+	ValueSourceSetJSON ValueSourceType = "set-json"
+	// ValueSourceSetLiteral is a value specified by WithSetLiteral.
+	ValueSourceSetLiteral ValueSourceType = "set-literal"
+	// ValueSourceValuesMap is a map specified by WithValuesMap.
+	ValueSourceValuesMap ValueSourceType = "values-map"
+	// ValueSourceUnknown is used if the source cannot be determined, such as values imported from a subchart.
+	ValueSourceUnknown ValueSourceType = "unknown"
+)
+
+// ValueSource is the source of a value.
+type ValueSource struct {
+	Type ValueSourceType
+
+	// Name identifies the source in the type.
+	// It is the chart name for the default values, the path or URL for a values file,
+	// the expression for set options (e.g. "image.tag=1.21.0") and the index for a values map.
+	Name string
+}
+
+// String returns the type and the name of the source.
+func (s ValueSource) String() string {
+	if len(s.Name) == 0 {
+		return string(s.Type)
+	}
+
+	return string(s.Type) + " " + s.Name
+}
+
+// TracedValue is a leaf of the computed values with its source.
+// Lists are not merged by helm, so a list is a leaf.
+type TracedValue struct {
+	// Path is the path to the value, which can be passed to Values.Get.
+	Path string
+
+	Value interface{}
+
+	// Source is the source that the value came from.
+	Source ValueSource
+
+	// Coercion describes the type change from the value overridden by the source, such as "string -> int64".
+	// It is empty if the type is not changed or no value is overridden.
+	Coercion string
+}
+
+// ValuesTrace records the source of each leaf of the computed values.
+type ValuesTrace struct {
+	values []TracedValue
+}
+
+// Get returns the traced value at the path.
+// The ok result indicates whether the value was found.
+func (t *ValuesTrace) Get(path string) (TracedValue, bool) {
+	for _, v := range t.values {
+		if v.Path == path {
+			return v, true
+		}
+	}
+
+	return TracedValue{}, false
+}
+
+// Values returns the traced values sorted by path.
+func (t *ValuesTrace) Values() []TracedValue {
+	return append([]TracedValue(nil), t.values...)
+}
+
+// String returns the traced values, one per line.
+//
+// e.g.
+//
+//  image.repository = "nginx" (chart test-chart)
+//  image.tag = 1 (set image.tag=1) [string -> int64]
+//
+func (t *ValuesTrace) String() string {
+	var b strings.Builder
+
+	for _, v := range t.values {
+		fmt.Fprintf(&b, "%s = %s (%s)", v.Path, formatTracedValue(v.Value), v.Source)
+
+		if len(v.Coercion) != 0 {
+			fmt.Fprintf(&b, " [%s]", v.Coercion)
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// formatTracedValue formats the value so that strings are distinguished from other types.
+func formatTracedValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+
+	if value == nil {
+		return "null"
+	}
+
+	return fmt.Sprint(value)
+}
+
+// valuesLayer is the values of a source, in the coordinates of the computed values.
+type valuesLayer struct {
+	source ValueSource
+	values map[string]interface{}
+}
+
+// traceValues returns the trace of the computed values.
+// The chart must be the chart of the release, whose dependencies have been processed.
+func traceValues(c *chart.Chart, opts *option, computed Values, providers getter.Providers) (*ValuesTrace, error) {
+	layers := chartValuesLayers(c, nil, true)
+
+	userLayers, err := userValuesLayers(opts, providers)
+	if err != nil {
+		return nil, err
+	}
+
+	if reusesPreviousValues(opts, userLayers) {
+		layers = append(layers, valuesLayer{
+			source: ValueSource{Type: ValueSourcePreviousRelease},
+			values: opts.upgrade.previous.Values,
+		})
+	}
+
+	layers = append(layers, userLayers...)
+
+	trace := &ValuesTrace{}
+
+	walkLeaves(nil, computed, func(path []string, value interface{}) {
+		trace.values = append(trace.values, traceLeaf(layers, path, value))
+	})
+
+	sort.Slice(trace.values, func(i, j int) bool {
+		return trace.values[i].Path < trace.values[j].Path
+	})
+
+	return trace, nil
+}
+
+// chartValuesLayers returns the default values of the chart and its subcharts in the order of precedence,
+// that is, the subcharts first and the parent chart last.
+func chartValuesLayers(c *chart.Chart, path []string, root bool) []valuesLayer {
+	var layers []valuesLayer
+
+	for _, dep := range c.Dependencies() {
+		layers = append(layers, chartValuesLayers(dep, append(append([]string(nil), path...), dep.Name()), false)...)
+	}
+
+	source := ValueSource{Type: ValueSourceChart, Name: c.Name()}
+	if !root {
+		source.Type = ValueSourceSubchart
+	}
+
+	values := chartDefaultValues(c)
+
+	for i := len(path) - 1; i >= 0; i-- {
+		values = map[string]interface{}{path[i]: values}
+	}
+
+	return append(layers, valuesLayer{source: source, values: values})
+}
+
+// chartDefaultValues returns the values in the values.yaml of the chart.
+// The values of the chart are not used, because helm merges the values of the subcharts into them when rendering.
+func chartDefaultValues(c *chart.Chart) map[string]interface{} {
+	for _, f := range c.Raw {
+		if f.Name != chartutil.ValuesfileName {
+			continue
+		}
+
+		values, err := chartutil.ReadValues(f.Data)
+		if err != nil {
+			break
+		}
+
+		return values
+	}
+
+	return c.Values
+}
+
+// reusesPreviousValues returns true if the values of the previous release are merged by the upgrade rules of helm.
+func reusesPreviousValues(opts *option, userLayers []valuesLayer) bool {
+	upgrade := opts.upgrade
+
+	switch {
+	case upgrade.previous == nil || upgrade.resetValues:
+		return false
+	case upgrade.reuseValues || upgrade.resetThenReuseValues:
+		return true
+	}
+
+	// The values of the previous release are reused only if no values are specified.
+	for _, layer := range userLayers {
+		if len(layer.values) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// userValuesLayers returns the values of the value options in the order of precedence used by helm.
+func userValuesLayers(opts *option, providers getter.Providers) ([]valuesLayer, error) {
+	var layers []valuesLayer
+
+	add := func(sourceType ValueSourceType, name string, valueOpts *values.Options) error {
+		v, err := valueOpts.MergeValues(providers)
+		if err != nil {
+			return fmt.Errorf("failed to trace %s: %w", ValueSource{Type: sourceType, Name: name}, err)
+		}
+
+		layers = append(layers, valuesLayer{source: ValueSource{Type: sourceType, Name: name}, values: v})
+
+		return nil
+	}
+
+	for _, file := range opts.valueFiles {
+		if err := add(ValueSourceFile, file, &values.Options{ValueFiles: []string{file}}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range opts.jsonValues {
+		if err := add(ValueSourceSetJSON, value, &values.Options{JSONValues: []string{value}}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range opts.values {
+		if err := add(ValueSourceSet, value, &values.Options{Values: []string{value}}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range opts.stringValues {
+		if err := add(ValueSourceSetString, value, &values.Options{StringValues: []string{value}}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range opts.fileValues {
+		if err := add(ValueSourceSetFile, value, &values.Options{FileValues: []string{value}}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range opts.literalValues {
+		if err := add(ValueSourceSetLiteral, value, &values.Options{LiteralValues: []string{value}}); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, m := range opts.valuesMaps {
+		layers = append(layers, valuesLayer{
+			source: ValueSource{Type: ValueSourceValuesMap, Name: strconv.Itoa(i)},
+			values: m,
+		})
+	}
+
+	return layers, nil
+}
+
+// walkLeaves calls fn for each leaf of the values.
+// A list and an empty map are leaves.
+func walkLeaves(path []string, value interface{}, fn func(path []string, value interface{})) {
+	var m map[string]interface{}
+
+	switch v := value.(type) {
+	case Values:
+		m = v
+	case map[string]interface{}:
+		m = v
+	}
+
+	if m == nil || (len(m) == 0 && len(path) != 0) {
+		fn(path, value)
+
+		return
+	}
+
+	for k, v := range m {
+		walkLeaves(append(append([]string(nil), path...), k), v, fn)
+	}
+}
+
+// traceLeaf finds the source of the leaf.
+// The source is the layer with the highest precedence that has the same value at the path.
+// If no layer has the same value, such as a list modified by "--set list[0]=value",
+// the layer with the highest precedence that has the path is used.
+func traceLeaf(layers []valuesLayer, path []string, value interface{}) TracedValue {
+	traced := TracedValue{
+		Path:   formatValuesPath(path),
+		Value:  value,
+		Source: ValueSource{Type: ValueSourceUnknown},
+	}
+
+	winner := -1
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		if v, ok := lookupLayer(layers[i].values, path); ok && reflect.DeepEqual(v, value) {
+			winner = i
+
+			break
+		}
+	}
+
+	if winner < 0 {
+		for i := len(layers) - 1; i >= 0; i-- {
+			if _, ok := lookupLayer(layers[i].values, path); ok {
+				winner = i
+
+				break
+			}
+		}
+	}
+
+	if winner < 0 {
+		return traced
+	}
+
+	traced.Source = layers[winner].source
+
+	// Note the type change from the value overridden by the source.
+	for i := winner - 1; i >= 0; i-- {
+		overridden, ok := lookupLayer(layers[i].values, path)
+		if !ok {
+			continue
+		}
+
+		if from, to := valueTypeName(overridden), valueTypeName(value); from != to {
+			traced.Coercion = from + " -> " + to
+		}
+
+		break
+	}
+
+	return traced
+}
+
+// lookupLayer returns the value at the path in the values of a layer.
+// The global values are also looked up from the parent charts,
+// because helm copies them to the subcharts and the values of the parent take precedence.
+func lookupLayer(values map[string]interface{}, path []string) (interface{}, bool) {
+	for i, name := range path {
+		if name != "global" {
+			continue
+		}
+
+		// The parent charts first, from the root.
+		for j := 0; j <= i; j++ {
+			candidate := append(append([]string(nil), path[:j]...), path[i:]...)
+
+			if v, ok := lookupPath(values, candidate); ok {
+				return v, true
+			}
+		}
+
+		break
+	}
+
+	return lookupPath(values, path)
+}
+
+// lookupPath returns the value at the path of the field names.
+func lookupPath(values map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = values
+
+	for _, name := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = m[name]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// formatValuesPath returns the path of the field names in the format of Values.Get.
+func formatValuesPath(path []string) string {
+	var s string
+
+	for _, name := range path {
+		s = joinFieldPath(s, name)
+	}
+
+	return s
+}
+
+// valueTypeName returns the name of the type of the value.
+func valueTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	default:
+		return reflect.TypeOf(value).String()
+	}
+}
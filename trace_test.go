@@ -0,0 +1,270 @@
+package helmut_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestManifestsValuesTrace(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	valuesFile := createTempValuesFile(t, []byte("image:\n  tag: 1.20.0\nnameOverride: bar\n"))
+	noteFile := createTempValuesFile(t, []byte("hello"))
+
+	umbrellaOptions := []helmut.Option{
+		helmut.WithDependencyBuild(),
+		helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart"),
+	}
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+		path    string
+		want    helmut.TracedValue
+	}{
+		{
+			name:  "chart default",
+			chart: "testdata/test-chart",
+			path:  "image.repository",
+			want: helmut.TracedValue{
+				Path:   "image.repository",
+				Value:  "nginx",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceChart, Name: "test-chart"},
+			},
+		},
+		{
+			name:    "values file",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithValues(valuesFile)},
+			path:    "nameOverride",
+			want: helmut.TracedValue{
+				Path:   "nameOverride",
+				Value:  "bar",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceFile, Name: valuesFile},
+			},
+		},
+		{
+			name:    "set takes precedence over values file",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSet("image.tag=1.21.0"), helmut.WithValues(valuesFile)},
+			path:    "image.tag",
+			want: helmut.TracedValue{
+				Path:   "image.tag",
+				Value:  "1.21.0",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceSet, Name: "image.tag=1.21.0"},
+			},
+		},
+		{
+			name:    "set with type coercion",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSet("replicaCount=3")},
+			path:    "replicaCount",
+			want: helmut.TracedValue{
+				Path:     "replicaCount",
+				Value:    int64(3),
+				Source:   helmut.ValueSource{Type: helmut.ValueSourceSet, Name: "replicaCount=3"},
+				Coercion: "float64 -> int64",
+			},
+		},
+		{
+			name:    "set-string with type coercion",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSetString("replicaCount=3")},
+			path:    "replicaCount",
+			want: helmut.TracedValue{
+				Path:     "replicaCount",
+				Value:    "3",
+				Source:   helmut.ValueSource{Type: helmut.ValueSourceSetString, Name: "replicaCount=3"},
+				Coercion: "float64 -> string",
+			},
+		},
+		{
+			name:    "set-file",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSetFile("podAnnotations.note=" + noteFile)},
+			path:    "podAnnotations.note",
+			want: helmut.TracedValue{
+				Path:   "podAnnotations.note",
+				Value:  "hello",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceSetFile, Name: "podAnnotations.note=" + noteFile},
+			},
+		},
+		{
+			name:    "set-json",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSetJSON(`image={"tag":"1.21.0"}`)},
+			path:    "image.tag",
+			want: helmut.TracedValue{
+				Path:   "image.tag",
+				Value:  "1.21.0",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceSetJSON, Name: `image={"tag":"1.21.0"}`},
+			},
+		},
+		{
+			name:    "set-literal takes precedence over set",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSetLiteral("image.tag=1,2"), helmut.WithSet("image.tag=1.21.0")},
+			path:    "image.tag",
+			want: helmut.TracedValue{
+				Path:   "image.tag",
+				Value:  "1,2",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceSetLiteral, Name: "image.tag=1,2"},
+			},
+		},
+		{
+			name:    "values map takes precedence over set",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithValuesMap(map[string]interface{}{"replicaCount": 5}), helmut.WithSet("replicaCount=3")},
+			path:    "replicaCount",
+			want: helmut.TracedValue{
+				Path:     "replicaCount",
+				Value:    5,
+				Source:   helmut.ValueSource{Type: helmut.ValueSourceValuesMap, Name: "0"},
+				Coercion: "int64 -> int",
+			},
+		},
+		{
+			name:    "list modified by set",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSet("imagePullSecrets[0].name=secret")},
+			path:    "imagePullSecrets",
+			want: helmut.TracedValue{
+				Path:   "imagePullSecrets",
+				Value:  []interface{}{map[string]interface{}{"name": "secret"}},
+				Source: helmut.ValueSource{Type: helmut.ValueSourceSet, Name: "imagePullSecrets[0].name=secret"},
+			},
+		},
+		{
+			name:    "subchart default",
+			chart:   "testdata/umbrella-chart",
+			options: umbrellaOptions,
+			path:    "test-chart.image.repository",
+			want: helmut.TracedValue{
+				Path:   "test-chart.image.repository",
+				Value:  "nginx",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceSubchart, Name: "test-chart"},
+			},
+		},
+		{
+			name:    "subchart value overridden by parent",
+			chart:   "testdata/umbrella-chart",
+			options: umbrellaOptions,
+			path:    "test-chart.replicaCount",
+			want: helmut.TracedValue{
+				Path:   "test-chart.replicaCount",
+				Value:  float64(2),
+				Source: helmut.ValueSource{Type: helmut.ValueSourceChart, Name: "umbrella-chart"},
+			},
+		},
+		{
+			name:    "global value propagated to subchart",
+			chart:   "testdata/umbrella-chart",
+			options: umbrellaOptions,
+			path:    "sub-chart.global.environment",
+			want: helmut.TracedValue{
+				Path:   "sub-chart.global.environment",
+				Value:  "test",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceChart, Name: "umbrella-chart"},
+			},
+		},
+		{
+			name:    "previous release",
+			chart:   "testdata/upgrade-chart",
+			options: []helmut.Option{helmut.WithUpgrade(helmut.PreviousRelease{Values: map[string]interface{}{"color": "blue"}})},
+			path:    "color",
+			want: helmut.TracedValue{
+				Path:   "color",
+				Value:  "blue",
+				Source: helmut.ValueSource{Type: helmut.ValueSourcePreviousRelease},
+			},
+		},
+		{
+			name:  "previous release reset by set",
+			chart: "testdata/upgrade-chart",
+			options: []helmut.Option{
+				helmut.WithUpgrade(helmut.PreviousRelease{Values: map[string]interface{}{"color": "blue"}}),
+				helmut.WithSet("image=nginx:2.0"),
+			},
+			path: "color",
+			want: helmut.TracedValue{
+				Path:   "color",
+				Value:  "green",
+				Source: helmut.ValueSource{Type: helmut.ValueSourceChart, Name: "upgrade-chart"},
+			},
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := append([]helmut.Option{helmut.WithValuesTrace()}, tt.options...)
+
+			manifests, err := r.RenderTemplates(releaseName, tt.chart, options...)
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			got, ok := manifests.ValuesTrace().Get(tt.path)
+			if !ok {
+				t.Fatalf("trace of %s not found:\n%s", tt.path, manifests.ValuesTrace())
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("traced value mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManifestsValuesTraceString(t *testing.T) {
+	t.Parallel()
+
+	r := newRenderer(t)
+
+	manifests, err := r.RenderTemplates("foo", "testdata/test-chart",
+		helmut.WithValuesTrace(), helmut.WithSet("replicaCount=3"))
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	got := manifests.ValuesTrace().String()
+
+	for _, want := range []string{
+		"image.repository = \"nginx\" (chart test-chart)\n",
+		"replicaCount = 3 (set replicaCount=3) [float64 -> int64]\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trace does not contain %q:\n%s", want, got)
+		}
+	}
+
+	if len(manifests.ValuesTrace().Values()) == 0 {
+		t.Error("got no traced values")
+	}
+}
+
+func TestManifestsValuesTraceDisabled(t *testing.T) {
+	t.Parallel()
+
+	r := newRenderer(t)
+
+	manifests, err := r.RenderTemplates("foo", "testdata/test-chart")
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	if got := manifests.ValuesTrace().Values(); len(got) != 0 {
+		t.Errorf("got traced values without WithValuesTrace: %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package helmut
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// PreviousRelease is the state of the release before the upgrade, that is specified by WithUpgrade.
+type PreviousRelease struct {
+	// Values are the values supplied by the user to the previous release,
+	// such as the values of "--values" and "--set".
+	Values map[string]interface{}
+
+	// Manifests are the manifests of the previous release. Optional.
+	Manifests *Manifests
+
+	// Revision is the revision of the previous release.
+	// If not specified, 1 is used, so the upgrade is rendered as revision 2.
+	Revision int
+
+	// Chart is the path to the chart directory or archive of the previous release.
+	// The default values of the previous chart are used by WithReuseValues.
+	// If not specified, the chart being rendered is used.
+	Chart string
+}
+
+// upgradeOption stores the upgrade options.
+type upgradeOption struct {
+	previous             *PreviousRelease
+	reuseValues          bool
+	resetValues          bool
+	resetThenReuseValues bool
+}
+
+// upgrade will execute the equivalent of the "helm upgrade --dry-run" command against the previous release
+// stored in memory and return the upgraded release.
+func (r *Renderer) upgrade(
+	name string,
+	chartRequested *chart.Chart,
+	values map[string]interface{},
+	opts *option,
+	postRenderer postrender.PostRenderer,
+) (*release.Release, error) {
+	previous, err := r.newPreviousRelease(name, chartRequested, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	mem := driver.NewMemory()
+	mem.SetNamespace(opts.namespace)
+
+	releases := storage.Init(mem)
+
+	if err := releases.Create(previous); err != nil {
+		return nil, fmt.Errorf("failed to store the previous release: %w", err)
+	}
+
+	capabilities := chartutil.DefaultCapabilities.Copy()
+	capabilities.APIVersions = append(capabilities.APIVersions, opts.apiVersions...)
+
+	client := action.NewUpgrade(&action.Configuration{
+		Releases:     releases,
+		KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+		Capabilities: capabilities,
+		Log:          func(string, ...interface{}) {},
+	})
+
+	client.DryRun = true
+	client.Namespace = opts.namespace
+	client.DisableOpenAPIValidation = true
+	client.SkipSchemaValidation = true // Validated by helmut to report the violations as ValuesSchemaError
+	client.ReuseValues = opts.upgrade.reuseValues
+	client.ResetValues = opts.upgrade.resetValues
+	client.ResetThenReuseValues = opts.upgrade.resetThenReuseValues
+	client.PostRenderer = postRenderer
+
+	// Helm does not render the CRDs on upgrade, so they are added like an installation with IncludeCRDs.
+	if opts.includeCRDs {
+		client.PostRenderer = &crdPostRenderer{chart: chartRequested, next: postRenderer}
+	}
+
+	// The requested chart is modified by the upgrade, so a copy is kept to process the release on failure.
+	pristine, err := copyChart(chartRequested)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraded, err := client.Run(name, chartRequested, values)
+	if err != nil {
+		// Like an installation, the release is returned with the error,
+		// so that the computed values of the upgrade can be validated against the schema.
+		processed, processErr := r.processRelease(name, pristine, values, opts)
+		if processErr != nil {
+			processed = nil
+		}
+
+		return processed, fmt.Errorf("failed to upgrade release: %w", err)
+	}
+
+	return upgraded, nil
+}
+
+// crdPostRenderer is a post-renderer that adds the CRDs of the chart before the manifests
+// in the same format as an installation with IncludeCRDs, and then runs the next post-renderer.
+// The CRDs are read when the post-renderer runs, so that the CRDs of the disabled subcharts are excluded.
+type crdPostRenderer struct {
+	chart *chart.Chart
+	next  postrender.PostRenderer
+}
+
+// Run implements the postrender.PostRenderer interface.
+func (p *crdPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	b := &bytes.Buffer{}
+
+	for _, crd := range p.chart.CRDObjects() {
+		fmt.Fprintf(b, "---\n# Source: %s\n%s\n", crd.Filename, string(crd.File.Data))
+	}
+
+	b.Write(renderedManifests.Bytes())
+
+	if p.next == nil {
+		return b, nil
+	}
+
+	return p.next.Run(b)
+}
+
+// newPreviousRelease creates and returns the previous release specified by WithUpgrade.
+// It must be called before rendering, because rendering modifies the requested chart.
+func (r *Renderer) newPreviousRelease(name string, chartRequested *chart.Chart, opts *option) (*release.Release, error) {
+	previous := opts.upgrade.previous
+
+	var (
+		previousChart *chart.Chart
+		err           error
+	)
+
+	if len(previous.Chart) != 0 {
+		previousChart, err = r.charts.Load(previous.Chart)
+	} else {
+		previousChart, err = copyChart(chartRequested)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the chart of the previous release: %w", err)
+	}
+
+	var manifest string
+
+	if previous.Manifests != nil {
+		data, err := marshalManifests(previous.Manifests)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the manifests of the previous release: %w", err)
+		}
+
+		manifest = string(data)
+	}
+
+	values := previous.Values
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	revision := previous.Revision
+	if revision == 0 {
+		revision = 1
+	}
+
+	return &release.Release{
+		Name:      name,
+		Namespace: opts.namespace,
+		Chart:     previousChart,
+		Config:    values,
+		Manifest:  manifest,
+		Version:   revision,
+		Info:      &release.Info{Status: release.StatusDeployed},
+	}, nil
+}
+
+// upgradeValues returns the values of the upgraded release from the values of the previous release and the options.
+// If WithReuseValues is specified, the values of the chart are replaced with the values of the previous release.
+// This is equivalent to action.Upgrade.reuseValues.
+func upgradeValues(
+	c *chart.Chart,
+	previous *release.Release,
+	values map[string]interface{},
+	opts *option,
+) (map[string]interface{}, error) {
+	switch {
+	case opts.upgrade.resetValues:
+		return values, nil
+	case opts.upgrade.reuseValues:
+		previousValues, err := chartutil.CoalesceValues(previous.Chart, previous.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild old values: %w", err)
+		}
+
+		c.Values = previousValues
+
+		return chartutil.CoalesceTables(values, previous.Config), nil
+	case opts.upgrade.resetThenReuseValues:
+		return chartutil.CoalesceTables(values, previous.Config), nil
+	case len(values) == 0 && len(previous.Config) > 0:
+		return previous.Config, nil
+	default:
+		return values, nil
+	}
+}
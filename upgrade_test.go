@@ -0,0 +1,204 @@
+package helmut_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/d-kuro/helmut/assert"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRenderTemplatesWithUpgrade(t *testing.T) {
+	t.Parallel()
+
+	const (
+		releaseName = "foo"
+		chartPath   = "testdata/upgrade-chart"
+	)
+
+	previousValues := map[string]interface{}{"image": "nginx:1.1"}
+
+	// The previous chart has a different default value.
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		t.Fatalf("failed to load chart: %s", err)
+	}
+
+	for _, f := range c.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			f.Data = []byte("image: nginx:1.0\ncolor: blue\n")
+		}
+	}
+
+	previousChart, err := chartutil.Save(c, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to save chart: %s", err)
+	}
+
+	previous := helmut.PreviousRelease{Values: previousValues, Chart: previousChart}
+
+	tests := []struct {
+		name    string
+		options []helmut.Option
+		want    map[string]string
+	}{
+		{
+			name: "install",
+			want: map[string]string{"isUpgrade": "false", "revision": "1", "image": "nginx:1.0", "color": "green"},
+		},
+		{
+			name:    "upgrade reuses the previous values if no values are specified",
+			options: []helmut.Option{helmut.WithUpgrade(previous)},
+			want:    map[string]string{"isUpgrade": "true", "revision": "2", "image": "nginx:1.1", "color": "green"},
+		},
+		{
+			name:    "upgrade ignores the previous values if values are specified",
+			options: []helmut.Option{helmut.WithUpgrade(previous), helmut.WithSet("color=red")},
+			want:    map[string]string{"isUpgrade": "true", "revision": "2", "image": "nginx:1.0", "color": "red"},
+		},
+		{
+			name:    "reuse values",
+			options: []helmut.Option{helmut.WithUpgrade(previous), helmut.WithReuseValues()},
+			want:    map[string]string{"isUpgrade": "true", "revision": "2", "image": "nginx:1.1", "color": "blue"},
+		},
+		{
+			name:    "reuse values with values",
+			options: []helmut.Option{helmut.WithUpgrade(previous), helmut.WithReuseValues(), helmut.WithSet("color=red")},
+			want:    map[string]string{"isUpgrade": "true", "revision": "2", "image": "nginx:1.1", "color": "red"},
+		},
+		{
+			name:    "reset then reuse values",
+			options: []helmut.Option{helmut.WithUpgrade(previous), helmut.WithResetThenReuseValues()},
+			want:    map[string]string{"isUpgrade": "true", "revision": "2", "image": "nginx:1.1", "color": "green"},
+		},
+		{
+			name:    "reset values",
+			options: []helmut.Option{helmut.WithUpgrade(previous), helmut.WithResetValues()},
+			want:    map[string]string{"isUpgrade": "true", "revision": "2", "image": "nginx:1.0", "color": "green"},
+		},
+		{
+			name:    "previous revision",
+			options: []helmut.Option{helmut.WithUpgrade(helmut.PreviousRelease{Revision: 5})},
+			want:    map[string]string{"isUpgrade": "true", "revision": "6", "image": "nginx:1.0", "color": "green"},
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, chartPath, tt.options...)
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			assert.Contains(t, manifests, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-upgrade-chart"},
+				Data:       tt.want,
+			})
+		})
+	}
+}
+
+func TestRenderTemplatesWithUpgradeManifests(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	r := newRenderer(t)
+
+	chartPath := filepath.Join("testdata", "test-chart")
+
+	previous, err := r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	upgraded, err := r.RenderTemplates(releaseName, chartPath,
+		helmut.WithUpgrade(helmut.PreviousRelease{Manifests: previous}), helmut.WithSet("replicaCount=2"))
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	delta, err := helmut.DiffManifests(previous, upgraded)
+	if err != nil {
+		t.Fatalf("failed to diff manifests: %s", err)
+	}
+
+	if len(delta.Added) != 0 || len(delta.Removed) != 0 || len(delta.Changed) != 1 {
+		t.Errorf("got delta %+v, want only the deployment changed", delta)
+	}
+}
+
+func TestRenderTemplatesWithUpgradeIncludeCRDs(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	chartPath := filepath.Join("testdata", "crd-chart")
+	crdKey := helmut.NewObjectKey("", "widgets.example.com",
+		schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+
+	r := newRenderer(t)
+
+	previous, err := r.RenderTemplates(releaseName, chartPath)
+	if err != nil {
+		t.Fatalf("failed to render templates: %s", err)
+	}
+
+	upgrade := helmut.WithUpgrade(helmut.PreviousRelease{Manifests: previous})
+
+	tests := []struct {
+		name    string
+		options []helmut.Option
+		want    bool
+	}{
+		{
+			name:    "without including crds",
+			options: []helmut.Option{upgrade},
+			want:    false,
+		},
+		{
+			name:    "include crds",
+			options: []helmut.Option{upgrade, helmut.WithIncludeCRDs()},
+			want:    true,
+		},
+		{
+			name: "include crds with a post-renderer",
+			options: []helmut.Option{upgrade, helmut.WithIncludeCRDs(),
+				helmut.WithPostRendererFunc(func(*helmut.Manifests) error { return nil })},
+			want: true,
+		},
+		{
+			name:    "show only crds",
+			options: []helmut.Option{upgrade, helmut.WithIncludeCRDs(), helmut.WithShowOnly("crds/widget.yaml")},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, chartPath, tt.options...)
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			if _, got := manifests.Load(crdKey); got != tt.want {
+				t.Errorf("got CRD included %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
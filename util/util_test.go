@@ -7,7 +7,7 @@ import (
 	"github.com/d-kuro/helmut/util"
 	"github.com/google/go-cmp/cmp"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
-	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -376,15 +376,15 @@ func TestSetGVKIfDoesNotExist(t *testing.T) {
 			wantSet: true,
 		},
 		{
-			name: "hpa v2beta2",
-			object: &autoscalingv2beta2.HorizontalPodAutoscaler{
+			name: "hpa v2",
+			object: &autoscalingv2.HorizontalPodAutoscaler{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "test",
 				},
 			},
 			want: schema.GroupVersionKind{
 				Group:   "autoscaling",
-				Version: "v2beta2",
+				Version: "v2",
 				Kind:    "HorizontalPodAutoscaler",
 			},
 			wantSet: true,
@@ -0,0 +1,146 @@
+package helmut
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mergeMaps merges map b into map a and returns the result.
+// The values in b take precedence, and nested maps are merged recursively.
+// Neither a nor b is modified, and nested maps in b are copied.
+func mergeMaps(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+
+	for k, v := range a {
+		out[k] = v
+	}
+
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeMaps(bv, v)
+			} else {
+				out[k] = mergeMaps(nil, v)
+			}
+
+			continue
+		}
+
+		out[k] = v
+	}
+
+	return out
+}
+
+// Values is the computed values used to render the manifests.
+// The default values of the chart and its subcharts, the global values
+// and the values specified by the options are coalesced, as seen by the templates through ".Values".
+// The values of a subchart are stored under the name of the subchart.
+type Values map[string]interface{}
+
+// Get returns the value at the path, or nil if no value is present.
+// The ok result indicates whether the value was found.
+//
+// The path is the field names separated by ".", with indexes of lists in brackets.
+// Field names that contain "." are quoted in brackets.
+// e.g. "image.tag", "subchart.global.registry", "ingress.hosts[0].host", `podAnnotations["example.com/key"]`
+func (v Values) Get(path string) (interface{}, bool) {
+	elements, err := parseValuesPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var current interface{} = map[string]interface{}(v)
+
+	for _, element := range elements {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			if element.index >= 0 {
+				return nil, false
+			}
+
+			next, ok := value[element.name]
+			if !ok {
+				return nil, false
+			}
+
+			current = next
+		case []interface{}:
+			if element.index < 0 || element.index >= len(value) {
+				return nil, false
+			}
+
+			current = value[element.index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// valuesPathElement is an element of the values path, which is a field name or an index of a list.
+type valuesPathElement struct {
+	name  string
+	index int
+}
+
+// parseValuesPath parses the values path into the elements.
+func parseValuesPath(path string) ([]valuesPathElement, error) {
+	var elements []valuesPathElement
+
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in %q", path)
+			}
+
+			// A quoted field name may contain "]".
+			if i+1 < len(path) && path[i+1] == '"' {
+				quoted, err := strconv.QuotedPrefix(path[i+1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quoted field name in %q: %w", path, err)
+				}
+
+				name, _ := strconv.Unquote(quoted)
+				end = 1 + len(quoted)
+
+				if i+end >= len(path) || path[i+end] != ']' {
+					return nil, fmt.Errorf("unclosed bracket in %q", path)
+				}
+
+				elements = append(elements, valuesPathElement{name: name, index: -1})
+				i += end + 1
+
+				continue
+			}
+
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in %q", path)
+			}
+
+			elements = append(elements, valuesPathElement{index: index})
+			i += end + 1
+		case path[i] == '.':
+			if i == 0 || i+1 == len(path) || path[i+1] == '.' {
+				return nil, fmt.Errorf("empty field name in %q", path)
+			}
+
+			i++
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+
+			elements = append(elements, valuesPathElement{name: path[i : i+end], index: -1})
+			i += end
+		}
+	}
+
+	return elements, nil
+}
@@ -0,0 +1,138 @@
+package helmut_test
+
+import (
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestManifestsValues(t *testing.T) {
+	t.Parallel()
+
+	const releaseName = "foo"
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+		path    string
+		want    interface{}
+		wantOK  bool
+	}{
+		{
+			name:   "chart default",
+			chart:  "testdata/test-chart",
+			path:   "image.repository",
+			want:   "nginx",
+			wantOK: true,
+		},
+		{
+			name:  "set takes precedence over values file",
+			chart: "testdata/test-chart",
+			options: []helmut.Option{
+				helmut.WithValuesMap(map[string]interface{}{"replicaCount": 5}),
+				helmut.WithSet("image.tag=1.21.0"),
+				helmut.WithValues(createTempValuesFile(t, []byte("image:\n  tag: 1.20.0\n"))),
+			},
+			path:   "image.tag",
+			want:   "1.21.0",
+			wantOK: true,
+		},
+		{
+			name:    "values map takes precedence over set",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithValuesMap(map[string]interface{}{"replicaCount": 5}), helmut.WithSet("replicaCount=3")},
+			path:    "replicaCount",
+			want:    5,
+			wantOK:  true,
+		},
+		{
+			name:    "list element",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSet("ingress.hosts[0].host=example.com")},
+			path:    "ingress.hosts[0].host",
+			want:    "example.com",
+			wantOK:  true,
+		},
+		{
+			name:    "quoted field name",
+			chart:   "testdata/test-chart",
+			options: []helmut.Option{helmut.WithSet(`podAnnotations.example\.com/key=value`)},
+			path:    `podAnnotations["example.com/key"]`,
+			want:    "value",
+			wantOK:  true,
+		},
+		{
+			name:    "subchart value overridden by parent",
+			chart:   "testdata/umbrella-chart",
+			options: []helmut.Option{helmut.WithDependencyBuild(), helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart")},
+			path:    "test-chart.replicaCount",
+			want:    float64(2),
+			wantOK:  true,
+		},
+		{
+			name:    "subchart default",
+			chart:   "testdata/umbrella-chart",
+			options: []helmut.Option{helmut.WithDependencyBuild(), helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart")},
+			path:    "test-chart.image.repository",
+			want:    "nginx",
+			wantOK:  true,
+		},
+		{
+			name:    "global value propagated to subchart",
+			chart:   "testdata/umbrella-chart",
+			options: []helmut.Option{helmut.WithDependencyBuild(), helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart")},
+			path:    "sub-chart.global.environment",
+			want:    "test",
+			wantOK:  true,
+		},
+		{
+			name:  "not found",
+			chart: "testdata/test-chart",
+			path:  "image.notFound",
+		},
+		{
+			name:  "index of map",
+			chart: "testdata/test-chart",
+			path:  "image[0]",
+		},
+		{
+			name:  "invalid path",
+			chart: "testdata/test-chart",
+			path:  "image..tag",
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			manifests, err := r.RenderTemplates(releaseName, tt.chart, tt.options...)
+			if err != nil {
+				t.Fatalf("failed to render templates: %s", err)
+			}
+
+			got, ok := manifests.Values().Get(tt.path)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %t, want %t", ok, tt.wantOK)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("value mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManifestsValuesWithoutRendering(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := helmut.NewManifests().Values().Get("image.tag"); ok {
+		t.Error("got a value from manifests not rendered")
+	}
+}
@@ -0,0 +1,707 @@
+package helmut
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	yaml "go.yaml.in/yaml/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// valuesSentinel is the value set to a values path to confirm whether the templates read it.
+const valuesSentinel = "helmut-values-analysis"
+
+// SourceLocation is a location in a file of the chart.
+type SourceLocation struct {
+	// File is the name of the file prefixed with the names of the chart and its parents,
+	// in the same format as the template names of helm.
+	// e.g. "test-chart/templates/deployment.yaml", "umbrella-chart/charts/test-chart/values.yaml"
+	File string
+
+	Line   int
+	Column int
+}
+
+// String returns the location in the format of "file:line:column".
+func (l SourceLocation) String() string {
+	if l.Column == 0 {
+		return fmt.Sprintf("%s:%d", l.File, l.Line)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// UnusedValue is a key of the default values that no template reads.
+type UnusedValue struct {
+	// Path is the path to the value, which can be passed to Values.Get.
+	Path string
+
+	// Location is the location of the key in the values.yaml.
+	Location SourceLocation
+}
+
+// UndeclaredValue is a values path that the templates read but has no default value.
+type UndeclaredValue struct {
+	// Path is the path to the value, which can be passed to Values.Get.
+	Path string
+
+	// Locations are the locations of the references in the templates.
+	Locations []SourceLocation
+
+	// Confirmed is true if the rendered manifests change when the value is set,
+	// that is, the reference is reached with the default values.
+	Confirmed bool
+}
+
+// ValuesUsage is the result of AnalyzeValues.
+type ValuesUsage struct {
+	Unused     []UnusedValue
+	Undeclared []UndeclaredValue
+}
+
+// AnalyzeValues analyzes the templates and the helpers of the chart and its subcharts,
+// and reports the keys of the values.yaml files that no template reads,
+// and the values paths that the templates read but have no default value.
+//
+// The references to the values are collected statically from the templates, such as ".Values.image.tag",
+// "$.Values.image.tag", "index .Values.image "tag"" and the fields of the dot in "with .Values.image".
+// Since the static analysis cannot follow the values passed to the helpers or accessed dynamically,
+// each result is confirmed by rendering the chart with a value set to the path:
+// an unused key is reported only if the rendered manifests do not change,
+// and an undeclared path is marked as confirmed if the rendered manifests change.
+//
+// The options are applied to every render, and the values of the options are treated as the defaults.
+func (r *Renderer) AnalyzeValues(name, chart string, options ...Option) (*ValuesUsage, error) {
+	if err := r.prepare(); err != nil {
+		return nil, err
+	}
+
+	opts := &option{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	baseline, processed, err := r.render(name, chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := collectValuesRefs(processed, nil, processed.Name()+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := marshalManifests(baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	// changed reports whether setting the value to the path changes the rendered manifests.
+	changed := func(valuesPath []string) bool {
+		perturbed := *opts
+		perturbed.skipSchemaValidation = true
+		perturbed.valuesTrace = false
+		perturbed.valuesMaps = append(append([]map[string]interface{}(nil), opts.valuesMaps...),
+			nestValue(valuesPath, valuesSentinel))
+
+		manifests, _, err := r.render(name, chart, &perturbed)
+		if err != nil {
+			// The value is read, but cannot be the sentinel.
+			return true
+		}
+
+		actual, err := marshalManifests(manifests)
+
+		return err != nil || !bytes.Equal(expected, actual)
+	}
+
+	usage := &ValuesUsage{}
+
+	for _, v := range unusedValues(processed, refs) {
+		if !changed(v.path) {
+			usage.Unused = append(usage.Unused, v.UnusedValue)
+		}
+	}
+
+	for _, v := range undeclaredValues(baseline.Values(), refs) {
+		v.Confirmed = changed(v.path)
+		usage.Undeclared = append(usage.Undeclared, v.UndeclaredValue)
+	}
+
+	return usage, nil
+}
+
+// valuesRef is a reference to the values in a template.
+type valuesRef struct {
+	// path is the path to the value in the values of the release.
+	path []string
+
+	// whole is true if the whole value is read, such as printing it and passing it to functions.
+	// It is false if the value is only tested or used as the dot, such as "if" and "with".
+	whole bool
+
+	location SourceLocation
+}
+
+// unusedValue is an UnusedValue with the path as the field names.
+type unusedValue struct {
+	UnusedValue
+	path []string
+}
+
+// undeclaredValue is an UndeclaredValue with the path as the field names.
+type undeclaredValue struct {
+	UndeclaredValue
+	path []string
+}
+
+// unusedValues returns the leaves of the default values of the chart and its subcharts that no reference reads.
+func unusedValues(c *chart.Chart, refs []valuesRef) []unusedValue {
+	var unused []unusedValue
+
+	var walk func(c *chart.Chart, prefix []string, file string)
+
+	walk = func(c *chart.Chart, prefix []string, file string) {
+		var doc yaml.Node
+
+		for _, f := range c.Raw {
+			if f.Name == chartutil.ValuesfileName {
+				_ = yaml.Unmarshal(f.Data, &doc)
+			}
+		}
+
+		var leaves []unusedValue
+
+		walkLeaves(nil, chartDefaultValues(c), func(leaf []string, _ interface{}) {
+			valuesPath := append(append([]string(nil), prefix...), leaf...)
+
+			if isValueRead(valuesPath, refs) {
+				return
+			}
+
+			leaves = append(leaves, unusedValue{
+				UnusedValue: UnusedValue{
+					Path:     formatValuesPath(valuesPath),
+					Location: SourceLocation{File: file + chartutil.ValuesfileName, Line: yamlKeyLine(&doc, leaf)},
+				},
+				path: valuesPath,
+			})
+		})
+
+		sort.Slice(leaves, func(i, j int) bool {
+			return leaves[i].Path < leaves[j].Path
+		})
+
+		unused = append(unused, leaves...)
+
+		for _, dep := range c.Dependencies() {
+			walk(dep, append(append([]string(nil), prefix...), dep.Name()), file+"charts/"+dep.Name()+"/")
+		}
+	}
+
+	walk(c, nil, c.Name()+"/")
+
+	return unused
+}
+
+// isValueRead returns true if a reference reads the leaf of the values.
+func isValueRead(leaf []string, refs []valuesRef) bool {
+	for _, ref := range refs {
+		for _, refPath := range globalAliases(ref.path) {
+			switch {
+			case len(refPath) <= len(leaf) && hasPathPrefix(leaf, refPath):
+				// The leaf itself or its parent is read.
+				if ref.whole || len(refPath) == len(leaf) {
+					return true
+				}
+			case hasPathPrefix(refPath, leaf):
+				// The leaf is a placeholder, such as an empty map, and the templates read its fields.
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// globalAliases returns the path and the paths of the same global value in the parent charts.
+func globalAliases(valuesPath []string) [][]string {
+	aliases := [][]string{valuesPath}
+
+	for i, name := range valuesPath {
+		if name == "global" {
+			for j := 0; j < i; j++ {
+				aliases = append(aliases, append(append([]string(nil), valuesPath[:j]...), valuesPath[i:]...))
+			}
+
+			break
+		}
+	}
+
+	return aliases
+}
+
+// undeclaredValues returns the references to the paths that have no value, grouped by the path.
+// A path under a value that is not a map, or an empty map, is declared as the placeholder.
+func undeclaredValues(computed Values, refs []valuesRef) []undeclaredValue {
+	indexes := make(map[string]int)
+
+	var undeclared []undeclaredValue
+
+	for _, ref := range refs {
+		if isValueDeclared(computed, ref.path) {
+			continue
+		}
+
+		key := formatValuesPath(ref.path)
+
+		i, ok := indexes[key]
+		if !ok {
+			i = len(undeclared)
+			indexes[key] = i
+
+			undeclared = append(undeclared, undeclaredValue{
+				UndeclaredValue: UndeclaredValue{Path: key},
+				path:            ref.path,
+			})
+		}
+
+		undeclared[i].Locations = append(undeclared[i].Locations, ref.location)
+	}
+
+	for i := range undeclared {
+		undeclared[i].Locations = sortLocations(undeclared[i].Locations)
+	}
+
+	sort.Slice(undeclared, func(i, j int) bool {
+		return undeclared[i].Path < undeclared[j].Path
+	})
+
+	return undeclared
+}
+
+// sortLocations sorts the locations and removes the duplicates.
+func sortLocations(locations []SourceLocation) []SourceLocation {
+	sort.Slice(locations, func(i, j int) bool {
+		x, y := locations[i], locations[j]
+
+		if x.File != y.File {
+			return x.File < y.File
+		}
+
+		if x.Line != y.Line {
+			return x.Line < y.Line
+		}
+
+		return x.Column < y.Column
+	})
+
+	var sorted []SourceLocation
+
+	for _, l := range locations {
+		if len(sorted) == 0 || sorted[len(sorted)-1] != l {
+			sorted = append(sorted, l)
+		}
+	}
+
+	return sorted
+}
+
+// isValueDeclared returns true if the computed values have the path or a placeholder of the path.
+func isValueDeclared(computed Values, valuesPath []string) bool {
+	var current interface{} = map[string]interface{}(computed)
+
+	for _, name := range valuesPath {
+		m, ok := current.(map[string]interface{})
+		if !ok || len(m) == 0 {
+			return true
+		}
+
+		current, ok = m[name]
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasPathPrefix returns true if the path starts with the prefix.
+func hasPathPrefix(valuesPath, prefix []string) bool {
+	if len(prefix) > len(valuesPath) {
+		return false
+	}
+
+	for i := range prefix {
+		if valuesPath[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nestValue returns the nested map that has the value at the path.
+func nestValue(valuesPath []string, value interface{}) map[string]interface{} {
+	for i := len(valuesPath) - 1; i > 0; i-- {
+		value = map[string]interface{}{valuesPath[i]: value}
+	}
+
+	return map[string]interface{}{valuesPath[0]: value}
+}
+
+// yamlKeyLine returns the line of the key at the path in the YAML document, or 0 if not found.
+func yamlKeyLine(doc *yaml.Node, valuesPath []string) int {
+	if len(doc.Content) == 0 {
+		return 0
+	}
+
+	node, line := doc.Content[0], 0
+
+	for _, name := range valuesPath {
+		if node.Kind != yaml.MappingNode {
+			return line
+		}
+
+		found := false
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				node, line, found = node.Content[i+1], node.Content[i].Line, true
+
+				break
+			}
+		}
+
+		if !found {
+			return line
+		}
+	}
+
+	return line
+}
+
+// collectValuesRefs collects the references to the values from the templates of the chart and its subcharts.
+// The prefix is the path to the values of the chart, and the file is the prefix of the template names.
+func collectValuesRefs(c *chart.Chart, prefix []string, file string) ([]valuesRef, error) {
+	var refs []valuesRef
+
+	for _, t := range c.Templates {
+		name := file + t.Name
+
+		tree := parse.New(name)
+		tree.Mode = parse.SkipFuncCheck
+
+		trees := make(map[string]*parse.Tree)
+
+		if _, err := tree.Parse(string(t.Data), "{{", "}}", trees); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+
+		// The defined templates are walked with the dot as the root, because helpers are usually included with ".".
+		for _, tree := range trees {
+			if tree.Root == nil {
+				continue
+			}
+
+			w := &templateWalker{tree: tree, prefix: prefix, variables: make(map[string]valuesContext)}
+			w.walk(tree.Root, valuesContext{kind: rootContext})
+
+			refs = append(refs, w.refs...)
+		}
+	}
+
+	for _, dep := range c.Dependencies() {
+		depRefs, err := collectValuesRefs(dep, append(append([]string(nil), prefix...), dep.Name()),
+			path.Join(file, "charts", dep.Name())+"/")
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, depRefs...)
+	}
+
+	return refs, nil
+}
+
+// valuesContextKind is the kind of the value of a template expression.
+type valuesContextKind int
+
+const (
+	// unknownContext is a value not derived from the values, such as the result of a function.
+	unknownContext valuesContextKind = iota
+	// rootContext is the top-level object passed to the templates.
+	rootContext
+	// valuesPathContext is a value at a path of the values.
+	valuesPathContext
+)
+
+// valuesContext is the value of a template expression.
+type valuesContext struct {
+	kind valuesContextKind
+	path []string
+}
+
+// field returns the value of the fields of the context.
+func (c valuesContext) field(fields ...string) valuesContext {
+	switch c.kind {
+	case rootContext:
+		if len(fields) == 0 {
+			return c
+		}
+
+		if fields[0] != "Values" {
+			return valuesContext{}
+		}
+
+		return valuesContext{kind: valuesPathContext, path: append([]string(nil), fields[1:]...)}
+	case valuesPathContext:
+		return valuesContext{kind: valuesPathContext, path: append(append([]string(nil), c.path...), fields...)}
+	default:
+		return valuesContext{}
+	}
+}
+
+// templateWalker walks the parse tree of a template and collects the references to the values.
+type templateWalker struct {
+	tree      *parse.Tree
+	prefix    []string
+	variables map[string]valuesContext
+	refs      []valuesRef
+}
+
+// walk walks the node with the dot.
+func (w *templateWalker) walk(node parse.Node, dot valuesContext) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, child := range n.Nodes {
+			w.walk(child, dot)
+		}
+	case *parse.ActionNode:
+		pipe := n.Pipe
+
+		if len(pipe.Decl) != 0 && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+			// A variable assigned from a single expression is recorded when its fields are read.
+			if ctx := w.resolve(pipe.Cmds[0].Args[0], dot); ctx.kind != unknownContext {
+				w.assign(pipe, ctx)
+
+				return
+			}
+		}
+
+		w.walkPipe(pipe, dot, true)
+		w.assign(pipe, w.resolvePipe(pipe, dot))
+	case *parse.IfNode:
+		w.walkPipe(n.Pipe, dot, false)
+		w.walk(n.List, dot)
+		w.walk(n.ElseList, dot)
+	case *parse.WithNode:
+		ctx := w.resolvePipe(n.Pipe, dot)
+
+		w.walkPipe(n.Pipe, dot, false)
+		w.assign(n.Pipe, ctx)
+		w.walk(n.List, ctx)
+		w.walk(n.ElseList, dot)
+	case *parse.RangeNode:
+		w.walkPipe(n.Pipe, dot, true)
+
+		for _, v := range n.Pipe.Decl {
+			w.variables[v.Ident[0]] = valuesContext{}
+		}
+
+		w.walk(n.List, valuesContext{})
+		w.walk(n.ElseList, dot)
+	case *parse.TemplateNode:
+		w.walkPipe(n.Pipe, dot, true)
+	}
+}
+
+// walkPipe collects the references of the pipeline.
+func (w *templateWalker) walkPipe(pipe *parse.PipeNode, dot valuesContext, whole bool) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		// The result of a command is passed to the next command, so it is read as a whole.
+		w.walkCommand(cmd, dot, whole || len(pipe.Cmds) > 1)
+	}
+}
+
+// assign assigns the value to the variables declared in the pipeline.
+func (w *templateWalker) assign(pipe *parse.PipeNode, ctx valuesContext) {
+	for _, v := range pipe.Decl {
+		w.variables[v.Ident[0]] = ctx
+	}
+}
+
+// walkCommand collects the references of the command.
+func (w *templateWalker) walkCommand(cmd *parse.CommandNode, dot valuesContext, whole bool) {
+	if len(cmd.Args) == 0 {
+		return
+	}
+
+	fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		w.walkArg(cmd.Args[0], dot, whole)
+
+		for _, arg := range cmd.Args[1:] {
+			w.walkArg(arg, dot, true)
+		}
+
+		return
+	}
+
+	if fn.Ident == "index" && len(cmd.Args) > 1 {
+		ctx, complete := w.resolveIndex(cmd, dot)
+		w.record(ctx, whole || !complete, cmd.Args[1])
+
+		w.walkNested(cmd.Args[1], dot)
+
+		for _, arg := range cmd.Args[2:] {
+			w.walkArg(arg, dot, true)
+		}
+
+		return
+	}
+
+	// The arguments of the functions are read as a whole.
+	for _, arg := range cmd.Args[1:] {
+		w.walkArg(arg, dot, true)
+	}
+}
+
+// walkArg collects the references of the argument.
+func (w *templateWalker) walkArg(arg parse.Node, dot valuesContext, whole bool) {
+	switch n := arg.(type) {
+	case *parse.PipeNode:
+		w.walkPipe(n, dot, whole)
+	case *parse.ChainNode:
+		w.walkNested(n, dot)
+		w.record(w.resolve(n, dot), whole, n)
+	case *parse.FieldNode, *parse.VariableNode, *parse.DotNode:
+		w.record(w.resolve(n, dot), whole, n)
+	}
+}
+
+// walkNested collects the references of the pipeline of the chain node.
+func (w *templateWalker) walkNested(node parse.Node, dot valuesContext) {
+	if chain, ok := node.(*parse.ChainNode); ok {
+		if pipe, ok := chain.Node.(*parse.PipeNode); ok {
+			w.walkPipe(pipe, dot, false)
+		}
+	}
+}
+
+// record records the reference if the value is derived from the values.
+func (w *templateWalker) record(ctx valuesContext, whole bool, node parse.Node) {
+	if ctx.kind != valuesPathContext {
+		return
+	}
+
+	w.refs = append(w.refs, valuesRef{
+		path:     append(append([]string(nil), w.prefix...), ctx.path...),
+		whole:    whole,
+		location: w.location(node),
+	})
+}
+
+// location returns the location of the node in the template.
+func (w *templateWalker) location(node parse.Node) SourceLocation {
+	context, _ := w.tree.ErrorContext(node)
+
+	// The context is in the format of "name:line:column".
+	parts := strings.Split(context, ":")
+	if len(parts) < 3 {
+		return SourceLocation{File: w.tree.ParseName}
+	}
+
+	line, _ := strconv.Atoi(parts[len(parts)-2])
+	column, _ := strconv.Atoi(parts[len(parts)-1])
+
+	return SourceLocation{File: strings.Join(parts[:len(parts)-2], ":"), Line: line, Column: column}
+}
+
+// resolvePipe returns the value of the pipeline.
+func (w *templateWalker) resolvePipe(pipe *parse.PipeNode, dot valuesContext) valuesContext {
+	if pipe == nil || len(pipe.Cmds) == 0 {
+		return valuesContext{}
+	}
+
+	cmd := pipe.Cmds[len(pipe.Cmds)-1]
+
+	if len(cmd.Args) == 1 {
+		return w.resolve(cmd.Args[0], dot)
+	}
+
+	if fn, ok := cmd.Args[0].(*parse.IdentifierNode); ok && fn.Ident == "index" {
+		if ctx, complete := w.resolveIndex(cmd, dot); complete {
+			return ctx
+		}
+	}
+
+	return valuesContext{}
+}
+
+// resolveIndex returns the value of the "index" function with the constant keys.
+// The complete result is false if a key is not a constant, and the value is of the constant keys.
+func (w *templateWalker) resolveIndex(cmd *parse.CommandNode, dot valuesContext) (valuesContext, bool) {
+	ctx := w.resolve(cmd.Args[1], dot)
+
+	for _, arg := range cmd.Args[2:] {
+		key, ok := arg.(*parse.StringNode)
+		if !ok {
+			return ctx, false
+		}
+
+		ctx = ctx.field(key.Text)
+	}
+
+	return ctx, true
+}
+
+// resolve returns the value of the node.
+func (w *templateWalker) resolve(node parse.Node, dot valuesContext) valuesContext {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return dot.field(n.Ident...)
+	case *parse.VariableNode:
+		base, ok := w.variables[n.Ident[0]]
+		if n.Ident[0] == "$" {
+			base, ok = valuesContext{kind: rootContext}, true
+		}
+
+		if !ok {
+			return valuesContext{}
+		}
+
+		return base.field(n.Ident[1:]...)
+	case *parse.ChainNode:
+		var base valuesContext
+
+		if pipe, ok := n.Node.(*parse.PipeNode); ok {
+			base = w.resolvePipe(pipe, dot)
+		} else {
+			base = w.resolve(n.Node, dot)
+		}
+
+		return base.field(n.Field...)
+	case *parse.PipeNode:
+		return w.resolvePipe(n, dot)
+	}
+
+	return valuesContext{}
+}
@@ -0,0 +1,95 @@
+package helmut_test
+
+import (
+	"testing"
+
+	"github.com/d-kuro/helmut"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyzeValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		chart   string
+		options []helmut.Option
+		want    *helmut.ValuesUsage
+	}{
+		{
+			name:  "unused and undeclared values",
+			chart: "testdata/analysis-chart",
+			want: &helmut.ValuesUsage{
+				Unused: []helmut.UnusedValue{
+					{Path: "nested.unused", Location: helmut.SourceLocation{File: "analysis-chart/values.yaml", Line: 8}},
+					{Path: "unused", Location: helmut.SourceLocation{File: "analysis-chart/values.yaml", Line: 9}},
+				},
+				Undeclared: []helmut.UndeclaredValue{
+					{
+						Path:      "feature.name",
+						Locations: []helmut.SourceLocation{{File: "analysis-chart/templates/configmap.yaml", Line: 16, Column: 21}},
+					},
+					{
+						Path:      "namespace",
+						Locations: []helmut.SourceLocation{{File: "analysis-chart/templates/configmap.yaml", Line: 14, Column: 17}},
+						Confirmed: true,
+					},
+				},
+			},
+		},
+		{
+			name:    "values of the options",
+			chart:   "testdata/analysis-chart",
+			options: []helmut.Option{helmut.WithSet("enabled=true", "feature.name=beta", "namespace=test")},
+			want: &helmut.ValuesUsage{
+				Unused: []helmut.UnusedValue{
+					{Path: "nested.unused", Location: helmut.SourceLocation{File: "analysis-chart/values.yaml", Line: 8}},
+					{Path: "unused", Location: helmut.SourceLocation{File: "analysis-chart/values.yaml", Line: 9}},
+				},
+			},
+		},
+		{
+			name:  "subcharts",
+			chart: "testdata/umbrella-chart",
+			options: []helmut.Option{
+				helmut.WithDependencyBuild(),
+				helmut.WithLocalRepository("https://charts.example.com", "testdata/sub-chart"),
+				helmut.WithSet("test-chart.autoscaling.targetMemoryUtilizationPercentage=80"),
+			},
+			want: &helmut.ValuesUsage{
+				Unused: []helmut.UnusedValue{
+					{Path: "global.environment", Location: helmut.SourceLocation{File: "umbrella-chart/values.yaml", Line: 8}},
+				},
+			},
+		},
+	}
+
+	r := newRenderer(t)
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := r.AnalyzeValues("foo", tt.chart, tt.options...)
+			if err != nil {
+				t.Fatalf("failed to analyze values: %s", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("usage mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAnalyzeValuesError(t *testing.T) {
+	t.Parallel()
+
+	r := newRenderer(t)
+
+	if _, err := r.AnalyzeValues("foo", "testdata/not-found"); err == nil {
+		t.Error("got no error for the chart not found")
+	}
+}
@@ -0,0 +1,89 @@
+package helmut
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// walkSymlinks walks the file tree rooted at root in lexical order, calling fn for each file or directory.
+// Unlike filepath.Walk, the symbolic links are followed, and fn is called with the path of the link
+// and the file info of the target, like the chart loader of helm.
+func walkSymlinks(root string, fn filepath.WalkFunc) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkSymlink(root, info, fn)
+	}
+
+	if errors.Is(err, filepath.SkipDir) {
+		return nil
+	}
+
+	return err
+}
+
+// walkSymlink recursively descends the path, resolving the symbolic links.
+func walkSymlink(path string, info fs.FileInfo, fn filepath.WalkFunc) error {
+	if info.Mode()&fs.ModeSymlink != 0 {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate symbolic link %s: %w", path, err)
+		}
+
+		if info, err = os.Lstat(resolved); err != nil {
+			return err
+		}
+
+		if err := walkSymlink(path, info, fn); err != nil && !errors.Is(err, filepath.SkipDir) {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		filename := filepath.Join(path, name)
+
+		fileInfo, err := os.Lstat(filename)
+		if err != nil {
+			if err := fn(filename, nil, err); err != nil && !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+
+			continue
+		}
+
+		if err := walkSymlink(filename, fileInfo, fn); err != nil {
+			if (!fileInfo.IsDir() && fileInfo.Mode()&fs.ModeSymlink == 0) || !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}